@@ -0,0 +1,68 @@
+// go run ./cmd/admin --storage-path=./storage/storage.db --email=ivan.ivanov@example.com
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/admin"
+	repo "github.com/arxonic/gmh/internal/storage"
+	"github.com/arxonic/gmh/internal/storage/sqlite"
+)
+
+// Grants the global admin role to a registered user, so a fresh deployment gets its first admin.
+// Other admins are appointed by them
+func main() {
+	var storagePath, email string
+
+	flag.StringVar(&storagePath, "storage-path", "", "")
+	flag.StringVar(&email, "email", "", "work email of the registered user")
+	flag.Parse()
+
+	if storagePath == "" {
+		panic("storage-path is required")
+	}
+	if email == "" {
+		panic("email is required")
+	}
+
+	storage, err := sqlite.New(storagePath)
+	if err != nil {
+		panic(err)
+	}
+	defer storage.Close()
+
+	user, err := storage.UserByEmail(email)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		panic("user is not registered, register with the bot first")
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	roles, err := storage.AdminRoles(user.ID)
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range roles {
+		if r.Role == models.RoleGlobal {
+			fmt.Println("user is already a global admin")
+			return
+		}
+	}
+
+	if _, err := storage.SaveAdmin(models.Admin{UserID: user.ID, Role: models.RoleGlobal}); err != nil {
+		panic(err)
+	}
+
+	record := models.AuditRecord{AdminID: user.ID, Action: admin.ActionBootstrap, Target: strconv.FormatInt(user.ID, 10), Details: "cmd/admin"}
+	if err := storage.SaveAuditRecord(record); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("user %d is a global admin now\n", user.ID)
+}
@@ -0,0 +1,32 @@
+// go run ./cmd/fsmchart --out=./conversation.dot
+// dot -Tsvg conversation.dot -o conversation.svg
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+)
+
+func main() {
+	var out string
+
+	flag.StringVar(&out, "out", "", "path to the DOT file, stdout if not set")
+	flag.Parse()
+
+	dot := states.NewConversation(slog.New(slog.NewTextHandler(io.Discard, nil))).DOT()
+
+	if out == "" {
+		fmt.Print(dot)
+		return
+	}
+
+	if err := os.WriteFile(out, []byte(dot), 0o644); err != nil {
+		panic(err)
+	}
+}
@@ -1,22 +1,34 @@
 package app
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/arxonic/gmh/internal/config"
+	"github.com/arxonic/gmh/internal/controllers/conversation"
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
 	emailController "github.com/arxonic/gmh/internal/controllers/email"
 	empController "github.com/arxonic/gmh/internal/controllers/employers"
+	"github.com/arxonic/gmh/internal/controllers/http/dashboard"
 	v1 "github.com/arxonic/gmh/internal/controllers/http/v1"
 	"github.com/arxonic/gmh/internal/controllers/telegram"
-	"github.com/arxonic/gmh/internal/controllers/telegram/states"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/lifecycle"
 	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/services/admin"
+	"github.com/arxonic/gmh/internal/services/apikeys"
 	"github.com/arxonic/gmh/internal/services/auth"
 	"github.com/arxonic/gmh/internal/services/email"
 	"github.com/arxonic/gmh/internal/services/employers"
+	"github.com/arxonic/gmh/internal/services/guard"
+	"github.com/arxonic/gmh/internal/services/health"
+	"github.com/arxonic/gmh/internal/services/privacy"
+	"github.com/arxonic/gmh/internal/services/scheduler"
 	"github.com/arxonic/gmh/internal/services/subscribe"
+	"github.com/arxonic/gmh/internal/services/users"
 	"github.com/arxonic/gmh/internal/storage/sqlite"
 	"github.com/go-chi/chi/v5"
 )
@@ -35,43 +47,119 @@ func Run(cfg *config.Config, log *slog.Logger) {
 	// -- init email server
 	emailSrv := emailController.New(cfg.MailServer.Host, cfg.MailServer.Port, cfg.MailServer.Sender, cfg.MailServer.Password)
 
+	// -- init texts of the bot and emails
+	catalog, err := i18n.Load(cfg.I18n.DefaultLanguage, i18n.Args{"company": cfg.I18n.Company}, cfg.I18n.TemplatesDir)
+	if err != nil {
+		log.Error("failed to load message catalog", sl.Err(err))
+		os.Exit(1)
+	}
+
+	// -- init users service
+	usersService := users.New(log, storage, storage)
+
+	// -- init telegram bot
+	bot, err := telegram.NewBot(cfg.TgBotKey, log, cfg.Telegram.PollTimeout, cfg.Telegram.Workers, cfg.Telegram.QueueSize,
+		telegram.SendLimits(cfg.Telegram.Send), usersService, catalog)
+	if err != nil {
+		log.Error("failed to init telegram bot", sl.Err(err))
+		os.Exit(1)
+	}
+
 	// use case
 	// -- init employers service
-	emloyerService := employers.New(log, empAPI)
+	emloyerService := employers.New(log, empAPI, cfg.EmailDomains())
 	// -- init notify service
-	notifyService := email.New(log, emailSrv)
+	notifyService := email.New(log, emailSrv, storage)
+	// -- init abuse protection
+	guardService := guard.New(log, storage, cfg.RateLimit.Lockout, map[string]guard.Limit{
+		guard.KindMessengerUser: guard.Limit(cfg.RateLimit.MessengerUser),
+		guard.KindRegistration:  guard.Limit(cfg.RateLimit.Registration),
+		guard.KindEmail:         guard.Limit(cfg.RateLimit.Email),
+		guard.KindIP:            guard.Limit(cfg.RateLimit.IP),
+	})
 	// -- init auth service
-	authService := auth.New(log, storage, storage, storage, notifyService)
+	authService := auth.New(log, storage, storage, storage, notifyService, bot, guardService, catalog,
+		cfg.Auth.TokenTTL, cfg.Auth.ActivationURL, cfg.Auth.BotURL)
 	// -- init subscribe service
 	subService := subscribe.New(log, storage, storage)
+	// -- init privacy service
+	privacyService := privacy.New(log, storage, storage, bot, catalog)
+	// -- init birthday scheduler
+	schedulerService := scheduler.New(log, storage, storage, bot, catalog, cfg.Scheduler.Interval, cfg.Scheduler.DaysBefore)
+	// -- init API keys service
+	keysService := apikeys.New(log, storage, cfg.Auth.PersonalKeyTTL)
+	// -- init admin service
+	adminService := admin.New(log, storage, storage, storage, storage, authService, keysService, schedulerService, bot, catalog,
+		cfg.AdminWeb.SessionTTL, cfg.AdminWeb.LoginCodeTTL)
+	// -- init readiness checks
+	healthService := health.New(log, cfg.Health.CheckTimeout)
+	healthService.Register("sqlite", storage)
+	healthService.Register("telegram", bot)
+	healthService.Register("smtp", emailSrv)
+	healthService.Register("employer_api", empAPI)
 
 	// transport
 	httpRouter := chi.NewRouter()
-	v1.NewRouts(httpRouter, log, authService)
-	srv := v1.NewServer(cfg.Address, httpRouter)
-	go func() {
-		if err := v1.Run(srv); err != nil {
-			log.Error("failed to run http server", sl.Err(err))
+	if err := v1.NewRouts(httpRouter, log, catalog, authService, guardService, keysService, usersService, subService, healthService); err != nil {
+		log.Error("failed to init http routes", sl.Err(err))
+		os.Exit(1)
+	}
+	dashboard.NewRouts(httpRouter, log, adminService, v1.RateLimit(log, guardService))
+	if cfg.Telegram.Mode == telegram.ModeWebhook {
+		if err := bot.StartWebhook(cfg.Telegram.Webhook.URL, cfg.Telegram.Webhook.Secret); err != nil {
+			log.Error("failed to register telegram webhook", sl.Err(err))
 			os.Exit(1)
 		}
-	}()
-
-	states := states.NewStates()
-	bot, err := telegram.NewBot(cfg.TgBotKey, log)
-	if err != nil {
-		log.Error("failed to init telegram bot", sl.Err(err))
-		os.Exit(1)
+		httpRouter.Post(bot.WebhookPath(), bot.WebhookHandler())
 	}
+	srv := v1.NewServer(cfg.Address, httpRouter, cfg.HTTPServer.Timeout, cfg.HTTPServer.IdleTimeout)
+	var stateStore states.StateStore = states.NewMemoryStore()
+	if cfg.Telegram.StateStore == states.StoreSQLite {
+		stateStore = states.NewSQLiteStore(storage, telegram.MessengerType)
+	}
+	states := states.NewStates(log, stateStore, cfg.Telegram.Session.TTL, cfg.Telegram.Session.Retention)
+	// -- init the conversation held in telegram
+	conv := conversation.New(log, bot, catalog, states, subService, authService, emloyerService, privacyService, adminService, guardService, usersService, keysService)
 
-	bot.Run(states, subService, authService, emloyerService)
-
-	// graceful shutdown
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	// lifecycle: components start in this order and stop in reverse
+	lc := lifecycle.New(log, cfg.Shutdown.Timeout)
+	lc.Add("storage", nil, func(context.Context) error {
+		return storage.Close()
+	})
+	// Messages of the bot are sent until everything that notifies users is stopped
+	lc.Add("telegram_sender", bot.RunSender, bot.CloseSender)
+	lc.Add("email_outbox", notifyService.Run, notifyService.Close)
+	lc.Add("scheduler", func(ctx context.Context) error {
+		schedulerService.Run(ctx)
+		return nil
+	}, nil)
+	lc.Add("session_janitor", func(ctx context.Context) error {
+		states.RunJanitor(ctx, cfg.Telegram.Session.JanitorInterval)
+		return nil
+	}, nil)
+	lc.Add("lockout_janitor", func(ctx context.Context) error {
+		guardService.RunJanitor(ctx, cfg.RateLimit.JanitorInterval)
+		return nil
+	}, nil)
+	lc.Add("telegram_bot", func(ctx context.Context) error {
+		return bot.Run(ctx, conv)
+	}, nil)
+	lc.Add("http_server", func(context.Context) error {
+		return v1.Run(srv)
+	}, srv.Shutdown)
+	// Telegram stops sending updates before the server goes down, pending ones are kept by Telegram
+	lc.Add("telegram_webhook", nil, func(context.Context) error {
+		return bot.StopWebhook()
+	})
 
-	notify := <-stop
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	err = lc.Run(ctx)
+	stop()
 
-	// TODO stop app
+	if err != nil {
+		log.Error("application stopped with error", sl.Err(err))
+		os.Exit(1)
+	}
 
-	log.Info("application stopped", slog.String("signal", notify.String()))
+	log.Info("application stopped")
 }
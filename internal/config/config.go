@@ -15,11 +15,66 @@ type flags struct {
 }
 
 type Config struct {
-	Env         string `yaml:"env" envDefault:"local"`
-	StoragePath string `yaml:"storage_path" env-required:"true"`
-	TgBotKey    string
-	HTTPServer  `yaml:"http_server"`
-	MailServer  `yaml:"mail_server"`
+	Env           string `yaml:"env" envDefault:"local"`
+	StoragePath   string `yaml:"storage_path" env-required:"true"`
+	TgBotKey      string
+	Telegram      `yaml:"telegram"`
+	HTTPServer    `yaml:"http_server"`
+	MailServer    `yaml:"mail_server"`
+	Auth          `yaml:"auth"`
+	Scheduler     `yaml:"scheduler"`
+	RateLimit     `yaml:"rate_limit"`
+	Health        `yaml:"health"`
+	AdminWeb      `yaml:"admin_web"`
+	Shutdown      `yaml:"shutdown"`
+	I18n          `yaml:"i18n"`
+	Organizations []Organization `yaml:"organizations"`
+}
+
+type Telegram struct {
+	Mode        string        `yaml:"mode" env-default:"polling"` // polling or webhook
+	PollTimeout time.Duration `yaml:"poll_timeout" env-default:"60s"`
+	Webhook     Webhook       `yaml:"webhook"`
+	// Workers handle updates of different users concurrently, each has a queue of QueueSize updates
+	Workers   int  `yaml:"workers" env-default:"8"`
+	QueueSize int  `yaml:"queue_size" env-default:"16"`
+	Send      Send `yaml:"send"`
+	// StateStore keeps conversation states: memory or sqlite, sqlite states survive restarts
+	StateStore string  `yaml:"state_store" env-default:"sqlite"`
+	Session    Session `yaml:"session"`
+}
+
+// Session expires after TTL without messages, the next message returns the user to the menu.
+// Sessions idle longer than Retention are evicted every JanitorInterval
+type Session struct {
+	TTL             time.Duration `yaml:"ttl" env-default:"30m"`
+	Retention       time.Duration `yaml:"retention" env-default:"168h"`
+	JanitorInterval time.Duration `yaml:"janitor_interval" env-default:"10m"`
+}
+
+// Send limits outgoing messages below the Telegram limits: about 30 messages per second in total
+// and one per second to a chat. Failed by network or server errors messages are tried Attempts times,
+// the delay starts from Backoff and doubles
+type Send struct {
+	PerSecond     float64       `yaml:"per_second" env-default:"25"`
+	ChatPerSecond float64       `yaml:"chat_per_second" env-default:"1"`
+	ChatBurst     int           `yaml:"chat_burst" env-default:"3"`
+	Attempts      int           `yaml:"attempts" env-default:"4"`
+	Backoff       time.Duration `yaml:"backoff" env-default:"500ms"`
+}
+
+// Webhook is used in the webhook mode. The path of URL is routed by the HTTP server
+type Webhook struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret" env:"TG_WEBHOOK_SECRET"`
+}
+
+// I18n sets the texts of the bot and emails. Company is the {{.company}} variable of the texts.
+// TemplatesDir contains <lang>.tmpl files overriding the embedded texts, not set means no overrides
+type I18n struct {
+	DefaultLanguage string `yaml:"default_language" env-default:"ru"`
+	Company         string `yaml:"company" env-required:"true"`
+	TemplatesDir    string `yaml:"templates_dir"`
 }
 
 type HTTPServer struct {
@@ -35,6 +90,65 @@ type MailServer struct {
 	Password string
 }
 
+// Auth sets the activation links and personal API keys of employees, issued with the bot command /apikey
+type Auth struct {
+	TokenTTL       time.Duration `yaml:"token_ttl" env-default:"24h"`
+	PersonalKeyTTL time.Duration `yaml:"personal_key_ttl" env-default:"2160h"`
+	ActivationURL  string        `yaml:"activation_url" env-default:"http://localhost:2001/v1/auth"`
+	BotURL         string        `yaml:"bot_url" env-default:"https://t.me/GPMHappyBBot"`
+}
+
+type Scheduler struct {
+	Interval   time.Duration `yaml:"interval" env-default:"1h"`
+	DaysBefore int           `yaml:"days_before" env-default:"7"`
+}
+
+// RateLimit locks out subjects exceeding their limit for Lockout, ended lockouts are deleted every JanitorInterval
+type RateLimit struct {
+	Lockout         time.Duration `yaml:"lockout" env-default:"15m"`
+	JanitorInterval time.Duration `yaml:"janitor_interval" env-default:"1h"`
+	MessengerUser   Limit         `yaml:"messenger_user"`
+	Registration    Limit         `yaml:"registration"`
+	Email           Limit         `yaml:"email"`
+	IP              Limit         `yaml:"ip"`
+}
+
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env-default:"2s"`
+}
+
+type AdminWeb struct {
+	SessionTTL   time.Duration `yaml:"session_ttl" env-default:"12h"`
+	LoginCodeTTL time.Duration `yaml:"login_code_ttl" env-default:"10m"`
+}
+
+// Shutdown limits the graceful shutdown of all components on SIGTERM or SIGINT
+type Shutdown struct {
+	Timeout time.Duration `yaml:"timeout" env-default:"15s"`
+}
+
+// Limit is the token bucket: PerMinute tokens are refilled up to Burst
+type Limit struct {
+	PerMinute float64 `yaml:"per_minute"`
+	Burst     int     `yaml:"burst"`
+}
+
+// Organization lists corporate email domains allowed for registration in the organization
+type Organization struct {
+	Name         string   `yaml:"name"`
+	EmailDomains []string `yaml:"email_domains"`
+}
+
+// EmailDomains returns allowed email domains by organization name
+func (c *Config) EmailDomains() map[string][]string {
+	domains := make(map[string][]string, len(c.Organizations))
+	for _, org := range c.Organizations {
+		domains[org.Name] = append(domains[org.Name], org.EmailDomains...)
+	}
+
+	return domains
+}
+
 func MustLoad() *Config {
 	e := fetchFlags()
 
@@ -0,0 +1,264 @@
+package conversation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/admin"
+	"github.com/arxonic/gmh/internal/services/apikeys"
+)
+
+type Administrator interface {
+	AdminByMessengerID(messengerType string, messengerID int64) (int64, error)
+	FindUser(adminID int64, query string) (models.UserInfo, error)
+	SetActivation(adminID, uID int64, activated bool) error
+	Celebrations(adminID int64) ([]models.Celebration, error)
+	CancelCelebration(adminID, celebrationID int64) error
+	RunScheduler(adminID int64) (int, error)
+	APIKeys(adminID int64) ([]models.APIKey, error)
+	IssueAPIKey(adminID int64, name string, scopes []string, ttl time.Duration) (string, models.APIKey, error)
+	RevokeAPIKey(adminID, keyID int64) error
+}
+
+func (c *Conversation) AdminHandler(m Message, adm Administrator, state *states.UserState, l i18n.Localizer) (int, error) {
+	adminID, err := adm.AdminByMessengerID(c.messenger.Type(), m.UserID)
+	if err != nil {
+		c.showScreen(m.ChatID, state, menuScreen(l, ""))
+		return states.StateMenu, nil
+	}
+
+	args := strings.Fields(m.Text)
+	if len(args) == 0 {
+		c.messenger.Reply(m, l.T("admin.menu"))
+		return states.StateAdmin, nil
+	}
+
+	switch args[0] {
+	case "user":
+		if len(args) < 2 {
+			c.messenger.Reply(m, l.T("error.input"))
+			return states.StateAdmin, nil
+		}
+
+		info, err := adm.FindUser(adminID, args[1])
+		if err != nil {
+			c.messenger.Reply(m, adminErrorText(l, err, "admin.user_not_found"))
+			return states.StateAdmin, nil
+		}
+
+		c.messenger.Reply(m, userInfoText(l, info))
+
+	case "activate", "deactivate":
+		uID, err := adminArgID(args)
+		if err != nil {
+			c.messenger.Reply(m, l.T("error.input"))
+			return states.StateAdmin, nil
+		}
+
+		if err := adm.SetActivation(adminID, uID, args[0] == "activate"); err != nil {
+			c.messenger.Reply(m, adminErrorText(l, err, "admin.user_not_found"))
+			return states.StateAdmin, nil
+		}
+
+		c.messenger.Reply(m, l.T("admin.done"))
+
+	case "celebrations":
+		celebrations, err := adm.Celebrations(adminID)
+		if err != nil {
+			c.messenger.Reply(m, adminErrorText(l, err, "error.server"))
+			return states.StateAdmin, nil
+		}
+
+		if len(celebrations) == 0 {
+			c.messenger.Reply(m, l.T("admin.no_celebrations"))
+			return states.StateAdmin, nil
+		}
+
+		lines := make([]string, 0, len(celebrations))
+		for _, cel := range celebrations {
+			lines = append(lines, l.T("admin.celebration", i18n.Args{"id": cel.ID, "uid": cel.UserID, "date": cel.Birthday.Format("02.01.2006"), i18n.CountArg: cel.Subscribers}))
+		}
+
+		c.messenger.Reply(m, strings.Join(lines, "\n"))
+
+	case "cancel":
+		id, err := adminArgID(args)
+		if err != nil {
+			c.messenger.Reply(m, l.T("error.input"))
+			return states.StateAdmin, nil
+		}
+
+		if err := adm.CancelCelebration(adminID, id); err != nil {
+			c.messenger.Reply(m, adminErrorText(l, err, "admin.celebration_not_found"))
+			return states.StateAdmin, nil
+		}
+
+		c.messenger.Reply(m, l.T("admin.celebration_cancelled"))
+
+	case "run":
+		created, err := adm.RunScheduler(adminID)
+		if err != nil {
+			c.messenger.Reply(m, adminErrorText(l, err, "admin.scheduler_failed"))
+			return states.StateAdmin, nil
+		}
+
+		c.messenger.Reply(m, l.T("admin.scheduler_done", i18n.Args{i18n.CountArg: created}))
+
+	case "keys":
+		keys, err := adm.APIKeys(adminID)
+		if err != nil {
+			c.messenger.Reply(m, adminErrorText(l, err, "error.server"))
+			return states.StateAdmin, nil
+		}
+
+		if len(keys) == 0 {
+			c.messenger.Reply(m, l.T("admin.no_keys"))
+			return states.StateAdmin, nil
+		}
+
+		lines := make([]string, 0, len(keys))
+		for _, k := range keys {
+			lines = append(lines, apiKeyText(l, k))
+		}
+
+		c.messenger.Reply(m, strings.Join(lines, "\n"))
+
+	case "key":
+		c.apiKeyCommand(m, adm, adminID, args[1:], l)
+
+	case "exit":
+		c.showScreen(m.ChatID, state, menuScreen(l, ""))
+		return states.StateMenu, nil
+
+	default:
+		c.messenger.Reply(m, l.T("admin.menu"))
+	}
+
+	return states.StateAdmin, nil
+}
+
+// apiKeyCommand handles "key issue <name> <scopes> [days]" and "key revoke <id>"
+func (c *Conversation) apiKeyCommand(m Message, adm Administrator, adminID int64, args []string, l i18n.Localizer) {
+	if len(args) == 0 {
+		c.messenger.Reply(m, l.T("error.input"))
+		return
+	}
+
+	switch args[0] {
+	case "issue":
+		if len(args) < 3 {
+			c.messenger.Reply(m, l.T("error.input"))
+			return
+		}
+
+		var ttl time.Duration
+		if len(args) > 3 {
+			days, err := strconv.Atoi(args[3])
+			if err != nil || days < 0 {
+				c.messenger.Reply(m, l.T("error.input"))
+				return
+			}
+			ttl = time.Duration(days) * 24 * time.Hour
+		}
+
+		key, apiKey, err := adm.IssueAPIKey(adminID, args[1], strings.Split(args[2], ","), ttl)
+		switch {
+		case errors.Is(err, apikeys.ErrKeyExists):
+			c.messenger.Reply(m, l.T("admin.key_exists"))
+			return
+		case errors.Is(err, apikeys.ErrUnknownScope), errors.Is(err, apikeys.ErrNoScopes):
+			c.messenger.Reply(m, l.T("admin.unknown_scope", i18n.Args{"scopes": strings.Join(models.Scopes, ", ")}))
+			return
+		case err != nil:
+			c.messenger.Reply(m, adminErrorText(l, err, "error.server"))
+			return
+		}
+
+		c.messenger.Reply(m, l.T("admin.key_issued", i18n.Args{"info": apiKeyText(l, apiKey), "key": key}))
+
+	case "revoke":
+		id, err := adminArgID(args)
+		if err != nil {
+			c.messenger.Reply(m, l.T("error.input"))
+			return
+		}
+
+		err = adm.RevokeAPIKey(adminID, id)
+		switch {
+		case errors.Is(err, apikeys.ErrKeyNotFound):
+			c.messenger.Reply(m, l.T("admin.key_not_found"))
+			return
+		case err != nil:
+			c.messenger.Reply(m, adminErrorText(l, err, "error.server"))
+			return
+		}
+
+		c.messenger.Reply(m, l.T("admin.key_revoked"))
+
+	default:
+		c.messenger.Reply(m, l.T("error.input"))
+	}
+}
+
+func adminArgID(args []string) (int64, error) {
+	if len(args) < 2 {
+		return 0, errors.New("id is required")
+	}
+
+	return strconv.ParseInt(args[1], 10, 64)
+}
+
+// adminErrorText returns the message of the fallback key unless the admin lacks permissions
+func adminErrorText(l i18n.Localizer, err error, fallback string) string {
+	if errors.Is(err, admin.ErrForbidden) {
+		return l.T("admin.forbidden")
+	}
+
+	return l.T(fallback)
+}
+
+func apiKeyText(l i18n.Localizer, k models.APIKey) string {
+	text := fmt.Sprintf("%d: %s [%s]", k.ID, k.Name, strings.Join(k.Scopes, ", "))
+
+	if k.ExpiresAt != nil {
+		text += l.T("admin.key_expires", i18n.Args{"date": k.ExpiresAt.Format("02.01.2006")})
+	}
+
+	if k.LastUsedAt != nil {
+		text += l.T("admin.key_used", i18n.Args{"time": k.LastUsedAt.Format("02.01.2006 15:04")})
+	} else {
+		text += l.T("admin.key_unused")
+	}
+
+	return text
+}
+
+func userInfoText(l i18n.Localizer, info models.UserInfo) string {
+	u := info.User
+	text := l.T("admin.user", i18n.Args{
+		"id":       u.ID,
+		"name":     strings.TrimSpace(u.LastName + " " + u.FirstName + " " + u.Patronymic),
+		"email":    u.Email,
+		"birthday": u.BirthDate.Format("02.01.2006"),
+	})
+
+	for _, org := range info.Organizations {
+		text += fmt.Sprintf("\n%s, %s, %s, %s", org.Name, org.City, org.Office, org.Department)
+	}
+
+	for _, m := range info.Messengers {
+		key := "admin.messenger_not_activated"
+		if m.IsActivated {
+			key = "admin.messenger_activated"
+		}
+		text += "\n" + l.T(key, i18n.Args{"messenger": m.MessengerType})
+	}
+
+	return text
+}
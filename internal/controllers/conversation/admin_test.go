@@ -0,0 +1,50 @@
+package conversation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/services/apikeys"
+)
+
+func TestRevokeAPIKeyErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"revoked", nil, "admin.key_revoked"},
+		{"not found", fmt.Errorf("admin.RevokeAPIKey:%w", apikeys.ErrKeyNotFound), "admin.key_not_found"},
+		{"storage failure", errors.New("database is locked"), "error.server"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestConversation(t, 0)
+			tc.admins.adminID = 1
+			tc.admins.revokeErr = tt.err
+
+			const userID = 1
+			tc.states.Store(userID, states.UserState{State: states.StateAdmin})
+			tc.send(t, userID, "key revoke 5")
+
+			if got, want := tc.lastReply(t), tc.l.T(tt.want); got != want {
+				t.Errorf("reply = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAdminCommandOfNotAdmin(t *testing.T) {
+	tc := newTestConversation(t, 0)
+
+	const userID = 1
+	tc.states.Store(userID, states.UserState{State: states.StateMenu})
+
+	if state := tc.send(t, userID, "/admin"); state.State != states.StateMenu {
+		t.Errorf("state = %s, want menu", states.Name(state.State))
+	}
+	if got, want := tc.lastReply(t), tc.l.T("error.unknown_command"); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,330 @@
+package conversation
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/models"
+)
+
+// activatedStates are the states of users with an activated account
+var activatedStates = []int{states.StateMenu, states.StateDeleteConfirm, states.StateAdmin, states.StateFind, states.StateSubscribe}
+
+// CommandContext is everything a command handler needs, so handlers don't depend on the messenger
+type CommandContext struct {
+	ChatID        int64
+	MessengerType string
+	MessengerID   int64
+	// LanguageCode is the language of the user's messenger client
+	LanguageCode string
+	// Payload is the text after the command, e.g. the deep link parameter of /start
+	Payload   string
+	State     *states.UserState
+	Localizer i18n.Localizer
+	// Reply sends a text message to the chat
+	Reply func(text string) error
+	// Menu sends the main menu with the text above it
+	Menu func(text string) error
+}
+
+// CommandHandler handles the command and returns the next state of the user
+type CommandHandler func(c CommandContext) int
+
+// Command is a slash command handled before the state machine
+type Command struct {
+	Name        string // without the slash
+	Description string // message key of the text shown in the command menu of the messenger
+	// States where the command is available, nil means any state
+	States []int
+	// Unavailable is the message key of the reply in other states. If it is empty, the message is passed to the state machine
+	Unavailable string
+	Handler     CommandHandler
+}
+
+func (c Command) availableIn(state int) bool {
+	if c.States == nil {
+		return true
+	}
+
+	for _, s := range c.States {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CommandRouter finds the command of a message
+type CommandRouter struct {
+	commands []Command
+}
+
+func NewCommandRouter(commands ...Command) *CommandRouter {
+	return &CommandRouter{commands: commands}
+}
+
+// Match returns the command of the text available in the state and its payload.
+// Commands addressed to the bot as /cmd@bot_name are matched too.
+// ok is false if the text must be handled by the state machine
+func (r *CommandRouter) Match(text string, state int) (cmd Command, payload string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return Command{}, "", false
+	}
+
+	name, payload, _ := strings.Cut(text[1:], " ")
+	name, _, _ = strings.Cut(name, "@")
+
+	for _, c := range r.commands {
+		if c.Name != name {
+			continue
+		}
+
+		if !c.availableIn(state) {
+			if c.Unavailable == "" {
+				return Command{}, "", false
+			}
+
+			unavailable := c.Unavailable
+			c.Handler = func(cc CommandContext) int {
+				cc.Reply(cc.Localizer.T(unavailable))
+				return state
+			}
+		}
+
+		return c, strings.TrimSpace(payload), true
+	}
+
+	return Command{}, "", false
+}
+
+// Help returns descriptions of commands available in the state
+func (r *CommandRouter) Help(state int, l i18n.Localizer) string {
+	lines := make([]string, 0, len(r.commands))
+	for _, c := range r.commands {
+		if c.availableIn(state) {
+			lines = append(lines, "/"+c.Name+" - "+l.T(c.Description))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// defaultCommands returns /start, /help, /menu, /cancel, /language and /apikey
+func defaultCommands(uf UserFinder, ua UserAuther, lk LanguageKeeper, ki KeyIssuer, messengerType string, catalog *i18n.Catalog) *CommandRouter {
+	r := NewCommandRouter()
+
+	r.commands = []Command{
+		{
+			Name:        "start",
+			Description: "command.start",
+			Handler:     startCommand(uf, ua, messengerType),
+		},
+		{
+			Name:        "menu",
+			Description: "command.menu",
+			States:      activatedStates,
+			Unavailable: "command.menu_unavailable",
+			Handler:     menuCommand,
+		},
+		{
+			Name:        "cancel",
+			Description: "command.cancel",
+			States:      activatedStates,
+			Unavailable: "command.nothing_to_cancel",
+			Handler:     cancelCommand,
+		},
+		{
+			Name:        "language",
+			Description: "command.language",
+			States:      activatedStates,
+			Unavailable: "command.language_unavailable",
+			Handler:     languageCommand(lk, messengerType, catalog),
+		},
+		{
+			Name:        "apikey",
+			Description: "command.apikey",
+			States:      activatedStates,
+			Unavailable: "command.apikey_unavailable",
+			Handler:     personalKeyCommand(uf, ki),
+		},
+		{
+			Name:        "help",
+			Description: "command.help",
+			Handler: func(c CommandContext) int {
+				c.Reply(c.Localizer.T("command.help_title") + "\n" + r.Help(c.State.State, c.Localizer))
+				return c.State.State
+			},
+		},
+	}
+
+	return r
+}
+
+// startCommand shows the menu. The deep link payload sub_<user id> subscribes to the user birthday,
+// users without an activated account are greeted and the payload is kept until the account is activated
+func startCommand(uf UserFinder, ua UserAuther, messengerType string) CommandHandler {
+	return func(c CommandContext) int {
+		if c.State.State == states.StateAuthMiddleware || c.State.State == states.StateEmailWait {
+			isActivated, err := ua.IsActivated(messengerType, c.MessengerID, c.ChatID)
+			if err != nil || !isActivated {
+				if c.Payload != "" {
+					c.State.StartPayload = c.Payload
+				}
+
+				// Registered users waiting for the link are told to follow it, unless they entered
+				// the email in this session: the reply must not tell whether the email was accepted
+				if err == nil && c.State.State == states.StateAuthMiddleware {
+					c.Reply(c.Localizer.T("auth.follow_link"))
+					return states.StateAuthMiddleware
+				}
+
+				c.Reply(c.Localizer.T("auth.greeting"))
+				return states.StateEmailWait
+			}
+		}
+
+		c.State.Finder = states.FindState{}
+
+		payload := c.Payload
+		if payload == "" {
+			payload = c.State.StartPayload
+		}
+		c.State.StartPayload = ""
+
+		c.Menu(startLinkText(c.Localizer, uf, c.MessengerType, c.MessengerID, payload))
+
+		return states.StateMenu
+	}
+}
+
+// startLinkText follows the deep link payload of /start and returns the result for the user,
+// it is empty if there is no payload
+func startLinkText(l i18n.Localizer, uf UserFinder, messengerType string, messengerID int64, payload string) string {
+	v, ok := strings.CutPrefix(payload, "sub_")
+	if !ok {
+		return ""
+	}
+
+	uID, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return l.T("subscribe.bad_link")
+	}
+
+	return subscribeText(l, uf.Subscribe(messengerType, messengerID, uID))
+}
+
+// menuCommand shows the menu, the finder resumes from the same step
+func menuCommand(c CommandContext) int {
+	c.Menu("")
+
+	return states.StateMenu
+}
+
+// cancelCommand leaves the finder, the account deletion or the admin mode
+func cancelCommand(c CommandContext) int {
+	text := c.Localizer.T("command.cancelled")
+	if c.State.State == states.StateMenu {
+		text = c.Localizer.T("command.nothing_to_cancel")
+	}
+
+	c.State.Finder = states.FindState{}
+	c.Menu(text)
+
+	return states.StateMenu
+}
+
+// languageAuto is the /language argument to follow the messenger client
+const languageAuto = "auto"
+
+type LanguageKeeper interface {
+	Language(messengerType string, messengerID int64) (string, error)
+	SetLanguage(messengerType string, messengerID int64, lang string) error
+}
+
+// languageCommand shows the message language, "/language <lang>" chooses it and "/language auto" follows the messenger client
+func languageCommand(lk LanguageKeeper, messengerType string, catalog *i18n.Catalog) CommandHandler {
+	return func(c CommandContext) int {
+		langs := strings.Join(catalog.Languages(), " | ")
+
+		lang := strings.ToLower(c.Payload)
+		switch lang {
+		case "":
+			c.Reply(c.Localizer.T("language.current", i18n.Args{"language": c.Localizer.T("language.name"), "languages": langs}))
+			return c.State.State
+		case languageAuto:
+			lang = ""
+		default:
+			if catalog.Match(lang) != lang {
+				c.Reply(c.Localizer.T("language.unknown", i18n.Args{"languages": langs}))
+				return c.State.State
+			}
+		}
+
+		if err := lk.SetLanguage(messengerType, c.MessengerID, lang); err != nil {
+			c.Reply(c.Localizer.T("error.server"))
+			return c.State.State
+		}
+		c.State.Language = lang
+
+		l := catalog.Localizer(lang, c.LanguageCode)
+		if lang == "" {
+			c.Reply(l.T("language.auto"))
+		} else {
+			c.Reply(l.T("language.set", i18n.Args{"language": l.T("language.name")}))
+		}
+
+		return c.State.State
+	}
+}
+
+type KeyIssuer interface {
+	IssuePersonal(uID int64) (string, models.APIKey, error)
+}
+
+// personalKeyCommand issues a personal key of the subscriptions API, the API acts on behalf of the user
+func personalKeyCommand(uf UserFinder, ki KeyIssuer) CommandHandler {
+	return func(c CommandContext) int {
+		uID, err := uf.UserIDByMessengerID(c.MessengerType, c.MessengerID)
+		if err != nil {
+			c.Reply(c.Localizer.T("error.server"))
+			return c.State.State
+		}
+
+		key, apiKey, err := ki.IssuePersonal(uID)
+		if err != nil {
+			c.Reply(c.Localizer.T("error.server"))
+			return c.State.State
+		}
+
+		expires := ""
+		if apiKey.ExpiresAt != nil {
+			expires = c.Localizer.T("admin.key_expires", i18n.Args{"date": apiKey.ExpiresAt.Format("02.01.2006")})
+		}
+		c.Reply(c.Localizer.T("apikey.issued", i18n.Args{"expires": expires, "key": key}))
+
+		return c.State.State
+	}
+}
+
+// commandContext binds the command context to the chat of the message
+func (c *Conversation) commandContext(m Message, state *states.UserState, payload string) CommandContext {
+	return CommandContext{
+		ChatID:        m.ChatID,
+		MessengerType: c.messenger.Type(),
+		MessengerID:   m.UserID,
+		LanguageCode:  m.LanguageCode,
+		Payload:       payload,
+		State:         state,
+		Localizer:     c.localizer(m.LanguageCode, state.Language),
+		Reply: func(text string) error {
+			return c.messenger.Reply(m, text)
+		},
+		// The localizer is taken on call, the command may change the language
+		Menu: func(text string) error {
+			return c.showScreen(m.ChatID, state, menuScreen(c.localizer(m.LanguageCode, state.Language), text))
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package conversation
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/models"
+)
+
+func TestCommandRouterMatch(t *testing.T) {
+	r := NewCommandRouter(
+		Command{Name: "menu", States: []int{states.StateMenu}, Unavailable: "command.menu_unavailable"},
+		Command{Name: "cancel", States: []int{states.StateMenu}},
+	)
+
+	for _, tt := range []struct {
+		text        string
+		state       int
+		wantOK      bool
+		wantName    string
+		wantPayload string
+	}{
+		{"/menu", states.StateMenu, true, "menu", ""},
+		{"/menu@gmh_bot  now ", states.StateMenu, true, "menu", "now"},
+		{"/menu", states.StateEmailWait, true, "menu", ""},
+		{"/cancel", states.StateEmailWait, false, "", ""},
+		{"/unknown", states.StateMenu, false, "", ""},
+		{"menu", states.StateMenu, false, "", ""},
+	} {
+		cmd, payload, ok := r.Match(tt.text, tt.state)
+		if ok != tt.wantOK || cmd.Name != tt.wantName || payload != tt.wantPayload {
+			t.Errorf("Match(%q, %s) = %q, %q, %v, want %q, %q, %v", tt.text, states.Name(tt.state),
+				cmd.Name, payload, ok, tt.wantName, tt.wantPayload, tt.wantOK)
+		}
+	}
+}
+
+// TestStartAvailableInEveryState checks that /start is never passed to the state machine as text
+func TestStartAvailableInEveryState(t *testing.T) {
+	tc := newTestConversation(t, 0)
+
+	for state := states.StateAuthMiddleware; state <= states.StateSubscribe; state++ {
+		if _, _, ok := tc.commands.Match("/start", state); !ok {
+			t.Errorf("/start is not available in %s", states.Name(state))
+		}
+	}
+}
+
+// TestStartLinkOfNewUser checks that the deep link sent before registration is followed after the activation
+func TestStartLinkOfNewUser(t *testing.T) {
+	tc := newTestConversation(t, 0)
+	tc.services.employees["ivan@example.com"] = models.Emp{}
+
+	const userID = 1
+
+	state := tc.send(t, userID, "/start sub_7")
+	if state.State != states.StateEmailWait || state.StartPayload != "sub_7" {
+		t.Fatalf("state after /start = %s with payload %q, want email_wait with sub_7", states.Name(state.State), state.StartPayload)
+	}
+	if got, want := tc.lastReply(t), tc.l.T("auth.greeting"); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+
+	// /start without the payload keeps the link
+	if state := tc.send(t, userID, "/start"); state.StartPayload != "sub_7" {
+		t.Errorf("payload after /start in email_wait = %q, want sub_7", state.StartPayload)
+	}
+
+	tc.send(t, userID, "ivan@example.com")
+	tc.services.activated[userID] = true
+
+	state = tc.send(t, userID, "hi")
+	if state.State != states.StateMenu || state.StartPayload != "" {
+		t.Errorf("state after activation = %s with payload %q, want menu without payload", states.Name(state.State), state.StartPayload)
+	}
+	if !slices.Equal(tc.services.subscribed, []int64{7}) {
+		t.Errorf("subscribed = %v, want [7]", tc.services.subscribed)
+	}
+	if sc := tc.messenger.lastScreen(t); !strings.Contains(sc.Text, tc.l.T("subscribe.done")) {
+		t.Errorf("menu = %q, want the subscription result", sc.Text)
+	}
+}
+
+func TestStartOfActivatedUser(t *testing.T) {
+	tc := newTestConversation(t, 0)
+
+	const userID = 1
+	tc.services.registered[userID] = true
+	tc.services.activated[userID] = true
+
+	// The session of the activated user is lost, the state is initial
+	state := tc.send(t, userID, "/start sub_7")
+	if state.State != states.StateMenu {
+		t.Errorf("state = %s, want menu", states.Name(state.State))
+	}
+	if !slices.Equal(tc.services.subscribed, []int64{7}) {
+		t.Errorf("subscribed = %v, want [7]", tc.services.subscribed)
+	}
+
+	if state := tc.send(t, userID, "/start sub_x"); state.State != states.StateMenu {
+		t.Errorf("state = %s, want menu", states.Name(state.State))
+	}
+	if sc := tc.messenger.lastScreen(t); !strings.Contains(sc.Text, tc.l.T("subscribe.bad_link")) {
+		t.Errorf("menu = %q, want the broken link text", sc.Text)
+	}
+}
+
+func TestStartOfNotActivatedUser(t *testing.T) {
+	tc := newTestConversation(t, 0)
+
+	const userID = 1
+	tc.services.registered[userID] = true
+
+	if state := tc.send(t, userID, "/start"); state.State != states.StateAuthMiddleware {
+		t.Errorf("state = %s, want auth_middleware", states.Name(state.State))
+	}
+	if got, want := tc.lastReply(t), tc.l.T("auth.follow_link"); got != want {
+		t.Errorf("reply = %q, want %q", got, want)
+	}
+}
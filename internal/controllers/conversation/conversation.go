@@ -0,0 +1,193 @@
+package conversation
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/metrics"
+	"github.com/arxonic/gmh/internal/services/auth"
+	"github.com/arxonic/gmh/internal/services/guard"
+)
+
+// Conversation leads users of one messenger through the states of the conversation: registration,
+// the menu, the colleague finder and the admin mode. It knows nothing of the messenger but its Messenger
+type Conversation struct {
+	log       *slog.Logger
+	messenger Messenger
+	catalog   *i18n.Catalog
+	states    *states.States
+
+	// commands are handled before the state machine
+	commands *CommandRouter
+	// fsm checks transitions of the conversation, handlers handle text messages in each state
+	fsm      *states.Machine
+	handlers map[int]stateHandler
+
+	uf  UserFinder
+	ua  UserAuther
+	dk  DataKeeper
+	lim Limiter
+	lk  LanguageKeeper
+}
+
+// New returns the conversation held by the messenger, user states are kept by s.
+// Texts are taken from the catalog in the language of the user
+func New(log *slog.Logger, messenger Messenger, catalog *i18n.Catalog, s *states.States, uf UserFinder, ua UserAuther, emp Employer, dk DataKeeper, adm Administrator, lim Limiter, lk LanguageKeeper, ki KeyIssuer) *Conversation {
+	c := &Conversation{
+		log:       log,
+		messenger: messenger,
+		catalog:   catalog,
+		states:    s,
+		commands:  defaultCommands(uf, ua, lk, ki, messenger.Type(), catalog),
+		fsm:       states.NewConversation(log),
+		uf:        uf,
+		ua:        ua,
+		dk:        dk,
+		lim:       lim,
+		lk:        lk,
+	}
+	c.handlers = c.stateHandlers(uf, ua, emp, dk, adm, lim)
+
+	registerSessionsMetric(s)
+
+	return c
+}
+
+// Commands returns the slash commands, messengers with a command menu show them there
+func (c *Conversation) Commands() []Command {
+	return c.commands.commands
+}
+
+// stateHandler handles a text message in the state and returns the next state of the user
+type stateHandler func(m Message, state *states.UserState, l i18n.Localizer) (int, error)
+
+// stateHandlers returns the handler of each state of the conversation
+func (c *Conversation) stateHandlers(uf UserFinder, ua UserAuther, emp Employer, dk DataKeeper, adm Administrator, lim Limiter) map[int]stateHandler {
+	return map[int]stateHandler{
+		states.StateAuthMiddleware: func(m Message, state *states.UserState, l i18n.Localizer) (int, error) {
+			return c.Auth(m, ua, state, l)
+		},
+		states.StateEmailWait: func(m Message, state *states.UserState, l i18n.Localizer) (int, error) {
+			return c.EmailWait(m, emp, ua, lim, state, l)
+		},
+		states.StateMenu: func(m Message, state *states.UserState, l i18n.Localizer) (int, error) {
+			return c.MenuHandler(m, uf, dk, adm, state, l)
+		},
+		states.StateDeleteConfirm: func(m Message, state *states.UserState, l i18n.Localizer) (int, error) {
+			return c.DeleteConfirmHandler(m, dk, state, l)
+		},
+		states.StateAdmin: func(m Message, state *states.UserState, l i18n.Localizer) (int, error) {
+			return c.AdminHandler(m, adm, state, l)
+		},
+		states.StateFind: func(m Message, state *states.UserState, l i18n.Localizer) (int, error) {
+			return c.FinderHandler(m, uf, state, l)
+		},
+	}
+}
+
+// HandleMessage handles a text message of the user: a command or the input of the current state
+func (c *Conversation) HandleMessage(m Message) {
+	if err := c.lim.Allow(guard.KindMessengerUser, c.subject(m.UserID)); err != nil {
+		// Reply once when the lockout starts, following messages are dropped silently
+		if errors.Is(err, guard.ErrLimited) {
+			state, _ := c.states.Load(m.UserID)
+			c.messenger.Reply(m, c.localizer(m.LanguageCode, state.Language).T("error.too_many_messages"))
+		}
+		return
+	}
+
+	// Инициализация состояния пользователя, если его еще нет
+	state, ok := c.states.Load(m.UserID)
+	if !ok {
+		// Not registered users have no saved language
+		lang, _ := c.lk.Language(c.messenger.Type(), m.UserID)
+		state = states.UserState{State: c.fsm.Initial(), Language: lang}
+	}
+	// Handlers change the copy of the state, it is stored when the message is handled
+	defer func() {
+		c.states.Store(m.UserID, state)
+	}()
+
+	l := c.localizer(m.LanguageCode, state.Language)
+
+	if c.deactivated(m.UserID, m.ChatID, &state) {
+		c.fsm.Transition(&state, states.StateAuthMiddleware)
+	}
+
+	if c.expire(&state) {
+		c.messenger.Reply(m, l.T("session.expired"))
+	}
+
+	stateName := states.Name(state.State)
+	updatesTotal.Inc(stateName)
+	defer func(start time.Time) {
+		handlerDuration.Observe(metrics.Since(start), stateName)
+	}(time.Now())
+
+	if cmd, payload, ok := c.commands.Match(m.Text, state.State); ok {
+		c.fsm.Transition(&state, cmd.Handler(c.commandContext(m, &state, payload)))
+		return
+	}
+
+	handle, ok := c.handlers[state.State]
+	if !ok {
+		return
+	}
+
+	next, err := handle(m, &state, l)
+	if err != nil {
+		return
+	}
+
+	c.fsm.Transition(&state, next)
+}
+
+// expire returns the user with an idle session from any activated state to the menu, reports whether
+// the state was reset. The caller tells the user about it and handles the update in the menu.
+// Not activated users go on with the registration, the idle menu without finder progress has nothing to reset
+func (c *Conversation) expire(state *states.UserState) bool {
+	if !state.Expired || !slices.Contains(activatedStates, state.State) {
+		return false
+	}
+
+	f := state.Finder
+	if state.State == states.StateMenu && len(f.Path()) == 0 && len(f.Options) == 0 && f.UserID == 0 {
+		return false
+	}
+
+	state.Finder = states.FindState{}
+	c.fsm.Transition(state, states.StateMenu)
+
+	return true
+}
+
+// deactivated reports whether the account of the user in an activated state is deactivated or deleted since
+// the session began. Sessions outlive the activation, so it is checked on every update
+func (c *Conversation) deactivated(userID, chatID int64, state *states.UserState) bool {
+	if !slices.Contains(activatedStates, state.State) {
+		return false
+	}
+
+	isActivated, err := c.ua.IsActivated(c.messenger.Type(), userID, chatID)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return true
+	}
+
+	// Storage failures are logged by the service, the session goes on
+	return err == nil && !isActivated
+}
+
+// subject is the rate limited subject of the messenger user
+func (c *Conversation) subject(userID int64) string {
+	return c.messenger.Type() + ":" + strconv.FormatInt(userID, 10)
+}
+
+// localizer returns the localizer of the language chosen by the user or, if it is empty, of the messenger client
+func (c *Conversation) localizer(clientLang, lang string) i18n.Localizer {
+	return c.catalog.Localizer(lang, clientLang)
+}
@@ -0,0 +1,422 @@
+package conversation
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/auth"
+)
+
+// fakeMessenger records everything sent to the user
+type fakeMessenger struct {
+	replies []string
+	screens []Screen
+	files   []string
+	lastID  int
+}
+
+func (f *fakeMessenger) Type() string { return "fake" }
+
+func (f *fakeMessenger) Reply(_ Message, text string) error {
+	f.replies = append(f.replies, text)
+	return nil
+}
+
+func (f *fakeMessenger) ShowScreen(_ int64, sc Screen) (int, error) {
+	f.lastID++
+	f.screens = append(f.screens, sc)
+	return f.lastID, nil
+}
+
+func (f *fakeMessenger) ReplaceScreen(_ int64, _ int, sc Screen) error {
+	f.screens = append(f.screens, sc)
+	return nil
+}
+
+func (f *fakeMessenger) SendFile(_ int64, name string, _ []byte) error {
+	f.files = append(f.files, name)
+	return nil
+}
+
+// lastScreen returns the text of the last shown screen
+func (f *fakeMessenger) lastScreen(t *testing.T) Screen {
+	t.Helper()
+
+	if len(f.screens) == 0 {
+		t.Fatal("no screen shown")
+	}
+
+	return f.screens[len(f.screens)-1]
+}
+
+// services are the services of the conversation but the admin ones
+type services struct {
+	// employees are found by email, activated are messenger IDs of activated accounts
+	employees  map[string]models.Emp
+	registered map[int64]bool
+	activated  map[int64]bool
+	subscribed []int64
+	resent     int
+	// registrations are the saved accounts, registerErr fails the registration
+	registrations int
+	registerErr   error
+	// findErr fails the finder, noOptions makes it find nothing
+	findErr   error
+	noOptions bool
+}
+
+func newServices() *services {
+	return &services{
+		employees:  make(map[string]models.Emp),
+		registered: make(map[int64]bool),
+		activated:  make(map[int64]bool),
+	}
+}
+
+func (s *services) RegisterNewUser(_ models.User, m models.UserMessenger, _ models.Organization) (int64, error) {
+	if s.registerErr != nil {
+		return 0, s.registerErr
+	}
+	if s.registered[m.MessengerID] {
+		return 0, auth.ErrMessengerExists
+	}
+	s.registered[m.MessengerID] = true
+	s.registrations++
+	return 1, nil
+}
+
+func (s *services) IsActivated(_ string, messengerID, _ int64) (bool, error) {
+	if !s.registered[messengerID] {
+		return false, errors.New("not found")
+	}
+	return s.activated[messengerID], nil
+}
+
+func (s *services) ResendActivation(_ string, messengerID, _ int64) error {
+	if !s.registered[messengerID] {
+		return errors.New("not found")
+	}
+	s.resent++
+	return nil
+}
+
+func (s *services) AllowedDomain(string) bool { return true }
+
+func (s *services) Employee(email string) (models.Emp, error) {
+	emp, ok := s.employees[email]
+	if !ok {
+		return models.Emp{}, errors.New("not found")
+	}
+	return emp, nil
+}
+
+func (s *services) Export(string, int64) ([]byte, error) { return []byte("{}"), nil }
+func (s *services) Delete(string, int64) error           { return nil }
+
+func (s *services) User(int64) (models.User, error) { return models.User{}, nil }
+func (s *services) UsersByOrgID(int64) ([]models.User, error) {
+	return []models.User{{ID: 7, LastName: "Ivanov", FirstName: "Ivan"}}, nil
+}
+
+// FindUser returns two choices of each step and the organization ID for the full path
+func (s *services) FindUser(path ...string) ([]string, error) {
+	if s.findErr != nil || s.noOptions {
+		return nil, s.findErr
+	}
+	if len(path) == 4 {
+		return []string{"5"}, nil
+	}
+	return []string{"A", "B"}, nil
+}
+
+func (s *services) Subscribe(_ string, _, uID int64) error {
+	s.subscribed = append(s.subscribed, uID)
+	return nil
+}
+
+func (s *services) UserIDByMessengerID(string, int64) (int64, error) { return 1, nil }
+func (s *services) Subscriptions(int64) ([]models.Birthday, error)   { return nil, nil }
+func (s *services) Unsubscribe(int64, int64) error                   { return nil }
+
+func (s *services) Allow(string, string) error { return nil }
+
+func (s *services) Language(string, int64) (string, error)  { return "", nil }
+func (s *services) SetLanguage(string, int64, string) error { return nil }
+
+func (s *services) IssuePersonal(uID int64) (string, models.APIKey, error) {
+	return "gmh_personal", models.APIKey{Name: "personal", CreatedBy: uID}, nil
+}
+
+// admins is the admin service with one admin if adminID is set. Methods not used by tests are not
+// implemented, the embedded nil Administrator panics if they are called
+type admins struct {
+	Administrator
+
+	adminID   int64
+	revokeErr error
+}
+
+func (a *admins) AdminByMessengerID(string, int64) (int64, error) {
+	if a.adminID == 0 {
+		return 0, errors.New("not admin")
+	}
+	return a.adminID, nil
+}
+
+func (a *admins) RevokeAPIKey(int64, int64) error { return a.revokeErr }
+
+// testConversation is the conversation of the fake messenger with states kept in memory
+type testConversation struct {
+	*Conversation
+	messenger *fakeMessenger
+	services  *services
+	admins    *admins
+	states    *states.States
+	l         i18n.Localizer
+}
+
+func newTestConversation(t *testing.T, ttl time.Duration) *testConversation {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	catalog, err := i18n.Load("en", i18n.Args{"company": "ACME"}, "")
+	if err != nil {
+		t.Fatalf("load catalog: %v", err)
+	}
+
+	fm := &fakeMessenger{}
+	svc := newServices()
+	adm := &admins{}
+	s := states.NewStates(log, states.NewMemoryStore(), ttl, 0)
+
+	return &testConversation{
+		Conversation: New(log, fm, catalog, s, svc, svc, svc, svc, adm, svc, svc, svc),
+		messenger:    fm,
+		services:     svc,
+		admins:       adm,
+		states:       s,
+		l:            catalog.Localizer("en"),
+	}
+}
+
+// send handles the text from the user and returns the state after it
+func (tc *testConversation) send(t *testing.T, userID int64, text string) states.UserState {
+	t.Helper()
+
+	tc.HandleMessage(Message{ChatID: userID, UserID: userID, Text: text, LanguageCode: "en"})
+
+	state, ok := tc.states.Load(userID)
+	if !ok {
+		t.Fatal("state is not stored")
+	}
+
+	return state
+}
+
+// lastReply returns the last text reply
+func (tc *testConversation) lastReply(t *testing.T) string {
+	t.Helper()
+
+	if len(tc.messenger.replies) == 0 {
+		t.Fatal("no reply sent")
+	}
+
+	return tc.messenger.replies[len(tc.messenger.replies)-1]
+}
+
+// TestEmailWaitSameForAnyEmail checks that replies and states don't tell whether the email belongs to an employee
+func TestEmailWaitSameForAnyEmail(t *testing.T) {
+	tc := newTestConversation(t, 0)
+	tc.services.employees["known@example.com"] = models.Emp{}
+
+	const known, unknown = 1, 2
+
+	for _, userID := range []int64{known, unknown} {
+		tc.send(t, userID, "hello")
+	}
+
+	for _, step := range []struct{ known, unknown string }{
+		{"known@example.com", "unknown@example.com"},
+		{"again", "again"},
+		{"/resend", "/resend"},
+	} {
+		knownState := tc.send(t, known, step.known)
+		knownReply := tc.lastReply(t)
+		unknownState := tc.send(t, unknown, step.unknown)
+		unknownReply := tc.lastReply(t)
+
+		if knownState.State != unknownState.State || knownReply != unknownReply {
+			t.Errorf("%q: known email gives %s %q, unknown gives %s %q", step.known,
+				states.Name(knownState.State), knownReply, states.Name(unknownState.State), unknownReply)
+		}
+	}
+
+	if !tc.services.registered[known] || tc.services.registered[unknown] {
+		t.Errorf("registered = %v, want only the known employee", tc.services.registered)
+	}
+	if tc.services.resent != 1 {
+		t.Errorf("resent = %d, want 1 link to the registered account", tc.services.resent)
+	}
+
+	// The link from the email activates the account, the next message opens the menu
+	tc.services.activated[known] = true
+	if state := tc.send(t, known, "hi"); state.State != states.StateMenu {
+		t.Errorf("state after activation = %s, want menu", states.Name(state.State))
+	}
+}
+
+func TestEmailWaitRegistersOnce(t *testing.T) {
+	tc := newTestConversation(t, 0)
+	tc.services.employees["first@example.com"] = models.Emp{}
+	tc.services.employees["second@example.com"] = models.Emp{}
+
+	const userID = 1
+	tc.send(t, userID, "hello")
+
+	tc.send(t, userID, "first@example.com")
+	state := tc.send(t, userID, "second@example.com")
+
+	if tc.services.registrations != 1 {
+		t.Errorf("registrations = %d, want 1", tc.services.registrations)
+	}
+	if tc.services.resent != 1 {
+		t.Errorf("resent = %d, want the link resent to the registered email", tc.services.resent)
+	}
+	if reply := tc.lastReply(t); state.State != states.StateEmailWait || reply != tc.l.T("auth.email_sent") {
+		t.Errorf("second email gives %s %q, want email wait with the same reply", states.Name(state.State), reply)
+	}
+}
+
+func TestEmailWaitRegistrationFailed(t *testing.T) {
+	tc := newTestConversation(t, 0)
+	tc.services.employees["known@example.com"] = models.Emp{}
+	tc.services.registerErr = errors.New("smtp is down")
+
+	const userID = 1
+	tc.send(t, userID, "hello")
+
+	if state := tc.send(t, userID, "known@example.com"); state.State != states.StateEmailWait {
+		t.Errorf("state = %s, want email wait", states.Name(state.State))
+	}
+	if reply := tc.lastReply(t); reply != tc.l.T("auth.resend_failed") {
+		t.Errorf("reply = %q, want the sending failure", reply)
+	}
+}
+
+// newIdleUser returns the conversation with the activated user in the menu, sessions expire after ttl
+func newIdleUser(t *testing.T, ttl time.Duration) (*testConversation, int64) {
+	t.Helper()
+
+	tc := newTestConversation(t, ttl)
+
+	const userID = 1
+	tc.services.registered[userID] = true
+	tc.services.activated[userID] = true
+	tc.send(t, userID, "hi")
+
+	return tc, userID
+}
+
+func TestExpireIdleMenu(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	tc, userID := newIdleUser(t, ttl)
+
+	time.Sleep(2 * ttl)
+
+	// Nothing to reset, the update is handled as is
+	replies := len(tc.messenger.replies)
+	if state := tc.send(t, userID, "1"); state.State != states.StateFind {
+		t.Errorf("state = %s, want find", states.Name(state.State))
+	}
+	if len(tc.messenger.replies) != replies {
+		t.Errorf("replies = %v, want no session expired note", tc.messenger.replies[replies:])
+	}
+}
+
+func TestExpireFinderMessage(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	tc, userID := newIdleUser(t, ttl)
+
+	tc.send(t, userID, "1")
+	if state := tc.send(t, userID, "A"); state.Finder.Organization != "A" {
+		t.Fatalf("organization = %q, want A", state.Finder.Organization)
+	}
+
+	time.Sleep(2 * ttl)
+
+	// The finder is reset and the message opens it again from the start
+	state := tc.send(t, userID, "1")
+	if reply := tc.lastReply(t); reply != tc.l.T("session.expired") {
+		t.Errorf("reply = %q, want the session expired note", reply)
+	}
+	if state.State != states.StateFind || state.Finder.Organization != "" {
+		t.Errorf("state = %s at %v, want find from the start", states.Name(state.State), state.Finder.Path())
+	}
+}
+
+func TestExpireFinderPress(t *testing.T) {
+	const ttl = 20 * time.Millisecond
+	tc, userID := newIdleUser(t, ttl)
+
+	tc.send(t, userID, "1")
+	press := func(value string) string {
+		state, _ := tc.states.Load(userID)
+		return tc.HandlePress(Press{ChatID: userID, UserID: userID, ScreenID: state.ScreenID, Value: value, LanguageCode: "en"})
+	}
+
+	time.Sleep(2 * ttl)
+
+	// The finder screen is replaced with the menu, its choice is stale
+	if notice := press(choice{actionFind, "0"}.String()); notice != tc.l.T("error.stale") {
+		t.Errorf("notice = %q, want stale", notice)
+	}
+	if sc := tc.messenger.lastScreen(t); !strings.Contains(sc.Text, tc.l.T("session.expired")) {
+		t.Errorf("screen = %q, want the session expired note", sc.Text)
+	}
+
+	time.Sleep(2 * ttl)
+
+	// The menu choice of the replaced screen is handled
+	press(choice{actionMenu, menuFind}.String())
+	if state, _ := tc.states.Load(userID); state.State != states.StateFind {
+		t.Errorf("state = %s, want find", states.Name(state.State))
+	}
+}
+
+func TestDeactivatedUserPress(t *testing.T) {
+	tc, userID := newIdleUser(t, 0)
+	tc.send(t, userID, "1")
+
+	// The admin deactivates the account, the stored session is in the finder
+	tc.services.activated[userID] = false
+
+	state, _ := tc.states.Load(userID)
+	notice := tc.HandlePress(Press{ChatID: userID, UserID: userID, ScreenID: state.ScreenID, Value: choice{actionFind, "0"}.String(), LanguageCode: "en"})
+	if notice != tc.l.T("auth.deactivated") {
+		t.Errorf("notice = %q, want deactivated", notice)
+	}
+	if state, _ := tc.states.Load(userID); state.State != states.StateAuthMiddleware || len(state.Finder.Path()) != 0 {
+		t.Errorf("state = %s at %v, want auth middleware without the finder", states.Name(state.State), state.Finder.Path())
+	}
+}
+
+func TestDeactivatedUserMessage(t *testing.T) {
+	tc, userID := newIdleUser(t, 0)
+	tc.services.activated[userID] = false
+
+	// The finder is not opened, the message is handled by the registration
+	if state := tc.send(t, userID, "1"); state.State != states.StateAuthMiddleware {
+		t.Errorf("state = %s, want auth middleware", states.Name(state.State))
+	}
+	if reply := tc.lastReply(t); reply != tc.l.T("auth.follow_link") {
+		t.Errorf("reply = %q, want the activation link reminder", reply)
+	}
+}
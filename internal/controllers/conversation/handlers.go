@@ -0,0 +1,475 @@
+package conversation
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/lib/email"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/auth"
+	"github.com/arxonic/gmh/internal/services/guard"
+	"github.com/arxonic/gmh/internal/services/subscribe"
+)
+
+type UserAuther interface {
+	RegisterNewUser(models.User, models.UserMessenger, models.Organization) (int64, error)
+	IsActivated(messengerType string, messengerID, chatID int64) (bool, error)
+	ResendActivation(messengerType string, messengerID, chatID int64) error
+}
+
+func (c *Conversation) Auth(m Message, ua UserAuther, state *states.UserState, l i18n.Localizer) (int, error) {
+	isActivated, err := ua.IsActivated(c.messenger.Type(), m.UserID, m.ChatID)
+	if err != nil {
+		// if New user
+		c.messenger.Reply(m, l.T("auth.greeting"))
+		return states.StateEmailWait, nil
+	}
+	if isActivated {
+		c.welcome(m, state, l)
+		return states.StateMenu, nil
+	} else if m.Text == "/resend" {
+		if err := ua.ResendActivation(c.messenger.Type(), m.UserID, m.ChatID); err != nil {
+			c.messenger.Reply(m, l.T("auth.resend_failed"))
+			return states.StateAuthMiddleware, nil
+		}
+		c.messenger.Reply(m, l.T("auth.resent"))
+		return states.StateAuthMiddleware, nil
+	} else {
+		// if user not follow auth link
+		c.messenger.Reply(m, l.T("auth.follow_link"))
+		return states.StateAuthMiddleware, nil
+	}
+}
+
+// welcome shows the menu to the user with the activated account and follows the deep link of /start
+// sent before the activation
+func (c *Conversation) welcome(m Message, state *states.UserState, l i18n.Localizer) {
+	text := l.T("auth.hello")
+	if link := startLinkText(l, c.uf, c.messenger.Type(), m.UserID, state.StartPayload); link != "" {
+		text += "\n\n" + link
+	}
+	state.StartPayload = ""
+
+	c.showScreen(m.ChatID, state, menuScreen(l, text))
+}
+
+type Limiter interface {
+	Allow(kind, subject string) error
+}
+
+type Employer interface {
+	AllowedDomain(email string) bool
+	Employee(email string) (models.Emp, error)
+}
+
+// EmailWait registers the user by the corporate email. Every accepted email is answered the same and keeps
+// the user in the state, whether the employee is found or not, so neither this reply nor the next ones
+// tell if the email belongs to an employee. Only a failure to send the email is reported. The messenger
+// account is registered once, further emails resend the link. The user leaves the state when the account
+// is activated by the link from the email
+func (c *Conversation) EmailWait(m Message, emp Employer, ua UserAuther, lim Limiter, state *states.UserState, l i18n.Localizer) (int, error) {
+	if isActivated, err := ua.IsActivated(c.messenger.Type(), m.UserID, m.ChatID); err == nil && isActivated {
+		c.welcome(m, state, l)
+		return states.StateMenu, nil
+	}
+
+	if m.Text == "/resend" {
+		// Only registered accounts get a new link, the reply is the same for everybody
+		ua.ResendActivation(c.messenger.Type(), m.UserID, m.ChatID)
+		c.messenger.Reply(m, l.T("auth.email_sent"))
+		return states.StateEmailWait, nil
+	}
+
+	e := m.Text
+	if !email.Valid(e) {
+		c.messenger.Reply(m, l.T("auth.email_invalid"))
+		return states.StateEmailWait, nil
+	}
+
+	if err := lim.Allow(guard.KindRegistration, c.subject(m.UserID)); err != nil {
+		c.messenger.Reply(m, l.T("auth.too_many_attempts"))
+		return states.StateEmailWait, nil
+	}
+
+	if !emp.AllowedDomain(e) {
+		c.messenger.Reply(m, l.T("auth.domain_not_allowed"))
+		return states.StateEmailWait, nil
+	}
+
+	// Employer API request. Failures are logged by the services
+	if employee, err := emp.Employee(e); err == nil {
+		// Convert Employer response to User model
+		user, org := models.EmpToUser(employee)
+		mess := models.UserMessenger{
+			MessengerType: c.messenger.Type(),
+			MessengerID:   m.UserID,
+			ChatID:        m.ChatID,
+			// The activation email is sent in the language of the messenger client
+			ClientLanguage: m.LanguageCode,
+		}
+
+		_, err := ua.RegisterNewUser(user, mess, org)
+		if errors.Is(err, auth.ErrMessengerExists) {
+			// The account registered with another email gets the link to that email, the reply is the same
+			err = ua.ResendActivation(c.messenger.Type(), m.UserID, m.ChatID)
+		}
+
+		switch {
+		case errors.Is(err, guard.ErrLimited):
+			c.messenger.Reply(m, l.T("auth.too_many_attempts"))
+			return states.StateEmailWait, nil
+		case err != nil:
+			c.messenger.Reply(m, l.T("auth.resend_failed"))
+			return states.StateEmailWait, nil
+		}
+	}
+
+	c.messenger.Reply(m, l.T("auth.email_sent"))
+
+	return states.StateEmailWait, nil
+}
+
+type DataKeeper interface {
+	Export(messengerType string, messengerID int64) ([]byte, error)
+	Delete(messengerType string, messengerID int64) error
+}
+
+// exportFile is the name of the file with the exported data
+const exportFile = "gmh-export.json"
+
+// MenuHandler handles text commands in the menu, choices of the menu are handled by HandlePress
+func (c *Conversation) MenuHandler(m Message, uf UserFinder, dk DataKeeper, adm Administrator, state *states.UserState, l i18n.Localizer) (int, error) {
+	switch m.Text {
+	case "1":
+		sc, next := c.finderResume(l, uf, &state.Finder)
+		c.showScreen(m.ChatID, state, sc)
+		return next, nil
+
+	case "2":
+		c.showScreen(m.ChatID, state, subscriptionsScreen(l, c.messenger.Type(), m.UserID, uf))
+		return states.StateMenu, nil
+
+	case "3", "/export":
+		data, err := dk.Export(c.messenger.Type(), m.UserID)
+		if err != nil {
+			c.messenger.Reply(m, l.T("export.failed"))
+			return states.StateMenu, nil
+		}
+
+		c.messenger.SendFile(m.ChatID, exportFile, data)
+		return states.StateMenu, nil
+
+	case "4", "/delete":
+		c.messenger.Reply(m, deleteConfirmText(l))
+		return states.StateDeleteConfirm, nil
+
+	case "/admin":
+		if _, err := adm.AdminByMessengerID(c.messenger.Type(), m.UserID); err != nil {
+			c.messenger.Reply(m, l.T("error.unknown_command"))
+			return states.StateMenu, nil
+		}
+
+		c.messenger.Reply(m, l.T("admin.menu"))
+		return states.StateAdmin, nil
+	default:
+		c.showScreen(m.ChatID, state, menuScreen(l, l.T("error.unknown_command")))
+		return states.StateMenu, nil
+	}
+}
+
+// deleteConfirmText asks to type the confirmation word of the user language
+func deleteConfirmText(l i18n.Localizer) string {
+	return l.T("delete.confirm", i18n.Args{"word": l.T("delete.word")})
+}
+
+func (c *Conversation) DeleteConfirmHandler(m Message, dk DataKeeper, state *states.UserState, l i18n.Localizer) (int, error) {
+	if m.Text != l.T("delete.word") {
+		c.showScreen(m.ChatID, state, menuScreen(l, l.T("delete.cancelled")))
+		return states.StateMenu, nil
+	}
+
+	if err := dk.Delete(c.messenger.Type(), m.UserID); err != nil {
+		c.messenger.Reply(m, l.T("delete.failed"))
+		return states.StateMenu, nil
+	}
+
+	// The session is reset when the user enters StateAuthMiddleware
+	c.messenger.Reply(m, l.T("delete.done"))
+
+	return states.StateAuthMiddleware, nil
+}
+
+type UserFinder interface {
+	User(id int64) (models.User, error)
+	UsersByOrgID(id int64) ([]models.User, error)
+	FindUser(...string) ([]string, error)
+	Subscribe(messengerType string, messengerID, uID int64) error
+	UserIDByMessengerID(messengerType string, id int64) (int64, error)
+	Subscriptions(subID int64) ([]models.Birthday, error)
+	Unsubscribe(subID, uID int64) error
+}
+
+// subscriptionsScreen lists subscriptions of the messenger account owner with choices to unsubscribe
+func subscriptionsScreen(l i18n.Localizer, messengerType string, messengerID int64, uf UserFinder) Screen {
+	uID, err := uf.UserIDByMessengerID(messengerType, messengerID)
+	if err != nil {
+		return menuScreen(l, l.T("error.server"))
+	}
+
+	birthdays, err := uf.Subscriptions(uID)
+	if err != nil {
+		return menuScreen(l, l.T("error.server"))
+	}
+
+	if len(birthdays) == 0 {
+		return menuScreen(l, l.T("subscriptions.empty"))
+	}
+
+	lines := make([]string, 0, len(birthdays)+1)
+	lines = append(lines, l.T("subscriptions.title"))
+	rows := make([][]Choice, 0, len(birthdays)+1)
+	for _, bd := range birthdays {
+		name := bd.User.LastName + " " + bd.User.FirstName
+		lines = append(lines, l.T("subscriptions.item", i18n.Args{"name": name, "date": bd.Date.Format("02.01"), i18n.CountArg: bd.DaysLeft}))
+
+		c := choice{actionUnsubscribe, strconv.FormatInt(bd.User.ID, 10)}
+		rows = append(rows, []Choice{option(l.T("subscriptions.unsubscribe", i18n.Args{"name": name}), c)})
+	}
+	rows = append(rows, []Choice{option(l.T("menu.back"), choice{actionMenu, menuOpen})})
+
+	return Screen{Text: strings.Join(lines, "\n"), Choices: rows}
+}
+
+// FinderHandler handles finder choices typed as text, choices of the finder are handled by HandlePress
+func (c *Conversation) FinderHandler(m Message, uf UserFinder, state *states.UserState, l i18n.Localizer) (int, error) {
+	sc, next := c.finderStep(l, uf, m.UserID, &state.Finder, m.Text)
+	c.showScreen(m.ChatID, state, sc)
+
+	return next, nil
+}
+
+// Finder controls, the arg of actionFind besides the option index
+const (
+	findBack   = "back"
+	findCancel = "cancel"
+)
+
+var errNoOptions = errors.New("no finder options")
+
+// finderResume shows the current step of the finder if the user left it for the menu, otherwise starts it
+func (c *Conversation) finderResume(l i18n.Localizer, uf UserFinder, f *states.FindState) (Screen, int) {
+	if len(f.Path()) > 0 && len(f.Options) > 0 {
+		return finderScreen(l, f, l.T(finderPrompt(f))), states.StateFind
+	}
+
+	return c.finderStart(l, uf, f)
+}
+
+// finderStart resets the finder and shows organizations
+func (c *Conversation) finderStart(l i18n.Localizer, uf UserFinder, f *states.FindState) (Screen, int) {
+	*f = states.FindState{}
+
+	options, err := c.finderOptions(uf, f)
+	if errors.Is(err, errNoOptions) {
+		return menuScreen(l, l.T("finder.failed")), states.StateMenu
+	}
+	if err != nil {
+		return menuScreen(l, l.T("error.server")), states.StateMenu
+	}
+
+	f.Options = options
+
+	return finderScreen(l, f, l.T(finderPrompt(f))), states.StateFind
+}
+
+// finderStep applies the choice to the current step of the finder: organization, city, office,
+// department and then the user to subscribe to. Returns the next screen and state
+func (c *Conversation) finderStep(l i18n.Localizer, uf UserFinder, messengerID int64, f *states.FindState, choice string) (Screen, int) {
+	if f.Department != "" {
+		uID, err := strconv.ParseInt(strings.Fields(choice + " ")[0], 10, 64)
+		if err != nil {
+			return finderScreen(l, f, l.T("finder.bad_colleague")), states.StateFind
+		}
+
+		if err := uf.Subscribe(c.messenger.Type(), messengerID, uID); err != nil {
+			return finderScreen(l, f, subscribeText(l, err)), states.StateFind
+		}
+
+		*f = states.FindState{}
+
+		return menuScreen(l, subscribeText(l, nil)), states.StateMenu
+	}
+
+	next := *f
+	switch {
+	case next.Organization == "":
+		next.Organization = choice
+	case next.City == "":
+		next.City = choice
+	case next.Office == "":
+		next.Office = choice
+	default:
+		next.Department = choice
+	}
+
+	options, err := c.finderOptions(uf, &next)
+	if errors.Is(err, errNoOptions) {
+		return finderScreen(l, f, l.T("finder.not_found")), states.StateFind
+	}
+	if err != nil {
+		return finderScreen(l, f, l.T("error.server")), states.StateFind
+	}
+
+	*f = next
+	f.Options = options
+
+	return finderScreen(l, f, l.T(finderPrompt(f))), states.StateFind
+}
+
+// finderBack returns the finder to the previous step, from the first step to the menu
+func (c *Conversation) finderBack(l i18n.Localizer, uf UserFinder, f *states.FindState) (Screen, int) {
+	switch {
+	case f.Department != "":
+		f.Department = ""
+	case f.Office != "":
+		f.Office = ""
+	case f.City != "":
+		f.City = ""
+	case f.Organization != "":
+		f.Organization = ""
+	default:
+		*f = states.FindState{}
+		return menuScreen(l, ""), states.StateMenu
+	}
+
+	options, err := c.finderOptions(uf, f)
+	if err != nil {
+		*f = states.FindState{}
+		if errors.Is(err, errNoOptions) {
+			return menuScreen(l, l.T("finder.failed")), states.StateMenu
+		}
+		return menuScreen(l, l.T("error.server")), states.StateMenu
+	}
+
+	f.Options = options
+
+	return finderScreen(l, f, l.T(finderPrompt(f))), states.StateFind
+}
+
+// finderOptions loads the choices of the current step. Organizations, cities, offices and departments
+// are found by the path chosen before, colleagues are the users of the chosen department.
+// Returns errNoOptions if there is nothing to choose, storage failures are logged
+func (c *Conversation) finderOptions(uf UserFinder, f *states.FindState) ([]states.Option, error) {
+	const fn = "conversation.finderOptions"
+
+	log := c.log.With(slog.String("fn", fn))
+
+	values, err := uf.FindUser(f.Path()...)
+	if err != nil {
+		log.Error("failed to find organizations", sl.Err(err))
+		return nil, err
+	}
+	if len(values) < 1 {
+		return nil, errNoOptions
+	}
+
+	if f.Department == "" {
+		return textOptions(values), nil
+	}
+
+	id, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		log.Error("failed to parse organization id", sl.Err(err))
+		return nil, err
+	}
+
+	users, err := uf.UsersByOrgID(id)
+	if err != nil {
+		log.Error("failed to get users of organization", slog.Int64("org_id", id), sl.Err(err))
+		return nil, err
+	}
+	if len(users) < 1 {
+		return nil, errNoOptions
+	}
+
+	options := make([]states.Option, 0, len(users))
+	for _, u := range users {
+		options = append(options, states.Option{
+			Label: strings.TrimSpace(fmt.Sprintf("%s %s %s", u.LastName, u.FirstName, u.Patronymic)),
+			Value: strconv.FormatInt(u.ID, 10),
+		})
+	}
+
+	return options, nil
+}
+
+// finderPrompt returns the message key asking for the choice of the current step
+func finderPrompt(f *states.FindState) string {
+	switch {
+	case f.Organization == "":
+		return "finder.organization"
+	case f.City == "":
+		return "finder.city"
+	case f.Office == "":
+		return "finder.office"
+	case f.Department == "":
+		return "finder.department"
+	default:
+		return "finder.colleague"
+	}
+}
+
+// finderScreen shows the chosen path above the text, the options and the finder controls
+func finderScreen(l i18n.Localizer, f *states.FindState, text string) Screen {
+	if path := f.Path(); len(path) > 0 {
+		text = l.T("finder.path", i18n.Args{"path": strings.Join(path, " › ")}) + "\n\n" + text
+	}
+
+	rows := make([][]Choice, 0, len(f.Options)+2)
+	for i, o := range f.Options {
+		rows = append(rows, []Choice{option(o.Label, choice{actionFind, strconv.Itoa(i)})})
+	}
+	rows = append(rows,
+		[]Choice{
+			option(l.T("finder.back"), choice{actionFind, findBack}),
+			option(l.T("finder.cancel"), choice{actionFind, findCancel}),
+		},
+		[]Choice{option(l.T("menu.back"), choice{actionMenu, menuOpen})},
+	)
+
+	return Screen{Text: text, Choices: rows}
+}
+
+// subscribeText describes the result of the subscription
+func subscribeText(l i18n.Localizer, err error) string {
+	switch {
+	case err == nil:
+		return l.T("subscribe.done")
+	case errors.Is(err, subscribe.ErrAlreadySubscribed):
+		return l.T("subscribe.already")
+	case errors.Is(err, subscribe.ErrSelfSubscription):
+		return l.T("subscribe.self")
+	case errors.Is(err, subscribe.ErrUserNotFound):
+		return l.T("subscribe.user_not_found")
+	case errors.Is(err, subscribe.ErrNotActivated):
+		return l.T("subscribe.not_activated")
+	default:
+		return l.T("error.server")
+	}
+}
+
+// textOptions makes options shown and chosen by the same text
+func textOptions(values []string) []states.Option {
+	options := make([]states.Option, 0, len(values))
+	for _, v := range values {
+		options = append(options, states.Option{Label: v, Value: v})
+	}
+
+	return options
+}
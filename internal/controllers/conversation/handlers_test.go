@@ -0,0 +1,66 @@
+package conversation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/services/subscribe"
+)
+
+func TestFinderErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		findErr   error
+		noOptions bool
+		want      string
+	}{
+		{name: "storage failure", findErr: errors.New("database is locked"), want: "error.server"},
+		{name: "nothing found", noOptions: true, want: "finder.failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestConversation(t, 0)
+
+			const userID = 1
+			tc.services.registered[userID] = true
+			tc.services.activated[userID] = true
+			tc.send(t, userID, "hi")
+
+			tc.services.findErr = tt.findErr
+			tc.services.noOptions = tt.noOptions
+
+			if state := tc.send(t, userID, "1"); state.State != states.StateMenu {
+				t.Errorf("state = %s, want menu", states.Name(state.State))
+			}
+			if sc := tc.messenger.lastScreen(t); !strings.Contains(sc.Text, tc.l.T(tt.want)) {
+				t.Errorf("screen = %q, want %s text", sc.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeText(t *testing.T) {
+	tc := newTestConversation(t, 0)
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "subscribe.done"},
+		{subscribe.ErrAlreadySubscribed, "subscribe.already"},
+		{subscribe.ErrSelfSubscription, "subscribe.self"},
+		{subscribe.ErrUserNotFound, "subscribe.user_not_found"},
+		{fmt.Errorf("subscribe.SubscribeUser:%w", subscribe.ErrNotActivated), "subscribe.not_activated"},
+		{errors.New("database is locked"), "error.server"},
+	}
+
+	for _, tt := range tests {
+		if got := subscribeText(tc.l, tt.err); got != tc.l.T(tt.want) {
+			t.Errorf("subscribeText(%v) = %q, want %s", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package conversation
+
+import "errors"
+
+var ErrChatUnreachable = errors.New("chat is unreachable, the user blocked the bot")
+
+// Messenger is the adapter of a messenger the conversation is held in. Screens are texts with choices,
+// the value of the pressed choice comes back as a Press
+type Messenger interface {
+	// Type is the messenger_type of accounts of the messenger
+	Type() string
+	// Reply sends the text to the chat of the message
+	Reply(m Message, text string) error
+	// ShowScreen sends the screen as a new message and returns its ID
+	ShowScreen(chatID int64, sc Screen) (int, error)
+	// ReplaceScreen shows the screen in place of the message with the ID
+	ReplaceScreen(chatID int64, screenID int, sc Screen) error
+	SendFile(chatID int64, name string, data []byte) error
+}
+
+// Message is a text message of the user
+type Message struct {
+	ID     int
+	ChatID int64
+	UserID int64
+	Text   string
+	// LanguageCode is the language of the user's messenger client
+	LanguageCode string
+}
+
+// Press is a choice of a screen made by the user
+type Press struct {
+	ChatID int64
+	UserID int64
+	// ScreenID is the ID of the screen message, 0 if it is unknown
+	ScreenID     int
+	Value        string
+	LanguageCode string
+}
+
+// Screen is a text with rows of choices, it is replaced in place on navigation
+type Screen struct {
+	Text    string
+	Choices [][]Choice
+}
+
+// Choice is shown by the label, its value is passed back when it is chosen. Values are at most 60 bytes
+type Choice struct {
+	Label string
+	Value string
+}
@@ -0,0 +1,24 @@
+package conversation
+
+import (
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/lib/metrics"
+)
+
+var (
+	updatesTotal = metrics.NewCounterVec("bot_updates_total",
+		"Messenger updates processed by the FSM state of the user.", "state")
+	handlerDuration = metrics.NewHistogramVec("bot_handler_duration_seconds",
+		"Duration of update handling in seconds by the FSM state of the user.", metrics.DefBuckets, "state")
+)
+
+// registerSessionsMetric exposes the number of not expired user sessions by FSM state
+func registerSessionsMetric(s *states.States) {
+	metrics.NewGaugeFunc("bot_sessions", "Active user sessions by FSM state, expired sessions are not counted.", "state", func() map[string]float64 {
+		values := make(map[string]float64)
+		for state, n := range s.CountByState() {
+			values[state] = float64(n)
+		}
+		return values
+	})
+}
@@ -0,0 +1,263 @@
+package conversation
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation/states"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/lib/metrics"
+	"github.com/arxonic/gmh/internal/services/guard"
+	"github.com/arxonic/gmh/internal/services/subscribe"
+)
+
+// Choice actions
+const (
+	actionMenu        = "m" // arg is one of menu* items
+	actionFind        = "f" // arg is the index of the finder option, findBack or findCancel
+	actionUnsubscribe = "u" // arg is the user ID
+)
+
+// Menu items
+const (
+	menuOpen          = "open"
+	menuFind          = "find"
+	menuSubscriptions = "subs"
+	menuExport        = "export"
+	menuDelete        = "delete"
+)
+
+var errBadChoice = errors.New("bad choice value")
+
+// choice is the value of a Choice: "<action>:<arg>"
+type choice struct {
+	action string
+	arg    string
+}
+
+func (c choice) String() string {
+	return c.action + ":" + c.arg
+}
+
+func parseChoice(value string) (choice, error) {
+	action, arg, ok := strings.Cut(value, ":")
+	if !ok {
+		return choice{}, errBadChoice
+	}
+
+	return choice{action: action, arg: arg}, nil
+}
+
+func option(label string, c choice) Choice {
+	return Choice{Label: label, Value: c.String()}
+}
+
+func menuScreen(l i18n.Localizer, text string) Screen {
+	if text == "" {
+		text = l.T("menu.title")
+	} else {
+		text += "\n\n" + l.T("menu.title")
+	}
+
+	return Screen{
+		Text: text,
+		Choices: [][]Choice{
+			{option(l.T("menu.find"), choice{actionMenu, menuFind})},
+			{option(l.T("menu.subscriptions"), choice{actionMenu, menuSubscriptions})},
+			{option(l.T("menu.export"), choice{actionMenu, menuExport})},
+			{option(l.T("menu.delete"), choice{actionMenu, menuDelete})},
+		},
+	}
+}
+
+// showScreen sends the screen as a new message, choices of previous screens become stale
+func (c *Conversation) showScreen(chatID int64, state *states.UserState, sc Screen) error {
+	id, err := c.messenger.ShowScreen(chatID, sc)
+	if err != nil {
+		return err
+	}
+
+	state.ScreenID = id
+
+	return nil
+}
+
+// replaceScreen replaces the current screen, a new message is sent if it can't be replaced
+func (c *Conversation) replaceScreen(chatID int64, state *states.UserState, sc Screen) error {
+	const fn = "conversation.replaceScreen"
+
+	if err := c.messenger.ReplaceScreen(chatID, state.ScreenID, sc); err != nil {
+		if errors.Is(err, ErrChatUnreachable) {
+			return err
+		}
+
+		c.log.Warn("failed to replace screen, sending a new one", slog.String("fn", fn), sl.Err(err))
+		return c.showScreen(chatID, state, sc)
+	}
+
+	if len(sc.Choices) == 0 {
+		state.ScreenID = 0
+	}
+
+	return nil
+}
+
+// HandlePress handles a choice of a screen and returns a short notice for the user, it may be empty
+func (c *Conversation) HandlePress(p Press) string {
+	const fn = "conversation.HandlePress"
+
+	log := c.log.With(slog.String("fn", fn))
+
+	state, ok := c.states.Load(p.UserID)
+	l := c.localizer(p.LanguageCode, state.Language)
+
+	if err := c.lim.Allow(guard.KindMessengerUser, c.subject(p.UserID)); err != nil {
+		return l.T("error.too_many_requests")
+	}
+
+	// The screens of a deactivated account are stale, the next message is handled by the registration
+	if ok && c.deactivated(p.UserID, p.ChatID, &state) {
+		c.fsm.Transition(&state, states.StateAuthMiddleware)
+		c.states.Store(p.UserID, state)
+		return l.T("auth.deactivated")
+	}
+
+	// The pressed screen may belong to the reset state, the menu replaces it before the press is handled
+	if ok && p.ScreenID != 0 && p.ScreenID == state.ScreenID && c.expire(&state) {
+		if err := c.replaceScreen(p.ChatID, &state, menuScreen(l, l.T("session.expired"))); err != nil {
+			log.Error("failed to show menu of expired session", sl.Err(err))
+		}
+		c.states.Store(p.UserID, state)
+	}
+
+	ch, err := parseChoice(p.Value)
+	if err != nil || !ok || p.ScreenID == 0 || p.ScreenID != state.ScreenID ||
+		(state.State != states.StateMenu && state.State != states.StateFind) {
+		return l.T("error.stale")
+	}
+
+	stateName := states.Name(state.State)
+	updatesTotal.Inc(stateName)
+	defer func(start time.Time) {
+		handlerDuration.Observe(metrics.Since(start), stateName)
+	}(time.Now())
+
+	notice := ""
+
+	switch ch.action {
+	case actionMenu:
+		notice = c.menuPress(p, ch.arg, &state, l)
+
+	case actionFind:
+		if state.State != states.StateFind {
+			return l.T("error.stale")
+		}
+
+		var sc Screen
+		next := state.State
+		switch ch.arg {
+		case findBack:
+			sc, next = c.finderBack(l, c.uf, &state.Finder)
+		case findCancel:
+			state.Finder = states.FindState{}
+			sc, next = menuScreen(l, l.T("finder.cancelled")), states.StateMenu
+		default:
+			i, err := strconv.Atoi(ch.arg)
+			if err != nil || i < 0 || i >= len(state.Finder.Options) {
+				return l.T("error.stale")
+			}
+
+			sc, next = c.finderStep(l, c.uf, p.UserID, &state.Finder, state.Finder.Options[i].Value)
+		}
+
+		c.fsm.Transition(&state, next)
+		if err := c.replaceScreen(p.ChatID, &state, sc); err != nil {
+			log.Error("failed to show finder", sl.Err(err))
+		}
+
+	case actionUnsubscribe:
+		uID, err := strconv.ParseInt(ch.arg, 10, 64)
+		if err != nil {
+			return l.T("error.stale")
+		}
+
+		notice = unsubscribe(l, c.uf, c.messenger.Type(), p.UserID, uID)
+		if err := c.replaceScreen(p.ChatID, &state, subscriptionsScreen(l, c.messenger.Type(), p.UserID, c.uf)); err != nil {
+			log.Error("failed to show subscriptions", sl.Err(err))
+		}
+
+	default:
+		return l.T("error.stale")
+	}
+
+	c.states.Store(p.UserID, state)
+
+	return notice
+}
+
+// menuPress handles menu choices and returns a short notice for the user
+func (c *Conversation) menuPress(p Press, item string, state *states.UserState, l i18n.Localizer) string {
+	const fn = "conversation.menuPress"
+
+	log := c.log.With(slog.String("fn", fn))
+
+	var sc Screen
+	next := state.State
+	switch item {
+	case menuOpen:
+		// The finder is kept to resume from the same step
+		sc, next = menuScreen(l, ""), states.StateMenu
+
+	case menuFind:
+		sc, next = c.finderResume(l, c.uf, &state.Finder)
+
+	case menuSubscriptions:
+		sc = subscriptionsScreen(l, c.messenger.Type(), p.UserID, c.uf)
+
+	case menuExport:
+		data, err := c.dk.Export(c.messenger.Type(), p.UserID)
+		if err != nil {
+			return l.T("export.failed")
+		}
+
+		if err := c.messenger.SendFile(p.ChatID, exportFile, data); err != nil {
+			log.Error("failed to send export", sl.Err(err))
+			return l.T("export.failed")
+		}
+		return l.T("export.done")
+
+	case menuDelete:
+		sc, next = Screen{Text: deleteConfirmText(l)}, states.StateDeleteConfirm
+
+	default:
+		return l.T("error.stale")
+	}
+
+	c.fsm.Transition(state, next)
+	if err := c.replaceScreen(p.ChatID, state, sc); err != nil {
+		log.Error("failed to show screen", slog.String("item", item), sl.Err(err))
+	}
+
+	return ""
+}
+
+func unsubscribe(l i18n.Localizer, uf UserFinder, messengerType string, messengerID, uID int64) string {
+	subID, err := uf.UserIDByMessengerID(messengerType, messengerID)
+	if err != nil {
+		return l.T("error.server")
+	}
+
+	err = uf.Unsubscribe(subID, uID)
+	switch {
+	case errors.Is(err, subscribe.ErrSubscriptionNotFound):
+		return l.T("subscriptions.already_unsubscribed")
+	case err != nil:
+		return l.T("error.server")
+	}
+
+	return l.T("subscriptions.unsubscribed")
+}
@@ -0,0 +1,64 @@
+package states
+
+import "log/slog"
+
+//go:generate go run ../../../../cmd/fsmchart -out ../../../../conversation.dot
+
+// NewConversation returns the machine of the bot conversation. Commands and screen choices move the user
+// between the same states as text messages. StateEmailSent and StateSubscribe are not used by the conversation
+func NewConversation(log *slog.Logger) *Machine {
+	m := NewMachine(log, StateAuthMiddleware)
+
+	m.Register(State{
+		ID: StateAuthMiddleware,
+		Transitions: []Transition{
+			{To: StateEmailWait, On: "new user"},
+			{To: StateMenu, On: "account activated"},
+		},
+		// The user is not known yet or the account is deleted or deactivated, nothing of the previous session is kept
+		OnEnter: func(s *UserState) {
+			s.Finder = FindState{}
+			s.ScreenID = 0
+			s.Language = ""
+			s.StartPayload = ""
+		},
+	})
+	m.Register(State{
+		ID: StateEmailWait,
+		Transitions: []Transition{
+			{To: StateMenu, On: "account activated"},
+		},
+	})
+	m.Register(State{
+		ID: StateMenu,
+		Transitions: []Transition{
+			{To: StateFind, On: "find colleague"},
+			{To: StateDeleteConfirm, On: "delete account"},
+			{To: StateAdmin, On: "/admin"},
+			{To: StateAuthMiddleware, On: "account deactivated"},
+		},
+	})
+	m.Register(State{
+		ID: StateFind,
+		Transitions: []Transition{
+			{To: StateMenu, On: "subscribed, cancel or menu"},
+			{To: StateAuthMiddleware, On: "account deactivated"},
+		},
+	})
+	m.Register(State{
+		ID: StateDeleteConfirm,
+		Transitions: []Transition{
+			{To: StateAuthMiddleware, On: "deletion confirmed or account deactivated"},
+			{To: StateMenu, On: "not confirmed or /cancel"},
+		},
+	})
+	m.Register(State{
+		ID: StateAdmin,
+		Transitions: []Transition{
+			{To: StateMenu, On: "exit or /cancel"},
+			{To: StateAuthMiddleware, On: "account deactivated"},
+		},
+	})
+
+	return m
+}
@@ -0,0 +1,119 @@
+package states
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var ErrIllegalTransition = errors.New("state transition is not allowed")
+
+// State is a state of the conversation. Transitions list the states the user may move to,
+// staying in the same state is always allowed and calls no hooks
+type State struct {
+	ID          int
+	Transitions []Transition
+	// OnEnter and OnExit are called when the user moves to or from the state, they may change the user state
+	OnEnter func(s *UserState)
+	OnExit  func(s *UserState)
+}
+
+// Transition is an allowed move to the state To, On describes its cause on the diagram
+type Transition struct {
+	To int
+	On string
+}
+
+// Machine checks transitions between registered states and calls their hooks
+type Machine struct {
+	log     *slog.Logger
+	initial int
+	states  map[int]State
+	// order is the order of registration, the diagram is the same for the same machine
+	order []int
+}
+
+// NewMachine returns an empty machine, conversations of new users start in the initial state
+func NewMachine(log *slog.Logger, initial int) *Machine {
+	return &Machine{
+		log:     log,
+		initial: initial,
+		states:  make(map[int]State),
+	}
+}
+
+// Register adds the state, a state registered twice is a programming error
+func (m *Machine) Register(s State) {
+	if _, ok := m.states[s.ID]; ok {
+		panic("states: state " + Name(s.ID) + " is registered twice")
+	}
+
+	m.states[s.ID] = s
+	m.order = append(m.order, s.ID)
+}
+
+// Initial returns the state of new conversations
+func (m *Machine) Initial() int {
+	return m.initial
+}
+
+// Transition moves the user to the state, calling the exit hook of the current state and the enter hook
+// of the next one. Not allowed transitions are logged and rejected, the user stays in the current state
+func (m *Machine) Transition(s *UserState, to int) error {
+	const fn = "states.Transition"
+
+	if s.State == to {
+		return nil
+	}
+
+	from, ok := m.states[s.State]
+	next, known := m.states[to]
+	if !ok || !known || !from.allows(to) {
+		m.log.Warn("illegal state transition", slog.String("fn", fn), slog.String("from", Name(s.State)), slog.String("to", Name(to)))
+		return fmt.Errorf("%s: %s -> %s: %w", fn, Name(s.State), Name(to), ErrIllegalTransition)
+	}
+
+	if from.OnExit != nil {
+		from.OnExit(s)
+	}
+
+	s.State = to
+
+	if next.OnEnter != nil {
+		next.OnEnter(s)
+	}
+
+	return nil
+}
+
+func (s State) allows(to int) bool {
+	for _, t := range s.Transitions {
+		if t.To == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DOT returns the diagram of the machine in the Graphviz DOT language
+func (m *Machine) DOT() string {
+	var sb strings.Builder
+
+	sb.WriteString("digraph conversation {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\tnode [shape=box, style=rounded];\n")
+	sb.WriteString("\tstart [shape=point];\n")
+	fmt.Fprintf(&sb, "\tstart -> %s;\n", Name(m.initial))
+
+	for _, id := range m.order {
+		for _, t := range m.states[id].Transitions {
+			fmt.Fprintf(&sb, "\t%s -> %s [label=%q];\n", Name(id), Name(t.To), t.On)
+		}
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+package states
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+func newTestMachine() *Machine {
+	return NewConversation(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestTransitionIllegal(t *testing.T) {
+	m := newTestMachine()
+
+	tests := []struct {
+		name     string
+		from, to int
+	}{
+		{"not listed", StateEmailWait, StateFind},
+		{"skipping the menu", StateFind, StateAdmin},
+		{"from unregistered state", StateSubscribe, StateMenu},
+		{"to unregistered state", StateMenu, StateEmailSent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &UserState{State: tt.from, ScreenID: 7}
+
+			err := m.Transition(s, tt.to)
+			if !errors.Is(err, ErrIllegalTransition) {
+				t.Fatalf("Transition = %v, want %v", err, ErrIllegalTransition)
+			}
+			if s.State != tt.from || s.ScreenID != 7 {
+				t.Errorf("state = %+v, want it unchanged", s)
+			}
+		})
+	}
+}
+
+func TestTransitionHooks(t *testing.T) {
+	var calls []string
+
+	m := NewMachine(slog.New(slog.NewTextHandler(io.Discard, nil)), StateMenu)
+	m.Register(State{
+		ID:          StateMenu,
+		Transitions: []Transition{{To: StateFind, On: "find"}},
+		OnExit:      func(*UserState) { calls = append(calls, "exit menu") },
+	})
+	m.Register(State{
+		ID:      StateFind,
+		OnEnter: func(*UserState) { calls = append(calls, "enter find") },
+	})
+
+	s := &UserState{State: StateMenu}
+	if err := m.Transition(s, StateFind); err != nil {
+		t.Fatalf("Transition: %v", err)
+	}
+	if s.State != StateFind {
+		t.Errorf("state = %s, want %s", Name(s.State), Name(StateFind))
+	}
+	if want := []string{"exit menu", "enter find"}; !slices.Equal(calls, want) {
+		t.Errorf("hooks = %v, want %v", calls, want)
+	}
+
+	// Staying in the same state is allowed and calls no hooks
+	if err := m.Transition(s, StateFind); err != nil {
+		t.Fatalf("Transition to the same state: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("hooks = %v, want no calls for the same state", calls)
+	}
+}
+
+func TestTransitionResetsSession(t *testing.T) {
+	m := newTestMachine()
+
+	s := &UserState{State: StateMenu, ScreenID: 7, Language: "ru", Finder: FindState{City: "Moscow"}}
+	if err := m.Transition(s, StateAuthMiddleware); err != nil {
+		t.Fatalf("Transition: %v", err)
+	}
+	if s.ScreenID != 0 || s.Language != "" || s.Finder.City != "" {
+		t.Errorf("state = %+v, want the session reset", s)
+	}
+}
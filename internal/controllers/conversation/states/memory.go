@@ -0,0 +1,65 @@
+package states
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps user states in memory, they are lost on restart
+type MemoryStore struct {
+	mx    sync.RWMutex
+	users map[int64]UserState
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users: make(map[int64]UserState),
+	}
+}
+
+func (m *MemoryStore) Load(key int64) (UserState, bool, error) {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	val, ok := m.users[key]
+
+	return val, ok, nil
+}
+
+func (m *MemoryStore) Store(key int64, state UserState) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.users[key] = state
+
+	return nil
+}
+
+func (m *MemoryStore) Counts(activeSince time.Time) (map[int]int, error) {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+
+	counts := make(map[int]int)
+	for _, us := range m.users {
+		if !us.LastActive.Before(activeSince) {
+			counts[us.State]++
+		}
+	}
+
+	return counts, nil
+}
+
+func (m *MemoryStore) Evict(idleSince time.Time) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	evicted := 0
+	for key, us := range m.users {
+		if us.LastActive.Before(idleSince) {
+			delete(m.users, key)
+			evicted++
+		}
+	}
+
+	return evicted, nil
+}
@@ -0,0 +1,128 @@
+package states
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+// SessionStorage persists sessions of the messenger accounts
+type SessionStorage interface {
+	Session(messengerType string, messengerID int64) (models.Session, error)
+	SaveSession(models.Session) error
+	SessionCounts(messengerType string, activeSince time.Time) (map[int]int, error)
+	DeleteSessions(messengerType string, idleSince time.Time) (int64, error)
+}
+
+// SQLiteStore keeps user states as JSON in the sessions table, so conversations survive restarts.
+// States are cached: stored ones are written to the table and the cache, loaded ones are read
+// from the table once
+type SQLiteStore struct {
+	storage       SessionStorage
+	messengerType string
+
+	mx    sync.RWMutex
+	cache map[int64]UserState
+}
+
+func NewSQLiteStore(storage SessionStorage, messengerType string) *SQLiteStore {
+	return &SQLiteStore{
+		storage:       storage,
+		messengerType: messengerType,
+		cache:         make(map[int64]UserState),
+	}
+}
+
+func (s *SQLiteStore) Load(key int64) (UserState, bool, error) {
+	const fn = "states.SQLiteStore.Load"
+
+	s.mx.RLock()
+	state, ok := s.cache[key]
+	s.mx.RUnlock()
+	if ok {
+		return state, true, nil
+	}
+
+	session, err := s.storage.Session(s.messengerType, key)
+	if errors.Is(err, repo.ErrSessionNotFound) {
+		return UserState{}, false, nil
+	}
+	if err != nil {
+		return UserState{}, false, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	if err := json.Unmarshal([]byte(session.Data), &state); err != nil {
+		return UserState{}, false, fmt.Errorf("%s:%w", fn, err)
+	}
+	state.State = session.State
+	state.LastActive = session.UpdatedAt
+
+	s.mx.Lock()
+	s.cache[key] = state
+	s.mx.Unlock()
+
+	return state, true, nil
+}
+
+// Store writes the state to the table and the cache. The cache is updated even if writing fails,
+// so the conversation goes on until restart
+func (s *SQLiteStore) Store(key int64, state UserState) error {
+	const fn = "states.SQLiteStore.Store"
+
+	s.mx.Lock()
+	s.cache[key] = state
+	s.mx.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	err = s.storage.SaveSession(models.Session{
+		MessengerType: s.messengerType,
+		MessengerID:   key,
+		State:         state.State,
+		Data:          string(data),
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) Counts(activeSince time.Time) (map[int]int, error) {
+	const fn = "states.SQLiteStore.Counts"
+
+	counts, err := s.storage.SessionCounts(s.messengerType, activeSince)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return counts, nil
+}
+
+// Evict deletes idle sessions from the table and the cache
+func (s *SQLiteStore) Evict(idleSince time.Time) (int, error) {
+	const fn = "states.SQLiteStore.Evict"
+
+	s.mx.Lock()
+	for key, state := range s.cache {
+		if state.LastActive.Before(idleSince) {
+			delete(s.cache, key)
+		}
+	}
+	s.mx.Unlock()
+
+	n, err := s.storage.DeleteSessions(s.messengerType, idleSince)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,206 @@
+package states
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+)
+
+// Conditions
+const (
+	StateAuthMiddleware = iota
+	StateEmailWait
+	StateEmailSent
+
+	StateMenu
+	StateDeleteConfirm
+	StateAdmin
+
+	StateFind
+	StateSubscribe
+)
+
+var names = map[int]string{
+	StateAuthMiddleware: "auth_middleware",
+	StateEmailWait:      "email_wait",
+	StateEmailSent:      "email_sent",
+	StateMenu:           "menu",
+	StateDeleteConfirm:  "delete_confirm",
+	StateAdmin:          "admin",
+	StateFind:           "find",
+	StateSubscribe:      "subscribe",
+}
+
+// Name returns the name of the state for logs and metrics
+func Name(state int) string {
+	if name, ok := names[state]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// States keeps user states in the store by value, so a state loaded by one handler is never changed
+// by another until it is stored back. Errors of the store are logged, the user starts a new session then.
+// Sessions idle longer than ttl are expired, the janitor evicts sessions idle longer than retention
+type States struct {
+	log       *slog.Logger
+	store     StateStore
+	ttl       time.Duration
+	retention time.Duration
+}
+
+// Kinds of the state store
+const (
+	StoreMemory = "memory"
+	StoreSQLite = "sqlite"
+)
+
+// StateStore keeps user states between updates, see MemoryStore and SQLiteStore
+type StateStore interface {
+	Load(key int64) (UserState, bool, error)
+	Store(key int64, state UserState) error
+	// Counts returns the number of users in each state active since the time
+	Counts(activeSince time.Time) (map[int]int, error)
+	// Evict forgets sessions idle since the time and returns their number
+	Evict(idleSince time.Time) (int, error)
+}
+
+// UserState is stored as JSON by SQLiteStore, fields are kept compatible with saved sessions
+type UserState struct {
+	State  int       `json:"state"`
+	Finder FindState `json:"finder"`
+	// ScreenID is the ID of the last screen message, choices of older screens are stale
+	ScreenID int `json:"screen_id,omitempty"`
+	// Language is chosen by the user, empty follows the messenger client. It is loaded with the state
+	Language string `json:"language,omitempty"`
+	// StartPayload is the deep link parameter of /start sent before the account was activated,
+	// it is followed when the user gets to the menu
+	StartPayload string `json:"start_payload,omitempty"`
+	// LastActive is the time the state was stored last
+	LastActive time.Time `json:"last_active"`
+	// Expired is set by Load if the session was idle longer than the TTL, it is not stored
+	Expired bool `json:"-"`
+}
+
+type FindState struct {
+	Organization string `json:"organization,omitempty"`
+	City         string `json:"city,omitempty"`
+	Office       string `json:"office,omitempty"`
+	Department   string `json:"department,omitempty"`
+	UserID       int64  `json:"user_id,omitempty"`
+	// Options are the choices of the current step in the order of buttons.
+	// The slice is replaced as a whole and never changed in place, so copies of the state may share it
+	Options []Option `json:"options,omitempty"`
+}
+
+// Path returns the chosen organization, city, office and department, as far as they are chosen
+func (f FindState) Path() []string {
+	path := make([]string, 0, 4)
+	for _, v := range []string{f.Organization, f.City, f.Office, f.Department} {
+		if v == "" {
+			break
+		}
+		path = append(path, v)
+	}
+
+	return path
+}
+
+// Option is a finder choice: Label is shown on the button, Value is passed to the next step
+type Option struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// NewStates returns user states kept in the store. Sessions idle longer than ttl expire on the next update,
+// sessions idle longer than retention are evicted by RunJanitor
+func NewStates(log *slog.Logger, store StateStore, ttl, retention time.Duration) *States {
+	return &States{
+		log:       log,
+		store:     store,
+		ttl:       ttl,
+		retention: retention,
+	}
+}
+
+// Load returns a copy of the user state, changes are visible to others after Store.
+// The state of an idle session is returned as is with Expired set, the handler decides what to reset
+func (s *States) Load(key int64) (UserState, bool) {
+	const fn = "states.Load"
+
+	state, ok, err := s.store.Load(key)
+	if err != nil {
+		s.log.Error("failed to load user state", slog.String("fn", fn), slog.Int64("key", key), sl.Err(err))
+		return UserState{}, false
+	}
+
+	if ok && s.ttl > 0 && time.Since(state.LastActive) > s.ttl {
+		state.Expired = true
+	}
+
+	return state, ok
+}
+
+// Store saves the state as the last activity of the user
+func (s *States) Store(key int64, value UserState) {
+	const fn = "states.Store"
+
+	value.LastActive = time.Now()
+	value.Expired = false
+
+	if err := s.store.Store(key, value); err != nil {
+		s.log.Error("failed to store user state", slog.String("fn", fn), slog.Int64("key", key), sl.Err(err))
+	}
+}
+
+// CountByState returns the number of users with not expired sessions in each state
+func (s *States) CountByState() map[string]int {
+	const fn = "states.CountByState"
+
+	counts := make(map[string]int)
+
+	var activeSince time.Time
+	if s.ttl > 0 {
+		activeSince = time.Now().Add(-s.ttl)
+	}
+
+	byState, err := s.store.Counts(activeSince)
+	if err != nil {
+		s.log.Error("failed to count user states", slog.String("fn", fn), sl.Err(err))
+		return counts
+	}
+
+	for state, n := range byState {
+		counts[Name(state)] += n
+	}
+
+	return counts
+}
+
+// RunJanitor evicts sessions idle longer than the retention every interval until ctx is done
+func (s *States) RunJanitor(ctx context.Context, interval time.Duration) {
+	const fn = "states.RunJanitor"
+
+	log := s.log.With(slog.String("fn", fn))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := s.store.Evict(time.Now().Add(-s.retention))
+			if err != nil {
+				log.Error("failed to evict idle sessions", sl.Err(err))
+				continue
+			}
+			if n > 0 {
+				log.Info("idle sessions evicted", slog.Int("count", n))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
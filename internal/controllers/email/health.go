@@ -0,0 +1,44 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+)
+
+// Check connects to the SMTP server and waits for its greeting
+func (s *Sender) Check(ctx context.Context) error {
+	addr := net.JoinHostPort(s.d.Host, strconv.Itoa(s.d.Port))
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.d.SSL {
+		d := tls.Dialer{Config: s.d.TLSConfig}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	tc := textproto.NewConn(conn)
+	if _, _, err := tc.ReadResponse(220); err != nil {
+		return fmt.Errorf("unexpected greeting: %w", err)
+	}
+
+	_ = tc.PrintfLine("QUIT")
+
+	return nil
+}
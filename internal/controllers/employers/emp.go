@@ -4,10 +4,19 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/arxonic/gmh/internal/lib/metrics"
 	"github.com/arxonic/gmh/internal/models"
 )
 
+var (
+	requestDuration = metrics.NewHistogramVec("employer_api_request_duration_seconds",
+		"Duration of Employer API requests in seconds.", metrics.DefBuckets)
+	requestErrors = metrics.NewCounterVec("employer_api_errors_total",
+		"Failed Employer API requests.")
+)
+
 type Employer struct {
 	URL string
 }
@@ -17,7 +26,14 @@ func New(url string) Employer {
 }
 
 // Employee return Emloyee info from Employer API
-func (e Employer) Employee(email string) (models.Emp, error) {
+func (e Employer) Employee(email string) (emp models.Emp, err error) {
+	defer func(start time.Time) {
+		requestDuration.Observe(metrics.Since(start))
+		if err != nil {
+			requestErrors.Inc()
+		}
+	}(time.Now())
+
 	resp, err := http.Get(e.URL + email)
 	if err != nil {
 		return models.Emp{}, err
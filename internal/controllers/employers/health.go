@@ -0,0 +1,27 @@
+package employers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Check requests the Employer API base URL, any response except server errors means it is reachable
+func (e Employer) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("employer API responded with %s", resp.Status)
+	}
+
+	return nil
+}
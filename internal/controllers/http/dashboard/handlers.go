@@ -0,0 +1,353 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/admin"
+	"github.com/go-chi/chi/v5"
+)
+
+// SessionCookie keeps the session token of the admin
+const SessionCookie = "gmh_admin_session"
+
+// csrfField is the form field with the CSRF token of the session
+const csrfField = "csrf_token"
+
+//go:embed templates
+var templatesFS embed.FS
+
+var pages = map[string]*template.Template{
+	"login":     parsePage("login.html"),
+	"code":      parsePage("code.html"),
+	"dashboard": parsePage("dashboard.html"),
+}
+
+func parsePage(name string) *template.Template {
+	return template.Must(template.ParseFS(templatesFS, "templates/layout.html", "templates/"+name))
+}
+
+// Results of actions shown after redirect to the dashboard
+var flashes = map[string]string{
+	"activated":   "Пользователь активирован",
+	"deactivated": "Пользователь деактивирован",
+	"resent":      "Ссылка активации отправлена повторно",
+	"cancelled":   "Поздравление отменено",
+	"no_pending":  "У пользователя нет аккаунтов, ожидающих активации",
+	"forbidden":   "Действие недоступно для вашей роли",
+	"error":       "Не удалось выполнить действие, попробуйте позже",
+}
+
+type Administrator interface {
+	StartLogin(email string) error
+	CompleteLogin(email, code string) (admin.Session, error)
+	Session(token string) (admin.Session, error)
+	Logout(token string)
+	Dashboard(adminID, afterID int64) (models.Dashboard, error)
+	SetActivation(adminID, uID int64, activated bool) error
+	ResendActivation(adminID, uID int64) (int, error)
+	CancelCelebration(adminID, celebrationID int64) error
+}
+
+type sessionKey struct{}
+
+type loginPage struct {
+	Email string
+	Error string
+}
+
+type dashboardPage struct {
+	CSRFToken     string
+	Flash         string
+	Dashboard     models.Dashboard
+	Users         []userRow
+	Organizations []*orgNode
+}
+
+type userRow struct {
+	ID            int64
+	Name          string
+	Email         string
+	BirthDate     time.Time
+	Organizations string
+	Activated     bool
+	Pending       bool // has messenger accounts waiting for activation
+}
+
+// orgNode is a level of the organization tree: name, city, office and department
+type orgNode struct {
+	Title    string
+	Children []*orgNode
+}
+
+// LoginPage renders the form asking for the admin email
+func LoginPage(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render(w, log, http.StatusOK, "login", loginPage{})
+	}
+}
+
+// Login sends the login code to the admin messenger
+func Login(log *slog.Logger, adm Administrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "http.dashboard.Login"
+
+		log := log.With(slog.String("fn", fn))
+
+		email := strings.TrimSpace(r.FormValue("email"))
+		if email == "" {
+			render(w, log, http.StatusBadRequest, "login", loginPage{Error: "Укажите рабочий email"})
+			return
+		}
+
+		if err := adm.StartLogin(email); err != nil {
+			log.Error("failed to start login", sl.Err(err))
+			render(w, log, http.StatusInternalServerError, "login", loginPage{Email: email, Error: "Не удалось отправить код, попробуйте позже"})
+			return
+		}
+
+		render(w, log, http.StatusOK, "code", loginPage{Email: email})
+	}
+}
+
+// LoginCode checks the login code and starts the session
+func LoginCode(log *slog.Logger, adm Administrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "http.dashboard.LoginCode"
+
+		log := log.With(slog.String("fn", fn))
+
+		email := r.FormValue("email")
+
+		session, err := adm.CompleteLogin(email, r.FormValue("code"))
+		if errors.Is(err, admin.ErrInvalidCode) {
+			render(w, log, http.StatusUnauthorized, "code", loginPage{Email: email, Error: "Неверный или просроченный код"})
+			return
+		}
+		if err != nil {
+			log.Error("failed to complete login", sl.Err(err))
+			render(w, log, http.StatusInternalServerError, "code", loginPage{Email: email, Error: "Не удалось войти, попробуйте позже"})
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     SessionCookie,
+			Value:    session.Token,
+			Path:     "/admin",
+			Expires:  session.ExpiresAt,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+	}
+}
+
+// Logout ends the session
+func Logout(adm Administrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adm.Logout(sessionFrom(r).Token)
+
+		http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: "", Path: "/admin", MaxAge: -1, HttpOnly: true})
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+	}
+}
+
+// RequireSession redirects to the login page without a valid session and checks CSRF token of POST requests
+func RequireSession(adm Administrator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(SessionCookie)
+			if err != nil {
+				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				return
+			}
+
+			session, err := adm.Session(cookie.Value)
+			if err != nil {
+				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				return
+			}
+
+			if r.Method == http.MethodPost && !validCSRF(session, r.FormValue(csrfField)) {
+				http.Error(w, "invalid CSRF token", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), sessionKey{}, session)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Dashboard renders users, organizations, upcoming birthdays, celebrations and email failures
+func Dashboard(log *slog.Logger, adm Administrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "http.dashboard.Dashboard"
+
+		log := log.With(slog.String("fn", fn))
+
+		session := sessionFrom(r)
+
+		afterID, _ := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+
+		d, err := adm.Dashboard(session.AdminID, afterID)
+		if errors.Is(err, admin.ErrNotAdmin) {
+			adm.Logout(session.Token)
+			http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			log.Error("failed to load dashboard", sl.Err(err))
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		render(w, log, http.StatusOK, "dashboard", dashboardPage{
+			CSRFToken:     session.CSRFToken,
+			Flash:         flashes[r.URL.Query().Get("msg")],
+			Dashboard:     d,
+			Users:         userRows(d.Users),
+			Organizations: orgTree(d.Organizations),
+		})
+	}
+}
+
+// SetActivation activates or deactivates the user
+func SetActivation(log *slog.Logger, adm Administrator, activated bool) http.HandlerFunc {
+	return action(log, func(adminID, id int64) (string, error) {
+		if err := adm.SetActivation(adminID, id, activated); err != nil {
+			return "", err
+		}
+		if activated {
+			return "activated", nil
+		}
+		return "deactivated", nil
+	})
+}
+
+// ResendActivation sends new activation links to the user
+func ResendActivation(log *slog.Logger, adm Administrator) http.HandlerFunc {
+	return action(log, func(adminID, id int64) (string, error) {
+		_, err := adm.ResendActivation(adminID, id)
+		return "resent", err
+	})
+}
+
+// CancelCelebration cancels the celebration
+func CancelCelebration(log *slog.Logger, adm Administrator) http.HandlerFunc {
+	return action(log, func(adminID, id int64) (string, error) {
+		return "cancelled", adm.CancelCelebration(adminID, id)
+	})
+}
+
+// action runs do with the admin and {id} URL param and redirects to the dashboard with the result
+func action(log *slog.Logger, do func(adminID, id int64) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		msg, err := do(sessionFrom(r).AdminID, id)
+		switch {
+		case errors.Is(err, admin.ErrForbidden), errors.Is(err, admin.ErrNotAdmin):
+			msg = "forbidden"
+		case errors.Is(err, admin.ErrNoPendingActivation):
+			msg = "no_pending"
+		case err != nil:
+			log.Error("admin action failed", slog.String("path", r.URL.Path), sl.Err(err))
+			msg = "error"
+		}
+
+		http.Redirect(w, r, "/admin?msg="+msg, http.StatusSeeOther)
+	}
+}
+
+func sessionFrom(r *http.Request) admin.Session {
+	session, _ := r.Context().Value(sessionKey{}).(admin.Session)
+	return session
+}
+
+func validCSRF(session admin.Session, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) == 1
+}
+
+func render(w http.ResponseWriter, log *slog.Logger, status int, page string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := pages[page].ExecuteTemplate(w, "layout", data); err != nil {
+		log.Error("failed to render dashboard page", slog.String("page", page), sl.Err(err))
+	}
+}
+
+func userRows(users []models.UserInfo) []userRow {
+	rows := make([]userRow, 0, len(users))
+	for _, info := range users {
+		row := userRow{
+			ID:        info.User.ID,
+			Name:      strings.TrimSpace(info.User.LastName + " " + info.User.FirstName + " " + info.User.Patronymic),
+			Email:     info.User.Email,
+			BirthDate: info.User.BirthDate,
+		}
+
+		orgs := make([]string, 0, len(info.Organizations))
+		for _, org := range info.Organizations {
+			orgs = append(orgs, org.Name+", "+org.Department)
+		}
+		row.Organizations = strings.Join(orgs, "; ")
+
+		for _, m := range info.Messengers {
+			if m.IsActivated {
+				row.Activated = true
+			} else {
+				row.Pending = true
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// orgTree groups organizations by name, city, office and department keeping their order
+func orgTree(orgs []models.Organization) []*orgNode {
+	root := &orgNode{}
+	for _, org := range orgs {
+		node := root
+		for _, title := range []string{org.Name, org.City, org.Office, org.Department} {
+			node = node.child(title)
+		}
+	}
+
+	return root.Children
+}
+
+func (n *orgNode) child(title string) *orgNode {
+	for _, c := range n.Children {
+		if c.Title == title {
+			return c
+		}
+	}
+
+	c := &orgNode{Title: title}
+	n.Children = append(n.Children, c)
+
+	return c
+}
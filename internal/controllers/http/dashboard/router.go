@@ -0,0 +1,32 @@
+package dashboard
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NewRouts registers the admin dashboard. Login requests pass through the limit middleware
+func NewRouts(handler *chi.Mux, log *slog.Logger, adm Administrator, limit func(http.Handler) http.Handler) {
+	handler.Route("/admin", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(limit)
+
+			r.Get("/login", LoginPage(log))
+			r.Post("/login", Login(log, adm))
+			r.Post("/login/code", LoginCode(log, adm))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(RequireSession(adm))
+
+			r.Get("/", Dashboard(log, adm))
+			r.Post("/logout", Logout(adm))
+			r.Post("/users/{id}/activate", SetActivation(log, adm, true))
+			r.Post("/users/{id}/deactivate", SetActivation(log, adm, false))
+			r.Post("/users/{id}/resend", ResendActivation(log, adm))
+			r.Post("/celebrations/{id}/cancel", CancelCelebration(log, adm))
+		})
+	})
+}
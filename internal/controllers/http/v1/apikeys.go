@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/apikeys"
+)
+
+type KeyAuthenticator interface {
+	Authenticate(key string) (models.APIKey, error)
+}
+
+type apiKeyKey struct{}
+
+// APIKeyAuth authenticates machine clients by the "Authorization: Bearer <key>" header
+func APIKeyAuth(log *slog.Logger, keys KeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			const fn = "http.v1.APIKeyAuth"
+
+			key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || key == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				respondError(w, r, http.StatusUnauthorized, codeUnauthorized, "API key is required")
+				return
+			}
+
+			apiKey, err := keys.Authenticate(strings.TrimSpace(key))
+			switch {
+			case errors.Is(err, apikeys.ErrInvalidKey):
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				respondError(w, r, http.StatusUnauthorized, codeUnauthorized, "invalid API key")
+				return
+			case errors.Is(err, apikeys.ErrKeyExpired):
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				respondError(w, r, http.StatusUnauthorized, codeUnauthorized, "API key expired")
+				return
+			case err != nil:
+				log.Error("failed to authenticate api key", slog.String("fn", fn), sl.Err(err))
+				respondInternalError(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), apiKeyKey{}, apiKey)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope rejects requests with an API key without the scope, a key with the admin scope passes any scope.
+// Must be used after APIKeyAuth
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey, ok := r.Context().Value(apiKeyKey{}).(models.APIKey)
+			if !ok || !apiKey.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+				respondError(w, r, http.StatusForbidden, codeForbidden, "API key has no scope "+scope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,53 +1,212 @@
 package v1
 
 import (
+	"embed"
+	"errors"
+	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/services/auth"
+	"github.com/go-chi/render"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var activationTmpl = template.Must(template.ParseFS(templatesFS, "templates/activation.html"))
+
+// Activation statuses returned to JSON clients
+const (
+	statusConfirm          = "confirmation_required"
+	statusActivated        = "activated"
+	statusAlreadyActivated = "already_activated"
+	statusExpired          = "expired"
+	statusInvalid          = "invalid"
+	statusError            = "error"
 )
 
-func Auth(log *slog.Logger, userAuther UserAuther) http.HandlerFunc {
+type activationForm struct {
+	Token         string
+	MessengerType string
+	MessengerID   int64
+	ChatID        int64
+	RedirectURL   string
+}
+
+type activationPage struct {
+	Lang        string
+	Title       string
+	Message     string
+	Form        *activationForm
+	RedirectURL string
+	// Button and Back are the labels of the confirmation button and the link back to the messenger
+	Button string
+	Back   string
+}
+
+type activationResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// newActivationPage returns the page of the activation.<result> texts
+func newActivationPage(l i18n.Localizer, result string) activationPage {
+	return activationPage{
+		Lang:    l.Lang(),
+		Title:   l.T("activation." + result + ".title"),
+		Message: l.T("activation." + result + ".message"),
+		Button:  l.T("activation.confirm.button"),
+		Back:    l.T("activation.back"),
+	}
+}
+
+// AuthPage renders the page which asks user to confirm account activation.
+// Activation itself happens only on POST, so link prefetchers can't activate accounts.
+// Texts are in the language of the browser
+func AuthPage(log *slog.Logger, catalog *i18n.Catalog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := catalog.Localizer(acceptLanguages(r.Header.Get("Accept-Language"))...)
+
+		form, err := parseActivationForm(r)
+		if err != nil {
+			respondActivation(w, r, log, http.StatusBadRequest, statusInvalid, newActivationPage(l, "invalid"))
+			return
+		}
+
+		page := newActivationPage(l, "confirm")
+		page.Form = &form
+		respondActivation(w, r, log, http.StatusOK, statusConfirm, page)
+	}
+}
+
+// Auth activates user account
+func Auth(log *slog.Logger, userAuther UserAuther, catalog *i18n.Catalog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const fn = "http.v1.api.Auth"
 
-		log = log.With(
+		log := log.With(
 			slog.String("fn", fn),
 		)
 
-		// Get Qury params
-		token := r.URL.Query().Get("token")
+		l := catalog.Localizer(acceptLanguages(r.Header.Get("Accept-Language"))...)
 
-		messengerType := r.URL.Query().Get("mtype")
-		if messengerType == "" {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
-		}
-
-		messengerID, err := strconv.Atoi(r.URL.Query().Get("mid"))
+		form, err := parseActivationForm(r)
 		if err != nil {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+			respondActivation(w, r, log, http.StatusBadRequest, statusInvalid, newActivationPage(l, "invalid"))
 			return
 		}
 
-		chatID, err := strconv.Atoi(r.URL.Query().Get("chatid"))
-		if err != nil {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
+		// Activate Account
+		err = userAuther.AccountActivation(form.MessengerType, form.MessengerID, form.ChatID, form.Token)
+		switch {
+		case err == nil:
+			page := newActivationPage(l, "done")
+			page.RedirectURL = form.RedirectURL
+			respondActivation(w, r, log, http.StatusOK, statusActivated, page)
+		case errors.Is(err, auth.ErrAlreadyActivated):
+			page := newActivationPage(l, "already")
+			page.RedirectURL = form.RedirectURL
+			respondActivation(w, r, log, http.StatusConflict, statusAlreadyActivated, page)
+		case errors.Is(err, auth.ErrTokenExpired):
+			page := newActivationPage(l, "expired")
+			page.RedirectURL = form.RedirectURL
+			respondActivation(w, r, log, http.StatusGone, statusExpired, page)
+		case errors.Is(err, auth.ErrInvalidToken):
+			respondActivation(w, r, log, http.StatusBadRequest, statusInvalid, newActivationPage(l, "invalid"))
+		default:
+			log.Error("failed to activate account", sl.Err(err))
+			respondActivation(w, r, log, http.StatusInternalServerError, statusError, newActivationPage(l, "error"))
 		}
+	}
+}
 
-		redirectURL := r.URL.Query().Get("redirect")
-		if redirectURL == "" {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
+// acceptLanguages returns language tags of the Accept-Language header, the preferred ones first
+func acceptLanguages(header string) []string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+
+	tags := make([]tag, 0)
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if lang == "" || lang == "*" {
+			continue
 		}
 
-		// Activate Account
-		err = userAuther.AccountActivation(messengerType, int64(messengerID), int64(chatID), token)
-		if err != nil {
-			http.Error(w, "Bad Request", http.StatusInternalServerError)
-			return
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
 		}
 
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		tags = append(tags, tag{lang: lang, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	langs := make([]string, 0, len(tags))
+	for _, t := range tags {
+		langs = append(langs, t.lang)
+	}
+
+	return langs
+}
+
+func parseActivationForm(r *http.Request) (activationForm, error) {
+	form := activationForm{
+		Token:         r.FormValue("token"),
+		MessengerType: r.FormValue("mtype"),
+		RedirectURL:   safeRedirect(r.FormValue("redirect")),
+	}
+
+	if form.Token == "" || form.MessengerType == "" {
+		return activationForm{}, errors.New("token and mtype are required")
+	}
+
+	var err error
+	form.MessengerID, err = strconv.ParseInt(r.FormValue("mid"), 10, 64)
+	if err != nil {
+		return activationForm{}, err
+	}
+
+	form.ChatID, err = strconv.ParseInt(r.FormValue("chatid"), 10, 64)
+	if err != nil {
+		return activationForm{}, err
+	}
+
+	return form, nil
+}
+
+// safeRedirect allows redirects only back to Telegram
+func safeRedirect(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host != "t.me" {
+		return ""
+	}
+
+	return u.String()
+}
+
+func respondActivation(w http.ResponseWriter, r *http.Request, log *slog.Logger, code int, status string, page activationPage) {
+	if render.GetAcceptedContentType(r) == render.ContentTypeJSON {
+		render.Status(r, code)
+		render.JSON(w, r, activationResponse{Status: status, Message: page.Message})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := activationTmpl.Execute(w, page); err != nil {
+		log.Error("failed to render activation page", sl.Err(err))
 	}
 }
@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuthPageLanguage(t *testing.T) {
+	router := newTestRouter(t, stubKeys{}, &stubSubscriber{})
+
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "Registration confirmation"},
+		{"en-US,en;q=0.9", "Registration confirmation"},
+		{"ru-RU,ru;q=0.9,en;q=0.8", "Подтверждение регистрации"},
+		{"de;q=1.0,en;q=0.5,ru;q=0.7", "Подтверждение регистрации"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/auth?token=t&mtype=telegram&mid=1&chatid=1", nil)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Accept-Language %q: status %d, want %d", tt.acceptLanguage, rec.Code, http.StatusOK)
+		}
+		if !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("Accept-Language %q: page has no %q:\n%s", tt.acceptLanguage, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestAuthInvalidLinkLanguage(t *testing.T) {
+	router := newTestRouter(t, stubKeys{}, &stubSubscriber{})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth", strings.NewReader("mtype=telegram"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept-Language", "ru")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Ссылка недействительна") || !strings.Contains(body, `lang="ru"`) {
+		t.Errorf("page is not in russian:\n%s", body)
+	}
+}
+
+func TestAcceptLanguages(t *testing.T) {
+	got := acceptLanguages("fr;q=0.2, ru-RU , *;q=0.1, en;q=0.5, bad;q=x")
+	want := []string{"ru-RU", "en", "fr"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("acceptLanguages = %v, want %v", got, want)
+	}
+}
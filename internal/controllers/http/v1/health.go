@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/arxonic/gmh/internal/services/health"
+	"github.com/go-chi/render"
+)
+
+type Readiness interface {
+	Ready(ctx context.Context) health.Report
+}
+
+// Healthz reports that the process is alive
+func Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, health.Report{Status: health.StatusOK, Checks: map[string]health.Result{}})
+	}
+}
+
+// Readyz runs readiness checks of all dependencies, 503 if any of them failed
+func Readyz(ready Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := ready.Ready(r.Context())
+		if report.Status != health.StatusOK {
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+
+		render.JSON(w, r, report)
+	}
+}
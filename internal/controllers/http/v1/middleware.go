@@ -0,0 +1,41 @@
+package v1
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arxonic/gmh/internal/services/guard"
+)
+
+type Limiter interface {
+	Allow(kind, subject string) error
+	LockedUntil(kind, subject string) time.Time
+}
+
+// RateLimit limits requests by client IP address
+func RateLimit(log *slog.Logger, limiter Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			err = limiter.Allow(guard.KindIP, ip)
+			if errors.Is(err, guard.ErrLimited) || errors.Is(err, guard.ErrLocked) {
+				if wait := time.Until(limiter.LockedUntil(guard.KindIP, ip)); wait > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				}
+
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
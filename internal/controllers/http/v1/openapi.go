@@ -0,0 +1,127 @@
+package v1
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/arxonic/gmh/internal/lib/openapi"
+	"github.com/go-chi/chi/v5"
+)
+
+// SpecPath is the route of the OpenAPI document
+const SpecPath = "/v1/openapi.json"
+
+//go:embed openapi.json
+var specJSON []byte
+
+var spec = mustLoadSpec(specJSON)
+
+func mustLoadSpec(raw []byte) *openapi.Spec {
+	s, err := openapi.Load(raw)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// OpenAPI serves the OpenAPI document of the API
+func OpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(spec.Raw())
+	}
+}
+
+// Validate rejects requests which don't match the OpenAPI document with validation_failed error.
+// Requests to routes missing in the document are passed as is
+func Validate() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, params, ok := spec.Find(r.Method, r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if errs := spec.Validate(r, op, params); len(errs) > 0 {
+				respondValidationError(w, r, errs)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Handlers behind Validate read parameters by the helpers below, they don't check values again,
+// so the document stays the only place of the rules
+
+// queryInt returns the integer query parameter, def if it is absent
+func queryInt(r *http.Request, name string, def int64) int64 {
+	v, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return def
+	}
+
+	return v
+}
+
+// queryBool returns the boolean query parameter, nil if it is absent
+func queryBool(r *http.Request, name string) *bool {
+	v, err := strconv.ParseBool(r.URL.Query().Get(name))
+	if err != nil {
+		return nil
+	}
+
+	return &v
+}
+
+// pathInt returns the integer path parameter
+func pathInt(r *http.Request, name string) int64 {
+	v, _ := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	return v
+}
+
+// CheckSpec compares v1 routes of the router with the OpenAPI document
+// and returns an error listing routes missing on either side
+func CheckSpec(routes chi.Routes) error {
+	const fn = "http.v1.CheckSpec"
+
+	registered := make(map[openapi.Route]bool)
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, "/v1/") {
+			return nil
+		}
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+
+		registered[openapi.Route{Method: method, Path: route}] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	drift := make([]string, 0)
+	for _, route := range spec.Routes() {
+		if !registered[route] {
+			drift = append(drift, "not routed: "+route.Method+" "+route.Path)
+		}
+		delete(registered, route)
+	}
+	for route := range registered {
+		drift = append(drift, "not documented: "+route.Method+" "+route.Path)
+	}
+
+	if len(drift) > 0 {
+		sort.Strings(drift)
+		return fmt.Errorf("%s: router and OpenAPI document differ: %s", fn, strings.Join(drift, "; "))
+	}
+
+	return nil
+}
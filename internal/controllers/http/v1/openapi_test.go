@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestCheckSpec(t *testing.T) {
+	router := newTestRouter(t, stubKeys{}, &stubSubscriber{})
+
+	if err := CheckSpec(router); err != nil {
+		t.Fatalf("CheckSpec: %v", err)
+	}
+}
+
+func TestCheckSpecUndocumentedRoute(t *testing.T) {
+	router := newTestRouter(t, stubKeys{}, &stubSubscriber{})
+	router.Get("/v1/extra", func(w http.ResponseWriter, r *http.Request) {})
+
+	err := CheckSpec(router)
+	if err == nil || !strings.Contains(err.Error(), "not documented: GET /v1/extra") {
+		t.Fatalf("CheckSpec = %v, want the undocumented route", err)
+	}
+}
+
+func TestCheckSpecMissingRoute(t *testing.T) {
+	router := chi.NewRouter()
+	router.Get(SpecPath, OpenAPI())
+
+	err := CheckSpec(router)
+	if err == nil || !strings.Contains(err.Error(), "not routed: GET /v1/subscriptions") {
+		t.Fatalf("CheckSpec = %v, want documented routes missing in the router", err)
+	}
+}
@@ -0,0 +1,47 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/arxonic/gmh/internal/lib/openapi"
+	"github.com/go-chi/render"
+)
+
+// Error codes of JSON error bodies
+const (
+	codeBadRequest   = "bad_request"
+	codeValidation   = "validation_failed"
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+	codeNotFound     = "not_found"
+	codeConflict     = "conflict"
+	codeInternal     = "internal_error"
+)
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message"`
+	Details []openapi.FieldError `json:"details,omitempty"`
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	render.Status(r, status)
+	render.JSON(w, r, errorResponse{Error: errorBody{Code: code, Message: message}})
+}
+
+func respondInternalError(w http.ResponseWriter, r *http.Request) {
+	respondError(w, r, http.StatusInternalServerError, codeInternal, "internal server error")
+}
+
+func respondValidationError(w http.ResponseWriter, r *http.Request, details []openapi.FieldError) {
+	render.Status(r, http.StatusBadRequest)
+	render.JSON(w, r, errorResponse{Error: errorBody{
+		Code:    codeValidation,
+		Message: "request validation failed",
+		Details: details,
+	}})
+}
@@ -3,6 +3,9 @@ package v1
 import (
 	"log/slog"
 
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/metrics"
+	"github.com/arxonic/gmh/internal/models"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -10,6 +13,46 @@ type UserAuther interface {
 	AccountActivation(messengerType string, messengerID, chatID int64, token string) error
 }
 
-func NewRouts(handler *chi.Mux, log *slog.Logger, auther UserAuther) {
-	handler.Get("/v1/auth", Auth(log, auther))
+// NewRouts registers v1 routes and checks them against the OpenAPI document
+func NewRouts(handler *chi.Mux, log *slog.Logger, catalog *i18n.Catalog, auther UserAuther, limiter Limiter, keys KeyAuthenticator, um UserManager, sub Subscriber, ready Readiness) error {
+	handler.Get("/healthz", Healthz())
+	handler.Get("/readyz", Readyz(ready))
+	handler.Handle("/metrics", metrics.Handler())
+	handler.Get(SpecPath, OpenAPI())
+
+	// The activation page renders its own errors for broken links, so it is not validated
+	handler.Group(func(r chi.Router) {
+		r.Use(RateLimit(log, limiter))
+
+		r.Get("/v1/auth", AuthPage(log, catalog))
+		r.Post("/v1/auth", Auth(log, auther, catalog))
+	})
+
+	handler.Route("/v1/users", func(r chi.Router) {
+		r.Use(APIKeyAuth(log, keys))
+
+		read := r.With(RequireScope(models.ScopeReadUsers), Validate())
+		read.Get("/", ListUsers(log, um))
+		read.Get("/by-email/{email}", GetUserByEmail(log, um))
+		read.Get("/{id}", GetUser(log, um))
+
+		write := r.With(RequireScope(models.ScopeWriteUsers), Validate())
+		write.Patch("/{id}", UpdateUser(log, um))
+		write.Post("/{id}/deactivate", DeactivateUser(log, um))
+	})
+
+	// The personal key authenticates the caller, subscriptions are of the key subject
+	handler.Group(func(r chi.Router) {
+		r.Use(APIKeyAuth(log, keys))
+
+		read := r.With(RequireScope(models.ScopeReadSubscriptions), Caller(log, sub), Validate())
+		read.Get("/v1/subscriptions", ListSubscriptions(log, sub))
+		read.Get("/v1/birthdays/upcoming", UpcomingBirthdays(log, sub))
+
+		write := r.With(RequireScope(models.ScopeWriteSubscriptions), Caller(log, sub), Validate())
+		write.Post("/v1/subscriptions", CreateSubscription(log, sub))
+		write.Delete("/v1/subscriptions/{user_id}", DeleteSubscription(log, sub))
+	})
+
+	return CheckSpec(handler)
 }
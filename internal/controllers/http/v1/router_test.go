@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/apikeys"
+	"github.com/arxonic/gmh/internal/services/health"
+	"github.com/arxonic/gmh/internal/services/subscribe"
+	"github.com/go-chi/chi/v5"
+)
+
+type stubAuther struct{}
+
+func (stubAuther) AccountActivation(string, int64, int64, string) error { return nil }
+
+type stubLimiter struct{}
+
+func (stubLimiter) Allow(string, string) error           { return nil }
+func (stubLimiter) LockedUntil(string, string) time.Time { return time.Time{} }
+
+// stubKeys authenticates the keys of the map
+type stubKeys map[string]models.APIKey
+
+func (k stubKeys) Authenticate(key string) (models.APIKey, error) {
+	apiKey, ok := k[key]
+	if !ok {
+		return models.APIKey{}, apikeys.ErrInvalidKey
+	}
+	return apiKey, nil
+}
+
+// stubUsers is the user manager, its methods are not called by tests
+type stubUsers struct {
+	UserManager
+}
+
+// stubSubscriber has activated users and records the subscriber of the last request
+type stubSubscriber struct {
+	activated map[int64]bool
+	subID     int64
+}
+
+func (s *stubSubscriber) CheckCaller(uID int64) error {
+	activated, ok := s.activated[uID]
+	switch {
+	case !ok:
+		return subscribe.ErrUserNotFound
+	case !activated:
+		return subscribe.ErrNotActivated
+	}
+	return nil
+}
+
+func (s *stubSubscriber) SubscribeUser(subID, _ int64) error {
+	s.subID = subID
+	return nil
+}
+
+func (s *stubSubscriber) Unsubscribe(subID, _ int64) error {
+	s.subID = subID
+	return nil
+}
+
+func (s *stubSubscriber) Subscriptions(subID int64) ([]models.Birthday, error) {
+	s.subID = subID
+	return nil, nil
+}
+
+func (s *stubSubscriber) UpcomingBirthdays(uID int64, _ int) ([]models.Birthday, error) {
+	s.subID = uID
+	return nil, nil
+}
+
+type stubReadiness struct{}
+
+func (stubReadiness) Ready(context.Context) health.Report {
+	return health.Report{Status: health.StatusOK}
+}
+
+// newTestRouter returns the router of v1 routes with stub services
+func newTestRouter(t *testing.T, keys stubKeys, sub *stubSubscriber) *chi.Mux {
+	t.Helper()
+
+	router := chi.NewRouter()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	catalog, err := i18n.Load("en", i18n.Args{"company": "ACME"}, "")
+	if err != nil {
+		t.Fatalf("i18n.Load: %v", err)
+	}
+
+	if err := NewRouts(router, log, catalog, stubAuther{}, stubLimiter{}, keys, stubUsers{}, sub, stubReadiness{}); err != nil {
+		t.Fatalf("NewRouts: %v", err)
+	}
+
+	return router
+}
+
+func TestSubscriptionsCaller(t *testing.T) {
+	subject := func(uID int64) *int64 { return &uID }
+	keys := stubKeys{
+		"gmh_ivan":   {CreatedBy: 1, SubjectID: subject(1), Scopes: apikeys.PersonalScopes},
+		"gmh_petr":   {CreatedBy: 2, SubjectID: subject(2), Scopes: apikeys.PersonalScopes},
+		"gmh_ghost":  {CreatedBy: 3, SubjectID: subject(3), Scopes: apikeys.PersonalScopes},
+		"gmh_reader": {CreatedBy: 1, SubjectID: subject(1), Scopes: []string{models.ScopeReadUsers}},
+		// The portal key issued by the admin Ivan acts on behalf of nobody, even with the admin scope
+		"gmh_portal": {CreatedBy: 1, Scopes: []string{models.ScopeAdmin}},
+	}
+
+	for _, tt := range []struct {
+		name       string
+		key        string
+		wantStatus int
+		wantSubID  int64
+	}{
+		{"key subject", "gmh_ivan", http.StatusOK, 1},
+		{"no key", "", http.StatusUnauthorized, 0},
+		{"not activated owner", "gmh_petr", http.StatusForbidden, 0},
+		{"unknown owner", "gmh_ghost", http.StatusUnauthorized, 0},
+		{"no scope", "gmh_reader", http.StatusForbidden, 0},
+		{"service key", "gmh_portal", http.StatusForbidden, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &stubSubscriber{activated: map[int64]bool{1: true, 2: false}}
+			router := newTestRouter(t, keys, sub)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/subscriptions", nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			// The caller is the key subject whatever the client claims
+			req.Header.Set("X-User-Email", "petr.petrov@example.com")
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if sub.subID != tt.wantSubID {
+				t.Errorf("subscriptions of %d, want %d", sub.subID, tt.wantSubID)
+			}
+		})
+	}
+}
@@ -1,14 +1,28 @@
 package v1
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"time"
+)
 
-func NewServer(address string, handler http.Handler) *http.Server {
+// NewServer returns the HTTP server, timeout limits reading the request and writing the response
+func NewServer(address string, handler http.Handler, timeout, idleTimeout time.Duration) *http.Server {
 	return &http.Server{
-		Addr:    address,
-		Handler: handler,
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: timeout,
+		ReadTimeout:       timeout,
+		WriteTimeout:      timeout,
+		IdleTimeout:       idleTimeout,
 	}
 }
 
+// Run serves until Shutdown is called
 func Run(srv *http.Server) error {
-	return srv.ListenAndServe()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
 }
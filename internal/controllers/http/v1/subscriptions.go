@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/subscribe"
+	"github.com/go-chi/render"
+)
+
+const defaultUpcomingDays = 7
+
+type callerKey struct{}
+
+type Subscriber interface {
+	CheckCaller(uID int64) error
+	SubscribeUser(subID, uID int64) error
+	Unsubscribe(subID, uID int64) error
+	Subscriptions(subID int64) ([]models.Birthday, error)
+	UpcomingBirthdays(uID int64, days int) ([]models.Birthday, error)
+}
+
+type birthdayListResponse struct {
+	Items []models.Birthday `json:"items"`
+}
+
+type subscribeRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+// Caller resolves the calling user as the subject of the personal API key, only activated users are allowed.
+// Keys of services act on behalf of nobody and are rejected. Must be used after APIKeyAuth
+func Caller(log *slog.Logger, sub Subscriber) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey, ok := r.Context().Value(apiKeyKey{}).(models.APIKey)
+			if !ok {
+				respondError(w, r, http.StatusUnauthorized, codeUnauthorized, "API key is required")
+				return
+			}
+
+			if apiKey.SubjectID == nil {
+				respondError(w, r, http.StatusForbidden, codeForbidden, "personal API key is required")
+				return
+			}
+
+			uID := *apiKey.SubjectID
+			err := sub.CheckCaller(uID)
+			switch {
+			case errors.Is(err, subscribe.ErrUserNotFound):
+				respondError(w, r, http.StatusUnauthorized, codeUnauthorized, "unknown user")
+				return
+			case errors.Is(err, subscribe.ErrNotActivated):
+				respondError(w, r, http.StatusForbidden, codeForbidden, "account is not activated")
+				return
+			case err != nil:
+				log.Error("failed to resolve caller", sl.Err(err))
+				respondInternalError(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), callerKey{}, uID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func callerID(r *http.Request) int64 {
+	uID, _ := r.Context().Value(callerKey{}).(int64)
+	return uID
+}
+
+// ListSubscriptions returns birthdays of users the caller is subscribed to
+func ListSubscriptions(log *slog.Logger, sub Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		birthdays, err := sub.Subscriptions(callerID(r))
+		if err != nil {
+			log.Error("failed to list subscriptions", sl.Err(err))
+			respondInternalError(w, r)
+			return
+		}
+
+		render.JSON(w, r, birthdayListResponse{Items: birthdays})
+	}
+}
+
+// CreateSubscription subscribes the caller on the user birthday
+func CreateSubscription(log *slog.Logger, sub Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req subscribeRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil || req.UserID < 1 {
+			respondError(w, r, http.StatusBadRequest, codeBadRequest, "user_id is required")
+			return
+		}
+
+		subID := callerID(r)
+		err := sub.SubscribeUser(subID, req.UserID)
+		if err != nil {
+			respondSubscriptionError(w, r, log, err)
+			return
+		}
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, models.Subscription{UserID: req.UserID, SubID: subID})
+	}
+}
+
+// DeleteSubscription unsubscribes the caller from the user birthday
+func DeleteSubscription(log *slog.Logger, sub Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := sub.Unsubscribe(callerID(r), pathInt(r, "user_id")); err != nil {
+			respondSubscriptionError(w, r, log, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UpcomingBirthdays returns birthdays of the caller colleagues in the next days
+func UpcomingBirthdays(log *slog.Logger, sub Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days := int(queryInt(r, "days", defaultUpcomingDays))
+
+		birthdays, err := sub.UpcomingBirthdays(callerID(r), days)
+		if err != nil {
+			log.Error("failed to list upcoming birthdays", sl.Err(err))
+			respondInternalError(w, r)
+			return
+		}
+
+		render.JSON(w, r, birthdayListResponse{Items: birthdays})
+	}
+}
+
+func respondSubscriptionError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, subscribe.ErrSelfSubscription):
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+	case errors.Is(err, subscribe.ErrNotActivated):
+		respondError(w, r, http.StatusForbidden, codeForbidden, err.Error())
+	case errors.Is(err, subscribe.ErrUserNotFound), errors.Is(err, subscribe.ErrSubscriptionNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, subscribe.ErrAlreadySubscribed):
+		respondError(w, r, http.StatusConflict, codeConflict, err.Error())
+	default:
+		log.Error("failed to process subscription", sl.Err(err))
+		respondInternalError(w, r)
+	}
+}
@@ -0,0 +1,165 @@
+package v1
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/users"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type UserManager interface {
+	List(filter models.UserFilter, cursor string, limit int) (users.Page, error)
+	User(id int64) (models.UserInfo, error)
+	UserByEmail(email string) (models.UserInfo, error)
+	UpdateProfile(id int64, upd users.ProfileUpdate) (models.UserInfo, error)
+	Deactivate(id int64) (models.UserInfo, error)
+}
+
+type userResponse struct {
+	models.User
+	IsActivated   bool                  `json:"is_activated"`
+	Organizations []models.Organization `json:"organizations"`
+}
+
+type userListResponse struct {
+	Items      []userResponse `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type userUpdateRequest struct {
+	FirstName  *string `json:"first_name"`
+	LastName   *string `json:"last_name"`
+	Patronymic *string `json:"patronymic"`
+	BirthDate  *string `json:"birth_date"`
+}
+
+// ListUsers returns users page filtered by organization and activation status.
+// Query parameters are checked by Validate
+func ListUsers(log *slog.Logger, um UserManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "http.v1.api.ListUsers"
+
+		log := log.With(slog.String("fn", fn))
+
+		q := r.URL.Query()
+		filter := models.UserFilter{
+			OrganizationID: queryInt(r, "organization_id", 0),
+			Organization:   q.Get("organization"),
+			City:           q.Get("city"),
+			Office:         q.Get("office"),
+			Department:     q.Get("department"),
+			Activated:      queryBool(r, "activated"),
+		}
+
+		// 0 is the default limit of the service
+		limit := int(queryInt(r, "limit", 0))
+
+		page, err := um.List(filter, q.Get("cursor"), limit)
+		if errors.Is(err, users.ErrInvalidCursor) {
+			respondError(w, r, http.StatusBadRequest, codeBadRequest, "invalid cursor")
+			return
+		}
+		if err != nil {
+			log.Error("failed to list users", sl.Err(err))
+			respondInternalError(w, r)
+			return
+		}
+
+		resp := userListResponse{
+			Items:      make([]userResponse, 0, len(page.Users)),
+			NextCursor: page.NextCursor,
+		}
+		for _, info := range page.Users {
+			resp.Items = append(resp.Items, newUserResponse(info))
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
+
+// GetUser returns user by ID
+func GetUser(log *slog.Logger, um UserManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := um.User(pathInt(r, "id"))
+		respondUser(w, r, log, info, err)
+	}
+}
+
+// GetUserByEmail returns user by email
+func GetUserByEmail(log *slog.Logger, um UserManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := um.UserByEmail(chi.URLParam(r, "email"))
+		respondUser(w, r, log, info, err)
+	}
+}
+
+// UpdateUser changes user profile fields
+func UpdateUser(log *slog.Logger, um UserManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req userUpdateRequest
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			respondError(w, r, http.StatusBadRequest, codeBadRequest, "invalid JSON body")
+			return
+		}
+
+		upd := users.ProfileUpdate{
+			FirstName:  req.FirstName,
+			LastName:   req.LastName,
+			Patronymic: req.Patronymic,
+		}
+		if req.BirthDate != nil {
+			birthDate, err := time.Parse(time.DateOnly, *req.BirthDate)
+			if err != nil {
+				respondError(w, r, http.StatusBadRequest, codeBadRequest, "birth_date must be a date in YYYY-MM-DD format")
+				return
+			}
+			upd.BirthDate = &birthDate
+		}
+
+		info, err := um.UpdateProfile(pathInt(r, "id"), upd)
+		respondUser(w, r, log, info, err)
+	}
+}
+
+// DeactivateUser deactivates user account
+func DeactivateUser(log *slog.Logger, um UserManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := um.Deactivate(pathInt(r, "id"))
+		respondUser(w, r, log, info, err)
+	}
+}
+
+func respondUser(w http.ResponseWriter, r *http.Request, log *slog.Logger, info models.UserInfo, err error) {
+	switch {
+	case errors.Is(err, users.ErrUserNotFound):
+		respondError(w, r, http.StatusNotFound, codeNotFound, "user not found")
+	case errors.Is(err, users.ErrInvalidInput):
+		respondError(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+	case err != nil:
+		log.Error("failed to process user request", sl.Err(err))
+		respondInternalError(w, r)
+	default:
+		render.JSON(w, r, newUserResponse(info))
+	}
+}
+
+func newUserResponse(info models.UserInfo) userResponse {
+	resp := userResponse{
+		User:          info.User,
+		Organizations: info.Organizations,
+	}
+
+	for _, m := range info.Messengers {
+		if m.IsActivated {
+			resp.IsActivated = true
+		}
+	}
+
+	return resp
+}
@@ -1,108 +1,266 @@
 package telegram
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sync/atomic"
+	"time"
 
-	"github.com/arxonic/gmh/internal/controllers/telegram/states"
+	"github.com/arxonic/gmh/internal/controllers/conversation"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/services/users"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 const MessengerType = "telegram"
 
+// Modes of receiving updates
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// pollRetry is the pause after a failed getUpdates request
+const pollRetry = 3 * time.Second
+
+// Bot receives updates of Telegram and is the Messenger of the conversation held there:
+// screens are messages with inline keyboards, their choices are pressed as callback queries
 type Bot struct {
-	bot *tgbotapi.BotAPI
-	log *slog.Logger
+	bot         *tgbotapi.BotAPI
+	log         *slog.Logger
+	pollTimeout time.Duration
+	catalog     *i18n.Catalog
+
+	// workers handle updates concurrently, each has a queue of queueSize updates
+	workers   int
+	queueSize int
+
+	// lastPoll is the unix time in nanoseconds of the last successful getUpdates request
+	lastPoll atomic.Int64
+	// dispatched is the ID of the last update passed to the workers
+	dispatched atomic.Int64
+
+	// webhook is set when updates are received by webhook instead of long polling
+	webhook *webhook
+
+	// sender sends all messages of the bot, see RunSender
+	sender *sender
 }
 
-func NewBot(tgBotKey string, log *slog.Logger) (*Bot, error) {
+// NewBot returns the bot receiving updates by long polling with pollTimeout, see StartWebhook to use webhook.
+// Updates are handled by workers, updates of one user are handled in order by the same worker.
+// Messages are sent within limits by the same number of workers, chats of users who blocked the bot are marked by rk.
+// Descriptions of commands in the Telegram menu are taken from the catalog
+func NewBot(tgBotKey string, log *slog.Logger, pollTimeout time.Duration, workers, queueSize int, limits SendLimits, rk ReachabilityKeeper, catalog *i18n.Catalog) (*Bot, error) {
 	bot, err := tgbotapi.NewBotAPI(tgBotKey)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Bot{
-		bot: bot,
-		log: log,
+		bot:         bot,
+		log:         log,
+		pollTimeout: pollTimeout,
+		catalog:     catalog,
+		workers:     workers,
+		queueSize:   queueSize,
+		sender:      newSender(bot, log, limits, rk, workers, queueSize),
 	}, nil
 }
 
-func (b *Bot) Run(states *states.States, uf UserFinder, ua UserAuther, emp Employer) {
-	u := tgbotapi.NewUpdate(0)
-
-	u.Timeout = 60 // TODO save to config
+// RunSender sends messages of the bot until CloseSender is called and all queued messages are sent
+func (b *Bot) RunSender(ctx context.Context) error {
+	return b.sender.run(ctx)
+}
 
-	updates := b.bot.GetUpdatesChan(u)
+// CloseSender stops accepting messages, sending them fails with ErrSenderClosed
+func (b *Bot) CloseSender(context.Context) error {
+	b.sender.close()
 
-	for update := range updates {
-		m := update.Message
-		if m == nil {
-			continue
-		}
+	return nil
+}
 
-		b.handleState(m, states, uf, ua, emp)
-	}
+// Conversation handles messages and button presses of users, see conversation.Conversation
+type Conversation interface {
+	HandleMessage(m conversation.Message)
+	HandlePress(p conversation.Press) string
+	Commands() []conversation.Command
 }
 
-func (b *Bot) handleState(m *tgbotapi.Message, s *states.States, uf UserFinder, ua UserAuther, emp Employer) {
-	userID := m.From.ID
+// Run passes updates to the conversation until ctx is done. Updates passed to the workers are handled before return
+func (b *Bot) Run(ctx context.Context, conv Conversation) error {
+	const fn = "telegram.Run"
 
-	// Инициализация состояния пользователя, если его еще нет
-	if _, ok := s.Load(userID); !ok {
-		s.Store(userID, &states.UserState{State: states.StateAuthMiddleware})
+	if err := b.registerCommands(conv.Commands()); err != nil {
+		b.log.Warn("failed to register bot commands", slog.String("fn", fn), sl.Err(err))
 	}
 
-	state, _ := s.Load(userID)
+	d := newDispatcher(b.workers, b.queueSize, func(update tgbotapi.Update) {
+		b.handleUpdate(update, conv)
+	})
+	registerQueueMetric(d)
 
-	newState := state.State
-	var err error
+	updates := b.webhookUpdates()
+	if updates == nil {
+		updates = b.poll(ctx)
+	}
 
-	switch state.State {
-	case states.StateAuthMiddleware:
-		newState, err = b.Auth(m, ua)
-		if err != nil {
-			return
+	for {
+		select {
+		case <-ctx.Done():
+			b.finish(updates, d)
+			return nil
+		case update := <-updates:
+			d.dispatch(update)
+			b.dispatched.Store(int64(update.UpdateID))
 		}
+	}
+}
 
-	case states.StateEmailWait:
-		newState, err = b.EmailWait(m, emp, ua)
-		if err != nil {
-			return
-		}
+// finish completes receiving updates on shutdown. Webhook updates are already accepted by Telegram,
+// so buffered ones are handled too. Workers finish their queues, then polled updates are confirmed
+// up to the last dispatched one, not received updates are received again after restart
+func (b *Bot) finish(updates <-chan tgbotapi.Update, d *dispatcher) {
+	const fn = "telegram.finish"
 
-	case states.StateMenu:
-		newState, err = b.MenuHandler(m, uf)
-		if err != nil {
-			return
+	if b.webhook != nil {
+		for drained := false; !drained; {
+			select {
+			case update := <-updates:
+				d.dispatch(update)
+			default:
+				drained = true
+			}
 		}
+	}
 
-	case states.StateFind:
-		newState, err = b.FinderHandler(m, uf, state)
-		if err != nil {
-			return
-		}
+	d.close()
 
+	last := b.dispatched.Load()
+	if b.webhook != nil || last == 0 {
+		return
 	}
 
-	state.State = newState
-	s.Store(userID, state)
-	// s.UserStates[userID].State = newState
+	u := tgbotapi.NewUpdate(int(last) + 1)
+	u.Limit = 1
+	u.Timeout = 0
+
+	if _, err := b.bot.GetUpdates(u); err != nil {
+		b.log.Warn("failed to confirm handled updates", slog.String("fn", fn), sl.Err(err))
+	}
 }
 
-func (b *Bot) SendMessage(m *tgbotapi.Message, text string) error {
-	msg := tgbotapi.NewMessage(m.Chat.ID, text)
-	msg.ReplyToMessageID = m.MessageID
+func (b *Bot) handleUpdate(update tgbotapi.Update, conv Conversation) {
+	if q := update.CallbackQuery; q != nil {
+		b.answer(q, conv.HandlePress(press(q)))
+		return
+	}
+
+	if update.MyChatMember != nil {
+		b.handleMembership(update.MyChatMember)
+		return
+	}
 
-	_, err := b.bot.Send(msg)
+	m := update.Message
+	if m == nil {
+		return
+	}
 
-	return err
+	conv.HandleMessage(message(m))
 }
 
-func (b *Bot) SendKeyboardMessage(m *tgbotapi.Message, text string, markup interface{}) error {
-	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+// handleMembership marks the private chat unreachable when the user blocks the bot and reachable again after unblocking
+func (b *Bot) handleMembership(u *tgbotapi.ChatMemberUpdated) {
+	const fn = "telegram.handleMembership"
 
-	msg.ReplyMarkup = markup
+	if !u.Chat.IsPrivate() {
+		return
+	}
+
+	var reachable bool
+	switch u.NewChatMember.Status {
+	case "kicked":
+		reachable = false
+	case "member":
+		reachable = true
+	default:
+		return
+	}
+
+	err := b.sender.rk.SetReachable(MessengerType, u.Chat.ID, reachable)
+	if err != nil && !errors.Is(err, users.ErrUserNotFound) {
+		b.log.Error("failed to save chat reachability", slog.String("fn", fn), slog.Bool("reachable", reachable), sl.Err(err))
+	}
+}
+
+// poll long-polls getUpdates until ctx is done and remembers the time of each successful request
+func (b *Bot) poll(ctx context.Context) <-chan tgbotapi.Update {
+	const fn = "telegram.poll"
+
+	log := b.log.With(slog.String("fn", fn))
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = int(b.pollTimeout.Seconds())
+
+	ch := make(chan tgbotapi.Update, b.bot.Buffer)
+
+	go func() {
+		for ctx.Err() == nil {
+			updates, err := b.bot.GetUpdates(u)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Warn("failed to get updates", sl.Err(err))
+				time.Sleep(pollRetry)
+				continue
+			}
+
+			b.lastPoll.Store(time.Now().UnixNano())
+
+			for _, update := range updates {
+				if update.UpdateID < u.Offset {
+					continue
+				}
+
+				select {
+				case ch <- update:
+					u.Offset = update.UpdateID + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Check reports whether getUpdates succeeded recently or the webhook is registered and delivers updates
+func (b *Bot) Check(ctx context.Context) error {
+	if b.webhook != nil {
+		return b.checkWebhook()
+	}
+
+	last := b.lastPoll.Load()
+	if last == 0 {
+		return errors.New("no successful getUpdates poll yet")
+	}
+
+	// Long poll returns at least every pollTimeout, so missing two polls means the bot is stuck
+	if age := time.Since(time.Unix(0, last)); age > 2*b.pollTimeout {
+		return fmt.Errorf("last successful getUpdates poll was %s ago", age.Round(time.Second))
+	}
+
+	return nil
+}
 
-	_, err := b.bot.Send(msg)
+// Notify sends text to the chat without reply to any message
+func (b *Bot) Notify(chatID int64, text string) error {
+	_, err := b.sender.send(chatID, tgbotapi.NewMessage(chatID, text))
 
 	return err
 }
@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"github.com/arxonic/gmh/internal/controllers/conversation"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerCommands shows the commands in the Telegram command menu: descriptions of each catalog language
+// to users with that client language and of the fallback language to others
+func (b *Bot) registerCommands(commands []conversation.Command) error {
+	if _, err := b.bot.Request(tgbotapi.NewSetMyCommands(botCommands(commands, b.catalog.Localizer())...)); err != nil {
+		return err
+	}
+
+	for _, lang := range b.catalog.Languages() {
+		cfg := tgbotapi.NewSetMyCommands(botCommands(commands, b.catalog.Localizer(lang))...)
+		cfg.LanguageCode = lang
+		if _, err := b.bot.Request(cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// botCommands returns the commands to register with setMyCommands
+func botCommands(commands []conversation.Command, l i18n.Localizer) []tgbotapi.BotCommand {
+	cmds := make([]tgbotapi.BotCommand, 0, len(commands))
+	for _, c := range commands {
+		cmds = append(cmds, tgbotapi.BotCommand{Command: c.Name, Description: l.T(c.Description)})
+	}
+
+	return cmds
+}
@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"strconv"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// dispatcher handles updates by a fixed number of workers. Updates of a user always go to the same worker,
+// so they are handled in order, while updates of different users are handled concurrently.
+// Each worker has a bounded queue, dispatch blocks while it is full, so receiving slows down with handling
+type dispatcher struct {
+	queues []chan tgbotapi.Update
+	wg     sync.WaitGroup
+}
+
+func newDispatcher(workers, queueSize int, handle func(tgbotapi.Update)) *dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	d := &dispatcher{queues: make([]chan tgbotapi.Update, workers)}
+
+	for i := range d.queues {
+		q := make(chan tgbotapi.Update, queueSize)
+		d.queues[i] = q
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for update := range q {
+				handle(update)
+			}
+		}()
+	}
+
+	return d
+}
+
+// dispatch queues the update to the worker of its user
+func (d *dispatcher) dispatch(update tgbotapi.Update) {
+	d.queues[updateUserID(update)%int64(len(d.queues))] <- update
+}
+
+// close stops the workers after they handle the queued updates. dispatch must not be called after close
+func (d *dispatcher) close() {
+	for _, q := range d.queues {
+		close(q)
+	}
+
+	d.wg.Wait()
+}
+
+// queued returns the number of updates waiting in each worker queue
+func (d *dispatcher) queued() map[string]float64 {
+	values := make(map[string]float64, len(d.queues))
+	for i, q := range d.queues {
+		values[strconv.Itoa(i)] = float64(len(q))
+	}
+
+	return values
+}
+
+// updateUserID returns the non-negative ID of the user who sent the update, 0 for updates without a user
+func updateUserID(update tgbotapi.Update) int64 {
+	var id int64
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		id = update.Message.From.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		id = update.CallbackQuery.From.ID
+	case update.MyChatMember != nil:
+		id = update.MyChatMember.From.ID
+	}
+
+	if id < 0 {
+		return -id
+	}
+
+	return id
+}
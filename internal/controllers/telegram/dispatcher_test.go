@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func messageUpdate(updateID int, userID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message:  &tgbotapi.Message{From: &tgbotapi.User{ID: userID}},
+	}
+}
+
+func TestDispatchUserOrder(t *testing.T) {
+	var (
+		mx      sync.Mutex
+		handled = make(map[int64][]int)
+	)
+
+	d := newDispatcher(4, 2, func(u tgbotapi.Update) {
+		// Handling time differs between users, updates of other users overtake each other
+		time.Sleep(time.Duration(u.Message.From.ID) * time.Millisecond)
+
+		mx.Lock()
+		defer mx.Unlock()
+		handled[u.Message.From.ID] = append(handled[u.Message.From.ID], u.UpdateID)
+	})
+
+	const perUser = 20
+	for i := 0; i < perUser; i++ {
+		for user := int64(1); user <= 5; user++ {
+			d.dispatch(messageUpdate(i, user))
+		}
+	}
+	d.close()
+
+	for user := int64(1); user <= 5; user++ {
+		got := handled[user]
+		if len(got) != perUser {
+			t.Fatalf("user %d: handled %d updates, want %d", user, len(got), perUser)
+		}
+		for i, id := range got {
+			if id != i {
+				t.Fatalf("user %d: handled %v, want the order of dispatch", user, got)
+			}
+		}
+	}
+}
+
+func TestDispatchQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	d := newDispatcher(1, 1, func(tgbotapi.Update) { <-release })
+
+	// The first update is handled, the second waits in the queue, the third blocks dispatch
+	d.dispatch(messageUpdate(1, 1))
+	d.dispatch(messageUpdate(2, 1))
+
+	dispatched := make(chan struct{})
+	go func() {
+		d.dispatch(messageUpdate(3, 1))
+		close(dispatched)
+	}()
+
+	select {
+	case <-dispatched:
+		t.Fatal("dispatch returned while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if got := d.queued()["0"]; got != 1 {
+		t.Errorf("queued = %v, want 1", got)
+	}
+
+	close(release)
+
+	select {
+	case <-dispatched:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch is still blocked after the worker freed the queue")
+	}
+
+	d.close()
+}
+
+func TestUpdateUserID(t *testing.T) {
+	tests := []struct {
+		name   string
+		update tgbotapi.Update
+		want   int64
+	}{
+		{"message", messageUpdate(1, 42), 42},
+		{"callback", tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 7}}}, 7},
+		{"negative", messageUpdate(1, -42), 42},
+		{"no user", tgbotapi.Update{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updateUserID(tt.update); got != tt.want {
+				t.Errorf("updateUserID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package telegram
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackVersion is the first field of callback data. It is changed with the data format,
+// so buttons of messages sent by an older version are treated as stale
+const callbackVersion = "1"
+
+// Type returns the messenger type of Telegram accounts
+func (b *Bot) Type() string {
+	return MessengerType
+}
+
+// Reply sends the text in reply to the message
+func (b *Bot) Reply(m conversation.Message, text string) error {
+	msg := tgbotapi.NewMessage(m.ChatID, text)
+	msg.ReplyToMessageID = m.ID
+
+	_, err := b.sender.send(m.ChatID, msg)
+
+	return err
+}
+
+// ShowScreen sends the screen as a new message, a screen without choices removes the keyboard
+func (b *Bot) ShowScreen(chatID int64, sc conversation.Screen) (int, error) {
+	msg := tgbotapi.NewMessage(chatID, sc.Text)
+	if len(sc.Choices) > 0 {
+		msg.ReplyMarkup = keyboard(sc)
+	} else {
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(false)
+	}
+
+	sent, err := b.sender.send(chatID, msg)
+	if err != nil {
+		return 0, err
+	}
+
+	return sent.MessageID, nil
+}
+
+// ReplaceScreen edits the message of the screen
+func (b *Bot) ReplaceScreen(chatID int64, screenID int, sc conversation.Screen) error {
+	var edit tgbotapi.EditMessageTextConfig
+	if len(sc.Choices) > 0 {
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, screenID, sc.Text, keyboard(sc))
+	} else {
+		edit = tgbotapi.NewEditMessageText(chatID, screenID, sc.Text)
+	}
+
+	_, err := b.sender.send(chatID, edit)
+	// Telegram refuses to edit a message without changes, that is not an error for the user
+	if err != nil && notModified(err) {
+		return nil
+	}
+
+	return err
+}
+
+// SendFile sends the data as a document
+func (b *Bot) SendFile(chatID int64, name string, data []byte) error {
+	_, err := b.sender.send(chatID, tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data}))
+
+	return err
+}
+
+// keyboard returns inline buttons of the choices, callback data is "<version>:<value>" and must fit 64 bytes
+func keyboard(sc conversation.Screen) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(sc.Choices))
+	for _, choices := range sc.Choices {
+		row := make([]tgbotapi.InlineKeyboardButton, 0, len(choices))
+		for _, c := range choices {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(c.Label, callbackVersion+":"+c.Value))
+		}
+		rows = append(rows, row)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// message returns the text message of the update
+func message(m *tgbotapi.Message) conversation.Message {
+	return conversation.Message{
+		ID:           m.MessageID,
+		ChatID:       m.Chat.ID,
+		UserID:       m.From.ID,
+		Text:         m.Text,
+		LanguageCode: m.From.LanguageCode,
+	}
+}
+
+// press returns the choice of the pressed button. Buttons of older versions have an empty value,
+// the conversation treats them as stale
+func press(q *tgbotapi.CallbackQuery) conversation.Press {
+	p := conversation.Press{
+		ChatID:       q.From.ID,
+		UserID:       q.From.ID,
+		LanguageCode: q.From.LanguageCode,
+	}
+
+	if q.Message != nil {
+		p.ChatID = q.Message.Chat.ID
+		p.ScreenID = q.Message.MessageID
+	}
+
+	if value, ok := strings.CutPrefix(q.Data, callbackVersion+":"); ok {
+		p.Value = value
+	}
+
+	return p
+}
+
+// answer stops the loading animation of the button, text is shown as a notice if it is set
+func (b *Bot) answer(q *tgbotapi.CallbackQuery, text string) {
+	const fn = "telegram.answer"
+
+	if _, err := b.bot.Request(tgbotapi.NewCallback(q.ID, text)); err != nil {
+		b.log.Warn("failed to answer callback query", slog.String("fn", fn), sl.Err(err))
+	}
+}
@@ -0,0 +1,13 @@
+package telegram
+
+import (
+	"github.com/arxonic/gmh/internal/lib/metrics"
+)
+
+var sentTotal = metrics.NewCounterVec("bot_messages_sent_total",
+	"Telegram messages by sending result, retried counts every retry.", "result")
+
+// registerQueueMetric exposes the number of updates waiting in each worker queue
+func registerQueueMetric(d *dispatcher) {
+	metrics.NewGaugeFunc("bot_dispatch_queue", "Telegram updates waiting for handling by worker.", "worker", d.queued)
+}
@@ -0,0 +1,283 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/lib/ratelimit"
+	"github.com/arxonic/gmh/internal/services/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var ErrSenderClosed = errors.New("telegram sender is closed")
+
+// Sending results of the bot_messages_sent_total metric
+const (
+	sendSent        = "sent"
+	sendRetried     = "retried"
+	sendFailed      = "failed"
+	sendUnreachable = "unreachable"
+)
+
+// SendLimits limit outgoing messages. Telegram allows about 30 messages per second in total
+// and about one per second to a chat, more is answered by 429 with retry_after
+type SendLimits struct {
+	PerSecond     float64
+	ChatPerSecond float64
+	ChatBurst     int
+	// Attempts is the number of tries of a message failed by a network or server error.
+	// Backoff is the delay before the second try, it doubles before every next one
+	Attempts int
+	Backoff  time.Duration
+}
+
+// ReachabilityKeeper remembers chats of users who blocked the bot, notifications skip them
+type ReachabilityKeeper interface {
+	SetReachable(messengerType string, chatID int64, reachable bool) error
+}
+
+type outgoing struct {
+	chatID int64
+	c      tgbotapi.Chattable
+	result chan sendResult
+}
+
+type sendResult struct {
+	msg tgbotapi.Message
+	err error
+}
+
+// sender sends messages from the queues of workers, see run. Messages to one chat are sent in order
+// by the same worker. Every message waits for the global and the chat rate limits
+type sender struct {
+	api    *tgbotapi.BotAPI
+	log    *slog.Logger
+	limits SendLimits
+	rk     ReachabilityKeeper
+
+	global *ratelimit.Limiter
+	chats  *ratelimit.Limiter
+
+	// pausedUntil is the unix time in nanoseconds before which Telegram asked not to send anything
+	pausedUntil atomic.Int64
+
+	mx     sync.RWMutex
+	closed bool
+	queues []chan outgoing
+}
+
+func newSender(api *tgbotapi.BotAPI, log *slog.Logger, limits SendLimits, rk ReachabilityKeeper, workers, queueSize int) *sender {
+	queues := make([]chan outgoing, workers)
+	for i := range queues {
+		queues[i] = make(chan outgoing, queueSize)
+	}
+
+	return &sender{
+		api:    api,
+		log:    log,
+		limits: limits,
+		rk:     rk,
+		global: ratelimit.New(limits.PerSecond, max(1, int(limits.PerSecond))),
+		chats:  ratelimit.New(limits.ChatPerSecond, limits.ChatBurst),
+		queues: queues,
+	}
+}
+
+// send puts the message into the queue of the chat and waits until it is sent or fails
+func (s *sender) send(chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	out := outgoing{chatID: chatID, c: c, result: make(chan sendResult, 1)}
+
+	s.mx.RLock()
+	if s.closed {
+		s.mx.RUnlock()
+		return tgbotapi.Message{}, ErrSenderClosed
+	}
+
+	if chatID < 0 {
+		chatID = -chatID
+	}
+	s.queues[chatID%int64(len(s.queues))] <- out
+	s.mx.RUnlock()
+
+	r := <-out.result
+
+	return r.msg, r.err
+}
+
+// run sends queued messages until close is called and the queues are empty.
+// Messages left when ctx is done fail with the error of ctx
+func (s *sender) run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, queue := range s.queues {
+		wg.Add(1)
+		go func(queue <-chan outgoing) {
+			defer wg.Done()
+
+			for out := range queue {
+				msg, err := s.deliver(ctx, out)
+				out.result <- sendResult{msg: msg, err: err}
+			}
+		}(queue)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting messages, run returns after the queued ones are sent
+func (s *sender) close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	for _, queue := range s.queues {
+		close(queue)
+	}
+}
+
+// deliver sends the message. Flood control answers are retried after retry_after, network and server
+// errors are retried with exponential backoff. The chat is marked unreachable if the user blocked the bot
+func (s *sender) deliver(ctx context.Context, out outgoing) (tgbotapi.Message, error) {
+	const fn = "telegram.deliver"
+
+	log := s.log.With(slog.String("fn", fn), slog.Int64("chat_id", out.chatID))
+
+	failures := 0
+	for {
+		if err := s.wait(ctx, out.chatID); err != nil {
+			sentTotal.Inc(sendFailed)
+			return tgbotapi.Message{}, err
+		}
+
+		msg, err := s.api.Send(out.c)
+		if err == nil {
+			sentTotal.Inc(sendSent)
+			return msg, nil
+		}
+
+		var apiErr *tgbotapi.Error
+		isAPIErr := errors.As(err, &apiErr)
+
+		switch {
+		case isAPIErr && apiErr.Code == http.StatusTooManyRequests:
+			// Flood control applies to the whole bot, so every worker pauses
+			log.Warn("sending is rate limited by telegram", slog.Int("retry_after", apiErr.RetryAfter))
+			sentTotal.Inc(sendRetried)
+			s.pause(time.Duration(max(1, apiErr.RetryAfter)) * time.Second)
+			continue
+		case isAPIErr && chatClosed(apiErr):
+			sentTotal.Inc(sendUnreachable)
+			s.markUnreachable(out.chatID)
+			return tgbotapi.Message{}, fmt.Errorf("%w: %s", conversation.ErrChatUnreachable, err)
+		case isAPIErr && apiErr.Code < http.StatusInternalServerError:
+			// The request is wrong, it fails again on retry
+			sentTotal.Inc(sendFailed)
+			if !notModified(err) {
+				log.Warn("failed to send message", sl.Err(err))
+			}
+			return tgbotapi.Message{}, err
+		}
+
+		failures++
+		if failures >= s.limits.Attempts {
+			sentTotal.Inc(sendFailed)
+			log.Error("failed to send message", slog.Int("attempts", failures), sl.Err(err))
+			return tgbotapi.Message{}, err
+		}
+
+		log.Warn("failed to send message, retrying", slog.Int("attempt", failures), sl.Err(err))
+		sentTotal.Inc(sendRetried)
+
+		if err := sleep(ctx, s.limits.Backoff<<(failures-1)); err != nil {
+			sentTotal.Inc(sendFailed)
+			return tgbotapi.Message{}, err
+		}
+	}
+}
+
+// wait waits for the end of the flood control pause and for the rate limits
+func (s *sender) wait(ctx context.Context, chatID int64) error {
+	if err := sleep(ctx, time.Until(time.Unix(0, s.pausedUntil.Load()))); err != nil {
+		return err
+	}
+
+	if err := s.chats.Wait(ctx, strconv.FormatInt(chatID, 10)); err != nil {
+		return err
+	}
+
+	return s.global.Wait(ctx, "")
+}
+
+// pause stops sending for d, a longer pause already set is kept
+func (s *sender) pause(d time.Duration) {
+	until := time.Now().Add(d).UnixNano()
+	for {
+		current := s.pausedUntil.Load()
+		if current >= until || s.pausedUntil.CompareAndSwap(current, until) {
+			return
+		}
+	}
+}
+
+// markUnreachable saves that the chat is unreachable. Chats of not registered users are not saved
+func (s *sender) markUnreachable(chatID int64) {
+	const fn = "telegram.markUnreachable"
+
+	err := s.rk.SetReachable(MessengerType, chatID, false)
+	if err != nil && !errors.Is(err, users.ErrUserNotFound) {
+		s.log.Error("failed to mark chat unreachable", slog.String("fn", fn), slog.Int64("chat_id", chatID), sl.Err(err))
+	}
+}
+
+// chatClosed reports whether the user blocked the bot or deleted the account, nothing is delivered to the chat
+// after that. Other 403 answers, e.g. the bot can't start a conversation, are about the request
+func chatClosed(apiErr *tgbotapi.Error) bool {
+	return apiErr.Code == http.StatusForbidden &&
+		(strings.Contains(apiErr.Message, "bot was blocked by the user") || strings.Contains(apiErr.Message, "user is deactivated"))
+}
+
+// notModified reports whether Telegram refused to edit a message without changes
+func notModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
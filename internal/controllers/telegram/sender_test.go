@@ -0,0 +1,191 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arxonic/gmh/internal/controllers/conversation"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeAPI answers sendMessage with the queued answers, the last one repeats
+type fakeAPI struct {
+	mx      sync.Mutex
+	answers []tgbotapi.APIResponse
+	calls   []time.Time
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/getMe") {
+		_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: json.RawMessage(`{"id":1,"is_bot":true}`)})
+		return
+	}
+
+	f.mx.Lock()
+	f.calls = append(f.calls, time.Now())
+	answer := f.answers[0]
+	if len(f.answers) > 1 {
+		f.answers = f.answers[1:]
+	}
+	f.mx.Unlock()
+
+	_ = json.NewEncoder(w).Encode(answer)
+}
+
+func (f *fakeAPI) sent() []time.Time {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	return f.calls
+}
+
+type fakeReachability struct {
+	mx          sync.Mutex
+	unreachable []int64
+}
+
+func (f *fakeReachability) SetReachable(_ string, chatID int64, reachable bool) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if !reachable {
+		f.unreachable = append(f.unreachable, chatID)
+	}
+	return nil
+}
+
+var okAnswer = tgbotapi.APIResponse{Ok: true, Result: json.RawMessage(`{"message_id":1}`)}
+
+func apiError(code int, description string, retryAfter int) tgbotapi.APIResponse {
+	answer := tgbotapi.APIResponse{ErrorCode: code, Description: description}
+	if retryAfter > 0 {
+		answer.Parameters = &tgbotapi.ResponseParameters{RetryAfter: retryAfter}
+	}
+
+	return answer
+}
+
+// newTestSender runs a sender against a fake Telegram API answering with answers
+func newTestSender(t *testing.T, answers ...tgbotapi.APIResponse) (*sender, *fakeAPI, *fakeReachability) {
+	t.Helper()
+
+	api := &fakeAPI{answers: answers}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rk := &fakeReachability{}
+	limits := SendLimits{PerSecond: 1000, ChatPerSecond: 1000, ChatBurst: 100, Attempts: 3, Backoff: 20 * time.Millisecond}
+	s := newSender(bot, slog.New(slog.NewTextHandler(io.Discard, nil)), limits, rk, 2, 10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.run(context.Background())
+	}()
+	t.Cleanup(func() {
+		s.close()
+		<-done
+	})
+
+	return s, api, rk
+}
+
+func TestSenderRetryAfter(t *testing.T) {
+	s, api, _ := newTestSender(t, apiError(http.StatusTooManyRequests, "Too Many Requests: retry after 1", 1), okAnswer)
+
+	if _, err := s.send(42, tgbotapi.NewMessage(42, "hi")); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	calls := api.sent()
+	if len(calls) != 2 {
+		t.Fatalf("sent %d times, want 2", len(calls))
+	}
+	if gap := calls[1].Sub(calls[0]); gap < time.Second {
+		t.Errorf("retried after %s, want retry_after of 1s", gap)
+	}
+}
+
+func TestSenderBackoff(t *testing.T) {
+	serverErr := apiError(http.StatusInternalServerError, "Internal Server Error", 0)
+
+	t.Run("recovered", func(t *testing.T) {
+		s, api, _ := newTestSender(t, serverErr, serverErr, okAnswer)
+
+		if _, err := s.send(42, tgbotapi.NewMessage(42, "hi")); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+
+		calls := api.sent()
+		if len(calls) != 3 {
+			t.Fatalf("sent %d times, want 3", len(calls))
+		}
+		// The backoff doubles: 20ms before the second try, 40ms before the third
+		if gap := calls[2].Sub(calls[1]); gap < 40*time.Millisecond {
+			t.Errorf("third try after %s, want at least 40ms", gap)
+		}
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		s, api, _ := newTestSender(t, serverErr)
+
+		_, err := s.send(42, tgbotapi.NewMessage(42, "hi"))
+		var apiErr *tgbotapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusInternalServerError {
+			t.Fatalf("send: %v, want the server error", err)
+		}
+
+		if n := len(api.sent()); n != 3 {
+			t.Errorf("sent %d times, want 3 attempts", n)
+		}
+	})
+}
+
+func TestSenderForbidden(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		unreachable bool
+	}{
+		{"blocked", "Forbidden: bot was blocked by the user", true},
+		{"deactivated", "Forbidden: user is deactivated", true},
+		{"not started", "Forbidden: bot can't initiate conversation with a user", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, api, rk := newTestSender(t, apiError(http.StatusForbidden, tt.description, 0))
+
+			_, err := s.send(42, tgbotapi.NewMessage(42, "hi"))
+			if err == nil {
+				t.Fatal("send succeeded, want an error")
+			}
+			if got := errors.Is(err, conversation.ErrChatUnreachable); got != tt.unreachable {
+				t.Errorf("ErrChatUnreachable = %v, want %v", got, tt.unreachable)
+			}
+
+			marked := len(rk.unreachable) > 0
+			if marked != tt.unreachable {
+				t.Errorf("chat marked unreachable = %v, want %v", marked, tt.unreachable)
+			}
+
+			if n := len(api.sent()); n != 1 {
+				t.Errorf("sent %d times, want no retries", n)
+			}
+		})
+	}
+}
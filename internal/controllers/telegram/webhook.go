@@ -0,0 +1,152 @@
+package telegram
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SecretHeader contains the secret token passed to setWebhook in every webhook request
+const SecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookBuffer is the number of received updates waiting for handling
+const webhookBuffer = 100
+
+// secretPattern is the format of the secret token accepted by Telegram
+var secretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+type webhook struct {
+	url     *url.URL
+	secret  string
+	updates chan tgbotapi.Update
+}
+
+// StartWebhook registers the webhook with Telegram, after that updates are received only by WebhookHandler.
+// Must be called before Run
+func (b *Bot) StartWebhook(webhookURL, secret string) error {
+	const fn = "telegram.StartWebhook"
+
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s: webhook URL must be an absolute https URL", fn)
+	}
+
+	if !secretPattern.MatchString(secret) {
+		return fmt.Errorf("%s: secret must be 1-256 characters A-Z, a-z, 0-9, _ or -", fn)
+	}
+
+	// The library doesn't support secret_token yet, so the request is made directly
+	params := tgbotapi.Params{}
+	params["url"] = u.String()
+	params["secret_token"] = secret
+	if _, err := b.bot.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	b.webhook = &webhook{
+		url:     u,
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, webhookBuffer),
+	}
+
+	b.log.Info("telegram webhook registered", slog.String("url", u.Redacted()))
+
+	return nil
+}
+
+// StopWebhook deletes the webhook registration, pending updates are kept by Telegram
+func (b *Bot) StopWebhook() error {
+	const fn = "telegram.StopWebhook"
+
+	if b.webhook == nil {
+		return nil
+	}
+
+	if _, err := b.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	b.log.Info("telegram webhook deleted")
+
+	return nil
+}
+
+// WebhookPath returns the path of the registered webhook URL to route it
+func (b *Bot) WebhookPath() string {
+	if b.webhook == nil {
+		return ""
+	}
+
+	return b.webhook.url.Path
+}
+
+// WebhookHandler accepts updates sent by Telegram and passes them to Run
+func (b *Bot) WebhookHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const fn = "telegram.WebhookHandler"
+
+		log := b.log.With(slog.String("fn", fn))
+
+		if b.webhook == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		secret := r.Header.Get(SecretHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(b.webhook.secret)) != 1 {
+			log.Warn("webhook request with invalid secret token", slog.String("remote", r.RemoteAddr))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			log.Warn("failed to decode webhook update", sl.Err(err))
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		// Telegram retries the update if it isn't accepted in time
+		select {
+		case b.webhook.updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func (b *Bot) webhookUpdates() <-chan tgbotapi.Update {
+	if b.webhook == nil {
+		return nil
+	}
+
+	return b.webhook.updates
+}
+
+// checkWebhook reports whether the webhook is registered and the last delivery didn't fail recently
+func (b *Bot) checkWebhook() error {
+	info, err := b.bot.GetWebhookInfo()
+	if err != nil {
+		return err
+	}
+
+	if info.URL != b.webhook.url.String() {
+		return errors.New("webhook is not registered")
+	}
+
+	if info.LastErrorDate > 0 && time.Since(time.Unix(int64(info.LastErrorDate), 0)) < 2*b.pollTimeout {
+		return fmt.Errorf("last webhook delivery failed: %s", info.LastErrorMessage)
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package birthday
+
+import "time"
+
+// Next returns the date of the nearest birthday not before the day of now
+func Next(birthDate, now time.Time) time.Time {
+	t := Today(now)
+	b := time.Date(t.Year(), birthDate.Month(), birthDate.Day(), 0, 0, 0, 0, time.UTC)
+	if b.Before(t) {
+		b = b.AddDate(1, 0, 0)
+	}
+
+	return b
+}
+
+// DaysLeft returns the number of days from the day of now to the nearest birthday
+func DaysLeft(birthDate, now time.Time) int {
+	return int(Next(birthDate, now).Sub(Today(now)).Hours() / 24)
+}
+
+// Today returns the day of now as UTC midnight
+func Today(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -1,8 +1,26 @@
 package email
 
-import "net/mail"
+import (
+	"net/mail"
+	"strings"
+)
 
 func Valid(email string) bool {
 	_, err := mail.ParseAddress(email)
 	return err == nil
 }
+
+// Domain returns lower-cased domain part of the email or empty string if email is invalid
+func Domain(email string) string {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return ""
+	}
+
+	at := strings.LastIndex(addr.Address, "@")
+	if at < 0 {
+		return ""
+	}
+
+	return strings.ToLower(addr.Address[at+1:])
+}
@@ -0,0 +1,380 @@
+// Package i18n is the message catalog of user-facing texts. Bundles are files of the locales
+// directory, one per language. A message is a named text/template executed with the message
+// arguments, plural messages are templates "<key>.<form>" chosen by the "count" argument.
+// Templates of a bundle can be overridden from a directory without rebuilding the binary.
+package i18n
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed locales/*.tmpl
+var locales embed.FS
+
+// CountArg is the argument choosing the plural form of a message
+const CountArg = "count"
+
+// bundleExt is the extension of bundle and override files
+const bundleExt = ".tmpl"
+
+// Args are the values of template variables
+type Args map[string]any
+
+type Catalog struct {
+	fallback string
+	// bundles are the templates of each language named by message keys
+	bundles map[string]*template.Template
+	// defaults are the arguments of every message, e.g. the company name
+	defaults Args
+}
+
+// Load reads the embedded bundles and overrides them with <lang>.tmpl files of overridesDir, if it is set.
+// fallback is the language used when the user's language is unknown, every other bundle must have the same keys.
+// defaults are added to the arguments of each message
+func Load(fallback string, defaults Args, overridesDir string) (*Catalog, error) {
+	const fn = "i18n.Load"
+
+	c := &Catalog{
+		fallback: fallback,
+		bundles:  make(map[string]*template.Template),
+		defaults: defaults,
+	}
+
+	files, err := fs.Glob(locales, "locales/*"+bundleExt)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	for _, file := range files {
+		raw, err := locales.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		lang := strings.TrimSuffix(filepath.Base(file), bundleExt)
+		t, err := parseBundle(lang, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		c.bundles[lang] = t
+	}
+
+	if err := c.check(); err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	allowed := c.variables()
+
+	if overridesDir != "" {
+		if err := c.override(overridesDir, allowed); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+	}
+
+	if err := c.trial(allowed); err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return c, nil
+}
+
+// parseBundle parses the templates of the bundle, executing a template with a missing argument is an error
+func parseBundle(lang string, raw []byte) (*template.Template, error) {
+	t, err := template.New(lang).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", lang, err)
+	}
+
+	return t, nil
+}
+
+// check reports missing bundles, keys and plural forms
+func (c *Catalog) check() error {
+	base, ok := c.bundles[c.fallback]
+	if !ok {
+		return fmt.Errorf("no bundle of the fallback language %q", c.fallback)
+	}
+	baseKeys := keys(base)
+
+	problems := make([]string, 0)
+	for lang, t := range c.bundles {
+		rule, ok := pluralRules[lang]
+		if !ok {
+			problems = append(problems, lang+": no plural rule")
+			continue
+		}
+
+		langKeys := keys(t)
+		for key := range baseKeys {
+			if !langKeys[key] {
+				problems = append(problems, lang+": missing "+key)
+			}
+		}
+
+		for key := range langKeys {
+			if !baseKeys[key] {
+				problems = append(problems, lang+": unknown "+key)
+			}
+		}
+
+		for _, name := range names(t) {
+			key, form := splitForm(name)
+			if form == "" {
+				continue
+			}
+
+			if !rule.has(form) {
+				problems = append(problems, lang+": "+key+" has form "+form+" not used by the language")
+			}
+			for _, f := range rule.forms {
+				if t.Lookup(key+"."+f) == nil {
+					problems = append(problems, lang+": "+key+" has no form "+f)
+				}
+			}
+		}
+	}
+
+	return joinProblems("bundles are inconsistent", problems)
+}
+
+// override replaces templates by the ones of <lang>.tmpl files in dir. An override may only
+// redefine known messages and use the allowed variables of the message
+func (c *Catalog) override(dir string, allowed map[string]map[string]bool) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+bundleExt))
+	if err != nil {
+		return err
+	}
+
+	problems := make([]string, 0)
+	for _, file := range files {
+		lang := strings.TrimSuffix(filepath.Base(file), bundleExt)
+		bundle, ok := c.bundles[lang]
+		if !ok {
+			problems = append(problems, file+": unknown language "+lang)
+			continue
+		}
+
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		t, err := parseBundle(lang, raw)
+		if err != nil {
+			problems = append(problems, file+": "+err.Error())
+			continue
+		}
+
+		for _, name := range names(t) {
+			if bundle.Lookup(name) == nil {
+				problems = append(problems, file+": unknown message "+name)
+				continue
+			}
+
+			key, _ := splitForm(name)
+			vars := make(map[string]bool)
+			collectVars(t.Lookup(name).Tree.Root, t, vars, make(map[string]bool))
+			for v := range vars {
+				if !allowed[key][v] {
+					problems = append(problems, file+": "+name+" uses unknown variable ."+v)
+				}
+			}
+
+			if _, err := bundle.AddParseTree(name, t.Lookup(name).Tree); err != nil {
+				problems = append(problems, file+": "+name+": "+err.Error())
+			}
+		}
+	}
+
+	return joinProblems("invalid overrides", problems)
+}
+
+// variables returns the variables of each message: arguments used by its default templates in any language
+// and the default arguments
+func (c *Catalog) variables() map[string]map[string]bool {
+	allowed := make(map[string]map[string]bool)
+	for _, t := range c.bundles {
+		for _, name := range names(t) {
+			key, form := splitForm(name)
+			if allowed[key] == nil {
+				allowed[key] = make(map[string]bool)
+			}
+
+			collectVars(t.Lookup(name).Tree.Root, t, allowed[key], make(map[string]bool))
+			for v := range c.defaults {
+				allowed[key][v] = true
+			}
+			if form != "" {
+				allowed[key][CountArg] = true
+			}
+		}
+	}
+
+	return allowed
+}
+
+// trial executes every template with all its variables set, so templates failing at runtime
+// are found at startup
+func (c *Catalog) trial(allowed map[string]map[string]bool) error {
+	problems := make([]string, 0)
+	for lang, t := range c.bundles {
+		for _, name := range names(t) {
+			key, _ := splitForm(name)
+
+			args := make(Args, len(allowed[key]))
+			for v := range allowed[key] {
+				args[v] = v
+			}
+			args[CountArg] = 1
+
+			if err := t.ExecuteTemplate(&strings.Builder{}, name, args); err != nil {
+				problems = append(problems, lang+": "+err.Error())
+			}
+		}
+	}
+
+	return joinProblems("templates fail", problems)
+}
+
+// Languages returns the languages of the bundles
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.bundles))
+	for lang := range c.bundles {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	return langs
+}
+
+// Match returns the bundle language of the IETF language tag like "en-US", empty if there is no such bundle
+func (c *Catalog) Match(tag string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+	if _, ok := c.bundles[lang]; !ok {
+		return ""
+	}
+
+	return lang
+}
+
+// Localizer returns the localizer of the first supported language of langs, in order of preference.
+// The fallback language is used if none is supported
+func (c *Catalog) Localizer(langs ...string) Localizer {
+	for _, tag := range langs {
+		if lang := c.Match(tag); lang != "" {
+			return Localizer{catalog: c, lang: lang}
+		}
+	}
+
+	return Localizer{catalog: c, lang: c.fallback}
+}
+
+// Localizer formats messages of one language
+type Localizer struct {
+	catalog *Catalog
+	lang    string
+}
+
+func (l Localizer) Lang() string {
+	return l.lang
+}
+
+// T executes the template of the key with args.
+// Missing or failed messages fall back to the fallback language and then to the key itself
+func (l Localizer) T(key string, args ...Args) string {
+	values := make(Args, len(l.catalog.defaults))
+	for k, v := range l.catalog.defaults {
+		values[k] = v
+	}
+	for _, a := range args {
+		for k, v := range a {
+			values[k] = v
+		}
+	}
+
+	for _, lang := range []string{l.lang, l.catalog.fallback} {
+		t := l.catalog.bundles[lang]
+
+		name := key
+		if t.Lookup(key+"."+formOne) != nil {
+			name = key + "." + pluralRules[lang].form(count(values[CountArg]))
+		}
+
+		var sb strings.Builder
+		if err := t.ExecuteTemplate(&sb, name, values); err == nil {
+			return sb.String()
+		}
+	}
+
+	return key
+}
+
+// names returns the names of templates defined in the bundle
+func names(t *template.Template) []string {
+	names := make([]string, 0)
+	for _, tmpl := range t.Templates() {
+		if tmpl.Name() != t.Name() {
+			names = append(names, tmpl.Name())
+		}
+	}
+
+	return names
+}
+
+// keys returns the message keys of the bundle, plural forms are one key
+func keys(t *template.Template) map[string]bool {
+	keys := make(map[string]bool)
+	for _, name := range names(t) {
+		key, _ := splitForm(name)
+		keys[key] = true
+	}
+
+	return keys
+}
+
+// splitForm splits the template name to the message key and the plural form, if it is a plural form
+func splitForm(name string) (key, form string) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 || !isForm(name[i+1:]) {
+		return name, ""
+	}
+
+	return name[:i], name[i+1:]
+}
+
+func joinProblems(title string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return errors.New(title + ": " + strings.Join(problems, "; "))
+}
+
+func count(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+
+	return 0
+}
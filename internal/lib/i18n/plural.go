@@ -0,0 +1,65 @@
+package i18n
+
+// Plural forms, the names follow CLDR
+const (
+	formOne   = "one"
+	formFew   = "few"
+	formMany  = "many"
+	formOther = "other"
+)
+
+// pluralRule chooses the plural form of the number, forms are required in plural messages of the language
+type pluralRule struct {
+	forms []string
+	form  func(n int) string
+}
+
+var pluralRules = map[string]pluralRule{
+	"ru": {
+		forms: []string{formOne, formFew, formMany},
+		form: func(n int) string {
+			if n < 0 {
+				n = -n
+			}
+
+			switch {
+			case n%10 == 1 && n%100 != 11:
+				return formOne
+			case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+				return formFew
+			default:
+				return formMany
+			}
+		},
+	},
+	"en": {
+		forms: []string{formOne, formOther},
+		form: func(n int) string {
+			if n == 1 || n == -1 {
+				return formOne
+			}
+
+			return formOther
+		},
+	},
+}
+
+func (r pluralRule) has(form string) bool {
+	for _, f := range r.forms {
+		if f == form {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isForm reports whether the name is a plural form of any language
+func isForm(name string) bool {
+	switch name {
+	case formOne, formFew, formMany, formOther:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,55 @@
+package i18n
+
+import (
+	"text/template"
+	"text/template/parse"
+)
+
+// collectVars adds the top-level fields used by the template node to vars, like name of {{.name}}.
+// Templates called by {{template}} are followed, seen prevents endless recursion
+func collectVars(node parse.Node, t *template.Template, vars, seen map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectVars(child, t, vars, seen)
+		}
+	case *parse.ActionNode:
+		collectVars(n.Pipe, t, vars, seen)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectVars(cmd, t, vars, seen)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectVars(arg, t, vars, seen)
+		}
+	case *parse.ChainNode:
+		collectVars(n.Node, t, vars, seen)
+	case *parse.FieldNode:
+		vars[n.Ident[0]] = true
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, t, vars, seen)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, t, vars, seen)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, t, vars, seen)
+	case *parse.TemplateNode:
+		collectVars(n.Pipe, t, vars, seen)
+		if called := t.Lookup(n.Name); called != nil && !seen[n.Name] {
+			seen[n.Name] = true
+			collectVars(called.Tree.Root, t, vars, seen)
+		}
+	}
+}
+
+func collectBranch(n *parse.BranchNode, t *template.Template, vars, seen map[string]bool) {
+	collectVars(n.Pipe, t, vars, seen)
+	collectVars(n.List, t, vars, seen)
+	collectVars(n.ElseList, t, vars, seen)
+}
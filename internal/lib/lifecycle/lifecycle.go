@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+)
+
+var ErrStopTimeout = errors.New("component did not stop before the shutdown deadline")
+
+// RunFunc runs the component until it is stopped. It must return when ctx is done
+type RunFunc func(ctx context.Context) error
+
+// StopFunc asks the component to finish its work gracefully before ctx deadline
+type StopFunc func(ctx context.Context) error
+
+// forceTimeout is the time given to a component to return after its context is cancelled at the deadline
+const forceTimeout = time.Second
+
+type component struct {
+	name string
+	run  RunFunc
+	stop StopFunc
+}
+
+// Manager starts components in the order they are added and stops them in reverse order
+type Manager struct {
+	log        *slog.Logger
+	timeout    time.Duration
+	components []component
+}
+
+// New returns a new Manager, all components must stop within timeout after shutdown begins
+func New(log *slog.Logger, timeout time.Duration) *Manager {
+	return &Manager{
+		log:     log,
+		timeout: timeout,
+	}
+}
+
+// Add registers the component, run or stop may be nil.
+// On shutdown the component is stopped by stop if it is set, otherwise by cancelling the context of run.
+// The context of run is cancelled anyway when the shutdown deadline is exceeded
+func (m *Manager) Add(name string, run RunFunc, stop StopFunc) {
+	m.components = append(m.components, component{name: name, run: run, stop: stop})
+}
+
+type running struct {
+	cancel context.CancelFunc
+	done   chan error
+	// stopping is set before stop is called, run returning after that is not a failure
+	stopping *atomic.Bool
+}
+
+// Run starts all components and blocks until ctx is done or any component stops by itself,
+// then stops the components. Returns the error of the failed component or of the shutdown
+func (m *Manager) Run(ctx context.Context) error {
+	const fn = "lifecycle.Run"
+
+	log := m.log.With(slog.String("fn", fn))
+
+	failed := make(chan error, len(m.components))
+	started := make([]running, 0, len(m.components))
+
+	for _, c := range m.components {
+		// Components are stopped one by one on shutdown, so their contexts are not derived from ctx
+		cctx, cancel := context.WithCancel(context.Background())
+		r := running{cancel: cancel, done: make(chan error, 1), stopping: &atomic.Bool{}}
+
+		if c.run != nil {
+			go func(c component) {
+				err := c.run(cctx)
+				r.done <- err
+
+				if cctx.Err() == nil && !r.stopping.Load() {
+					if err == nil {
+						err = errors.New("stopped unexpectedly")
+					}
+					failed <- fmt.Errorf("%s: %w", c.name, err)
+				}
+			}(c)
+		} else {
+			close(r.done)
+		}
+
+		started = append(started, r)
+		log.Info("component started", slog.String("component", c.name))
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Info("shutdown requested")
+	case runErr = <-failed:
+		log.Error("component failed, shutting down", sl.Err(runErr))
+	}
+
+	stopErr := m.shutdown(log, started)
+
+	return errors.Join(runErr, stopErr)
+}
+
+// shutdown stops started components in reverse order within the timeout
+func (m *Manager) shutdown(log *slog.Logger, started []running) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c, r := m.components[i], started[i]
+		log := log.With(slog.String("component", c.name))
+
+		r.stopping.Store(true)
+		if c.stop != nil {
+			if err := c.stop(ctx); err != nil {
+				log.Error("failed to stop component", sl.Err(err))
+				errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			}
+		} else {
+			r.cancel()
+		}
+
+		stopped, err := r.wait(ctx)
+		if !stopped {
+			// Components still running at the deadline get a moment to give up their work
+			r.cancel()
+			forceCtx, cancelForce := context.WithTimeout(context.Background(), forceTimeout)
+			stopped, err = r.wait(forceCtx)
+			cancelForce()
+		}
+		r.cancel()
+		if !stopped {
+			log.Error("component did not stop in time")
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, ErrStopTimeout))
+			continue
+		}
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Warn("component stopped with error", sl.Err(err))
+		}
+
+		log.Info("component stopped")
+	}
+
+	return errors.Join(errs...)
+}
+
+// wait returns the result of run, stopped is false if it is still running when ctx is done
+func (r running) wait(ctx context.Context) (stopped bool, err error) {
+	select {
+	case err := <-r.done:
+		return true, err
+	case <-ctx.Done():
+	}
+
+	// The component may have stopped right at the deadline
+	select {
+	case err := <-r.done:
+		return true, err
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,201 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// events records the order of starts and stops
+type events struct {
+	mx   sync.Mutex
+	list []string
+}
+
+func (e *events) add(event string) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	e.list = append(e.list, event)
+}
+
+func (e *events) get() []string {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	return slices.Clone(e.list)
+}
+
+// server is a component like http.Server: run returns nil after stop
+type server struct {
+	name   string
+	events *events
+	done   chan struct{}
+}
+
+func newServer(name string, ev *events) *server {
+	return &server{name: name, events: ev, done: make(chan struct{})}
+}
+
+func (s *server) run(_ context.Context) error {
+	s.events.add("start " + s.name)
+	<-s.done
+	return nil
+}
+
+func (s *server) stop(_ context.Context) error {
+	s.events.add("stop " + s.name)
+	close(s.done)
+	return nil
+}
+
+func newTestManager(timeout time.Duration) *Manager {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), timeout)
+}
+
+func TestRunOrder(t *testing.T) {
+	ev := &events{}
+	m := newTestManager(time.Second)
+
+	for _, name := range []string{"storage", "bot", "http"} {
+		s := newServer(name, ev)
+		m.Add(name, s.run, s.stop)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- m.Run(ctx) }()
+
+	// Components run in their own goroutines, the start order is checked by TestRunStartOrder
+	deadline := time.Now().Add(time.Second)
+	for len(ev.get()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	if err := <-result; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got := ev.get()
+	if len(got) != 6 {
+		t.Fatalf("events = %v, want 3 starts and 3 stops", got)
+	}
+	if stops := got[3:]; !slices.Equal(stops, []string{"stop http", "stop bot", "stop storage"}) {
+		t.Errorf("stop order = %v, want reverse of the start order", stops)
+	}
+}
+
+// componentLog records the components in the "component started" records
+type componentLog struct {
+	slog.Handler
+	mx      sync.Mutex
+	started []string
+}
+
+func (h *componentLog) Handle(_ context.Context, r slog.Record) error {
+	if r.Message != "component started" {
+		return nil
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "component" {
+			h.mx.Lock()
+			h.started = append(h.started, a.Value.String())
+			h.mx.Unlock()
+		}
+		return true
+	})
+
+	return nil
+}
+
+func (h *componentLog) WithAttrs(_ []slog.Attr) slog.Handler { return h }
+
+func TestRunStartOrder(t *testing.T) {
+	h := &componentLog{Handler: slog.NewTextHandler(io.Discard, nil)}
+	m := New(slog.New(h), time.Second)
+
+	for _, name := range []string{"storage", "bot", "http"} {
+		m.Add(name, nil, nil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := []string{"storage", "bot", "http"}; !slices.Equal(h.started, want) {
+		t.Errorf("started %v, want %v", h.started, want)
+	}
+}
+
+func TestRunComponentFailed(t *testing.T) {
+	ev := &events{}
+	m := newTestManager(time.Second)
+
+	s := newServer("http", ev)
+	m.Add("http", s.run, s.stop)
+
+	failure := errors.New("listen failed")
+	m.Add("bot", func(_ context.Context) error { return failure }, nil)
+
+	err := m.Run(context.Background())
+	if !errors.Is(err, failure) {
+		t.Fatalf("Run: %v, want the failure of the component", err)
+	}
+	if !slices.Contains(ev.get(), "stop http") {
+		t.Error("other components are not stopped after a failure")
+	}
+}
+
+func TestRunStoppedUnexpectedly(t *testing.T) {
+	m := newTestManager(time.Second)
+	m.Add("worker", func(_ context.Context) error { return nil }, nil)
+
+	err := m.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run: nil, want the component stopped by itself reported")
+	}
+}
+
+func TestRunStopTimeout(t *testing.T) {
+	m := newTestManager(50 * time.Millisecond)
+
+	// The component ignores stop and returns only when its context is cancelled at the deadline
+	m.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, func(_ context.Context) error { return nil })
+
+	// The component does not return at all
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	m.Add("stuck", func(_ context.Context) error {
+		<-block
+		return nil
+	}, func(_ context.Context) error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := m.Run(ctx)
+
+	if !errors.Is(err, ErrStopTimeout) {
+		t.Fatalf("Run: %v, want %v", err, ErrStopTimeout)
+	}
+	if strings.Contains(err.Error(), "slow") || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("Run: %v, want only the stuck component timed out", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond+forceTimeout+500*time.Millisecond {
+		t.Errorf("shutdown took %s, want it bounded by the timeout", elapsed)
+	}
+}
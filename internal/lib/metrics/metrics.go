@@ -0,0 +1,321 @@
+// Package metrics implements counters, histograms and gauges exposed in the Prometheus text format.
+// Metrics created by the package functions are registered in Default
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Namespace prefixes names of all metrics
+const Namespace = "gmh"
+
+// DefBuckets are histogram buckets in seconds suitable for request latencies
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry served by Handler
+var Default = NewRegistry()
+
+type collector interface {
+	write(w io.Writer)
+}
+
+type Registry struct {
+	mx         sync.Mutex
+	names      []string
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// register adds the collector, a collector with the same name is replaced
+func (r *Registry) register(name string, c collector) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if _, ok := r.collectors[name]; !ok {
+		r.names = append(r.names, name)
+		sort.Strings(r.names)
+	}
+	r.collectors[name] = c
+}
+
+// WriteTo writes all metrics in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mx.Lock()
+	collectors := make([]collector, 0, len(r.names))
+	for _, name := range r.names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mx.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, c := range collectors {
+		c.write(cw)
+	}
+
+	return cw.n, cw.w.Flush()
+}
+
+// Handler serves metrics of the Default registry
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = Default.WriteTo(w)
+	})
+}
+
+// Since returns seconds elapsed since start, handy for histograms
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// CounterVec is a set of counters partitioned by label values
+type CounterVec struct {
+	desc
+	mx     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec creates a counter with the labels and registers it in Default
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: newDesc(name, help, "counter", labels), values: make(map[string]float64)}
+	// Counter without labels is exposed as zero before the first increment
+	if len(labels) == 0 {
+		c.values[""] = 0
+	}
+	Default.register(c.name, c)
+	return c
+}
+
+// Inc increments the counter with the label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with the label values
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.values[key] += v
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.header(w)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(key, "", ""), formatFloat(c.values[key]))
+	}
+}
+
+// HistogramVec is a set of histograms partitioned by label values
+type HistogramVec struct {
+	desc
+	mx      sync.Mutex
+	buckets []float64
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // cumulative counts are computed on write
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram with the buckets and labels and registers it in Default
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+
+	h := &HistogramVec{desc: newDesc(name, help, "histogram", labels), buckets: b, values: make(map[string]*histogram)}
+	if len(labels) == 0 {
+		h.values[""] = &histogram{counts: make([]uint64, len(b))}
+	}
+	Default.register(h.name, h)
+	return h
+}
+
+// Observe adds the observation to the histogram with the label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.header(w)
+
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hist := h.values[key]
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(key, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(key, "", ""), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(key, "", ""), hist.count)
+	}
+}
+
+// GaugeFunc is a gauge whose values by the label values are collected on each scrape
+type GaugeFunc struct {
+	desc
+	collect func() map[string]float64
+}
+
+// NewGaugeFunc creates a gauge with a single label, collect returns values by the label value.
+// Without label collect must return the value by the empty key
+func NewGaugeFunc(name, help, label string, collect func() map[string]float64) *GaugeFunc {
+	var labels []string
+	if label != "" {
+		labels = []string{label}
+	}
+
+	g := &GaugeFunc{desc: newDesc(name, help, "gauge", labels), collect: collect}
+	Default.register(g.name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	values := g.collect()
+
+	g.header(w)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(key, "", ""), formatFloat(values[key]))
+	}
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func newDesc(name, help, typ string, labels []string) desc {
+	return desc{name: Namespace + "_" + name, help: help, typ: typ, labels: labels}
+}
+
+func (d *desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// labelSep can't appear in valid UTF-8 label values
+const labelSep = "\xff"
+
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+
+	return strings.Join(labelValues, labelSep)
+}
+
+// labelPairs formats labels of the key with an optional extra label
+func (d *desc) labelPairs(key, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(d.labels)+1)
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, labelSep) {
+			pairs = append(pairs, d.labels[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,205 @@
+// Package openapi loads the subset of OpenAPI 3 documents used by the HTTP API
+// and validates incoming requests against it: path, query and header parameters
+// and JSON request bodies.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type Spec struct {
+	OpenAPI    string               `json:"openapi"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*Parameter `json:"parameters"`
+	} `json:"components"`
+
+	raw []byte
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get"`
+	Post   *Operation `json:"post"`
+	Put    *Operation `json:"put"`
+	Patch  *Operation `json:"patch"`
+	Delete *Operation `json:"delete"`
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []Parameter  `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"` // path, query or header
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+}
+
+// Route is a method and a path template of the operation
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Load parses the OpenAPI document
+func Load(raw []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("openapi.Load:%w", err)
+	}
+
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi.Load: unsupported version %q", spec.OpenAPI)
+	}
+
+	spec.raw = raw
+
+	return &spec, nil
+}
+
+// Raw returns the document as it was loaded
+func (s *Spec) Raw() []byte {
+	return s.raw
+}
+
+// Routes returns all operations of the document sorted by path and method
+func (s *Spec) Routes() []Route {
+	routes := make([]Route, 0)
+	for path, item := range s.Paths {
+		for method := range item.operations() {
+			routes = append(routes, Route{Method: method, Path: path})
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// Find returns the operation matching the request method and path with values of path parameters
+func (s *Spec) Find(method, path string) (*Operation, map[string]string, bool) {
+	segments := splitPath(path)
+
+	for template, item := range s.Paths {
+		op, ok := item.operations()[method]
+		if !ok {
+			continue
+		}
+
+		params, ok := matchPath(splitPath(template), segments)
+		if ok {
+			return op, params, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+func (p *PathItem) operations() map[string]*Operation {
+	ops := make(map[string]*Operation, 5)
+	for method, op := range map[string]*Operation{
+		http.MethodGet:    p.Get,
+		http.MethodPost:   p.Post,
+		http.MethodPut:    p.Put,
+		http.MethodPatch:  p.Patch,
+		http.MethodDelete: p.Delete,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+
+	return ops
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+		if !ok {
+			return nil
+		}
+		schema = s.Components.Schemas[name]
+	}
+
+	return schema
+}
+
+func (s *Spec) resolveParameter(p *Parameter) *Parameter {
+	for p != nil && p.Ref != "" {
+		name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+		if !ok {
+			return nil
+		}
+		p = s.Components.Parameters[name]
+	}
+
+	return p
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+func matchPath(template, segments []string) (map[string]string, bool) {
+	if len(template) != len(segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			params[t[1:len(t)-1]] = segments[i]
+			continue
+		}
+
+		if t != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
@@ -0,0 +1,306 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/email"
+)
+
+// MaxBodySize limits the size of validated request bodies
+const MaxBodySize = 1 << 20
+
+// FieldError describes a single invalid part of the request
+type FieldError struct {
+	In     string `json:"in"` // path, query, header or body
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Validate checks parameters and JSON body of the request against the operation.
+// The body is read and replaced, so handlers can decode it again
+func (s *Spec) Validate(r *http.Request, op *Operation, pathParams map[string]string) []FieldError {
+	errs := make([]FieldError, 0)
+
+	for i := range op.Parameters {
+		p := s.resolveParameter(&op.Parameters[i])
+		if p == nil {
+			continue
+		}
+
+		value, present := "", false
+		switch p.In {
+		case "path":
+			value, present = pathParams[p.Name]
+		case "query":
+			present = r.URL.Query().Has(p.Name)
+			value = r.URL.Query().Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		default:
+			continue
+		}
+
+		if !present {
+			if p.Required {
+				errs = append(errs, FieldError{In: p.In, Name: p.Name, Reason: "is required"})
+			}
+			continue
+		}
+
+		if reason := s.validateParam(s.resolve(p.Schema), value); reason != "" {
+			errs = append(errs, FieldError{In: p.In, Name: p.Name, Reason: reason})
+		}
+	}
+
+	if op.RequestBody != nil {
+		errs = append(errs, s.validateBody(r, op.RequestBody)...)
+	}
+
+	return errs
+}
+
+func (s *Spec) validateBody(r *http.Request, body *RequestBody) []FieldError {
+	media, ok := body.Content["application/json"]
+	if !ok {
+		return nil
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/json" {
+			return []FieldError{{In: "header", Name: "Content-Type", Reason: "must be application/json"}}
+		}
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	if err != nil {
+		return []FieldError{{In: "body", Reason: "can't read body"}}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	if len(raw) > MaxBodySize {
+		return []FieldError{{In: "body", Reason: fmt.Sprintf("must not exceed %d bytes", MaxBodySize)}}
+	}
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		if body.Required {
+			return []FieldError{{In: "body", Reason: "is required"}}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []FieldError{{In: "body", Reason: "must be valid JSON"}}
+	}
+
+	errs := make([]FieldError, 0)
+	s.validateJSON(s.resolve(media.Schema), v, "", &errs)
+
+	return errs
+}
+
+func (s *Spec) validateParam(schema *Schema, value string) string {
+	if schema == nil {
+		return ""
+	}
+
+	switch schema.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "must be an integer"
+		}
+		return checkRange(schema, float64(n))
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "must be a number"
+		}
+		return checkRange(schema, n)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be a boolean"
+		}
+		return ""
+	default:
+		return checkString(schema, value)
+	}
+}
+
+func (s *Spec) validateJSON(schema *Schema, v any, path string, errs *[]FieldError) {
+	if schema == nil {
+		return
+	}
+
+	fail := func(reason string) {
+		*errs = append(*errs, FieldError{In: "body", Name: path, Reason: reason})
+	}
+
+	if v == nil {
+		if !schema.Nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if reason := checkString(schema, str); reason != "" {
+			fail(reason)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			fail("must be an integer")
+			return
+		}
+		i, err := n.Int64()
+		if err != nil {
+			fail("must be an integer")
+			return
+		}
+		if reason := checkRange(schema, float64(i)); reason != "" {
+			fail(reason)
+		}
+	case "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		f, err := n.Float64()
+		if err != nil {
+			fail("must be a number")
+			return
+		}
+		if reason := checkRange(schema, f); reason != "" {
+			fail(reason)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("must be a boolean")
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			s.validateJSON(s.resolve(schema.Items), item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		s.validateObject(schema, obj, path, errs)
+	}
+}
+
+func (s *Spec) validateObject(schema *Schema, obj map[string]any, path string, errs *[]FieldError) {
+	join := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, FieldError{In: "body", Name: join(name), Reason: "is required"})
+		}
+	}
+
+	// Sorted keys keep the order of errors stable
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				*errs = append(*errs, FieldError{In: "body", Name: join(name), Reason: "is not allowed"})
+			}
+			continue
+		}
+
+		s.validateJSON(s.resolve(prop), obj[name], join(name), errs)
+	}
+}
+
+func checkRange(schema *Schema, n float64) string {
+	if schema.Minimum != nil && n < *schema.Minimum {
+		return "must be >= " + strconv.FormatFloat(*schema.Minimum, 'f', -1, 64)
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		return "must be <= " + strconv.FormatFloat(*schema.Maximum, 'f', -1, 64)
+	}
+
+	return checkEnum(schema, n)
+}
+
+func checkString(schema *Schema, str string) string {
+	length := len([]rune(str))
+	if schema.MinLength != nil && length < *schema.MinLength {
+		return fmt.Sprintf("must be at least %d characters long", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		return fmt.Sprintf("must be at most %d characters long", *schema.MaxLength)
+	}
+
+	switch schema.Format {
+	case "date":
+		if _, err := time.Parse(time.DateOnly, str); err != nil {
+			return "must be a date in YYYY-MM-DD format"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return "must be a date-time in RFC 3339 format"
+		}
+	case "email":
+		if !email.Valid(str) {
+			return "must be a valid email"
+		}
+	}
+
+	return checkEnum(schema, str)
+}
+
+func checkEnum(schema *Schema, v any) string {
+	if len(schema.Enum) == 0 {
+		return ""
+	}
+
+	values := make([]string, 0, len(schema.Enum))
+	for _, e := range schema.Enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return ""
+		}
+		values = append(values, fmt.Sprint(e))
+	}
+
+	return "must be one of: " + strings.Join(values, ", ")
+}
@@ -0,0 +1,202 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/v1/users": {
+      "get": {
+        "parameters": [
+          { "name": "limit", "in": "query", "schema": { "type": "integer", "minimum": 1, "maximum": 200 } },
+          { "name": "activated", "in": "query", "schema": { "type": "boolean" } },
+          { "name": "sort", "in": "query", "schema": { "type": "string", "enum": ["name", "birth_date"] } }
+        ]
+      }
+    },
+    "/v1/users/{id}": {
+      "patch": {
+        "parameters": [{ "$ref": "#/components/parameters/UserID" }],
+        "requestBody": {
+          "required": true,
+          "content": { "application/json": { "schema": { "$ref": "#/components/schemas/UserUpdate" } } }
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "UserID": { "name": "id", "in": "path", "required": true, "schema": { "type": "integer", "minimum": 1 } }
+    },
+    "schemas": {
+      "UserUpdate": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["first_name"],
+        "properties": {
+          "first_name": { "type": "string", "minLength": 1, "maxLength": 10 },
+          "birth_date": { "type": "string", "format": "date", "nullable": true },
+          "tags": { "type": "array", "items": { "type": "string", "maxLength": 3 } }
+        }
+      }
+    }
+  }
+}`
+
+func loadTestSpec(t *testing.T) *Spec {
+	t.Helper()
+
+	s, err := Load([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	return s
+}
+
+// validate finds the operation of the request and validates it
+func validate(t *testing.T, s *Spec, r *http.Request) []FieldError {
+	t.Helper()
+
+	op, params, ok := s.Find(r.Method, r.URL.Path)
+	if !ok {
+		t.Fatalf("no operation for %s %s", r.Method, r.URL.Path)
+	}
+
+	return s.Validate(r, op, params)
+}
+
+func TestLoadUnsupportedVersion(t *testing.T) {
+	if _, err := Load([]byte(`{"openapi": "2.0"}`)); err == nil {
+		t.Fatal("Load succeeded, want unsupported version")
+	}
+}
+
+func TestFind(t *testing.T) {
+	s := loadTestSpec(t)
+
+	_, params, ok := s.Find(http.MethodPatch, "/v1/users/42")
+	if !ok || params["id"] != "42" {
+		t.Fatalf("Find = %v, %v, want the id parameter", params, ok)
+	}
+
+	if _, _, ok := s.Find(http.MethodDelete, "/v1/users/42"); ok {
+		t.Error("Find matched a method missing in the document")
+	}
+	if _, _, ok := s.Find(http.MethodGet, "/v1/users/42/extra"); ok {
+		t.Error("Find matched a longer path")
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	s := loadTestSpec(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []FieldError
+	}{
+		{"absent", "", nil},
+		{"valid", "limit=200&activated=true&sort=name", nil},
+		{"not integer", "limit=ten", []FieldError{{In: "query", Name: "limit", Reason: "must be an integer"}}},
+		{"below minimum", "limit=0", []FieldError{{In: "query", Name: "limit", Reason: "must be >= 1"}}},
+		{"above maximum", "limit=201", []FieldError{{In: "query", Name: "limit", Reason: "must be <= 200"}}},
+		{"empty value", "limit=", []FieldError{{In: "query", Name: "limit", Reason: "must be an integer"}}},
+		{"not boolean", "activated=yes", []FieldError{{In: "query", Name: "activated", Reason: "must be a boolean"}}},
+		{"not in enum", "sort=age", []FieldError{{In: "query", Name: "sort", Reason: "must be one of: name, birth_date"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/users?"+tt.query, nil)
+
+			if got := validate(t, s, r); !slices.Equal(got, tt.want) {
+				t.Errorf("Validate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	s := loadTestSpec(t)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want []FieldError
+	}{
+		{"valid", "/v1/users/1", `{"first_name": "Ivan", "birth_date": null, "tags": ["a"]}`, nil},
+		{"path parameter", "/v1/users/0", `{"first_name": "Ivan"}`, []FieldError{{In: "path", Name: "id", Reason: "must be >= 1"}}},
+		{"missing body", "/v1/users/1", ``, []FieldError{{In: "body", Reason: "is required"}}},
+		{"invalid JSON", "/v1/users/1", `{"first_name":`, []FieldError{{In: "body", Reason: "must be valid JSON"}}},
+		{"required property", "/v1/users/1", `{}`, []FieldError{{In: "body", Name: "first_name", Reason: "is required"}}},
+		{"wrong type", "/v1/users/1", `{"first_name": 1}`, []FieldError{{In: "body", Name: "first_name", Reason: "must be a string"}}},
+		{"too long", "/v1/users/1", `{"first_name": "Константинополь"}`,
+			[]FieldError{{In: "body", Name: "first_name", Reason: "must be at most 10 characters long"}}},
+		{"bad date", "/v1/users/1", `{"first_name": "Ivan", "birth_date": "01.02.2000"}`,
+			[]FieldError{{In: "body", Name: "birth_date", Reason: "must be a date in YYYY-MM-DD format"}}},
+		{"not nullable", "/v1/users/1", `{"first_name": null}`, []FieldError{{In: "body", Name: "first_name", Reason: "must not be null"}}},
+		{"array item", "/v1/users/1", `{"first_name": "Ivan", "tags": ["a", "long"]}`,
+			[]FieldError{{In: "body", Name: "tags[1]", Reason: "must be at most 3 characters long"}}},
+		{"unknown property", "/v1/users/1", `{"first_name": "Ivan", "email": "x"}`,
+			[]FieldError{{In: "body", Name: "email", Reason: "is not allowed"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+
+			if got := validate(t, s, r); !slices.Equal(got, tt.want) {
+				t.Errorf("Validate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBodyContentType(t *testing.T) {
+	s := loadTestSpec(t)
+
+	r := httptest.NewRequest(http.MethodPatch, "/v1/users/1", strings.NewReader(`first_name=Ivan`))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	want := []FieldError{{In: "header", Name: "Content-Type", Reason: "must be application/json"}}
+	if got := validate(t, s, r); !slices.Equal(got, want) {
+		t.Errorf("Validate = %v, want %v", got, want)
+	}
+}
+
+func TestValidateBodyTooLarge(t *testing.T) {
+	s := loadTestSpec(t)
+
+	r := httptest.NewRequest(http.MethodPatch, "/v1/users/1", strings.NewReader(strings.Repeat(" ", MaxBodySize+1)))
+
+	got := validate(t, s, r)
+	if len(got) != 1 || !strings.HasPrefix(got[0].Reason, "must not exceed") {
+		t.Errorf("Validate = %v, want the size limit error", got)
+	}
+}
+
+func TestValidateKeepsBody(t *testing.T) {
+	s := loadTestSpec(t)
+
+	body := `{"first_name": "Ivan"}`
+	r := httptest.NewRequest(http.MethodPatch, "/v1/users/1", strings.NewReader(body))
+
+	if errs := validate(t, s, r); len(errs) != 0 {
+		t.Fatalf("Validate = %v, want no errors", errs)
+	}
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil || string(raw) != body {
+		t.Errorf("body after Validate = %q, %v, want %q", raw, err, body)
+	}
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneEvery is the number of Allow calls between removals of idle buckets
+const pruneEvery = 1024
+
+// Limiter is a set of token buckets, one per key
+type Limiter struct {
+	mx      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+	calls   int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns Limiter which refills rate tokens per second up to burst tokens per key
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the key bucket and reports whether it was available
+func (l *Limiter) Allow(key string) bool {
+	return l.take(key) == 0
+}
+
+// Wait takes a token from the key bucket, waiting until it is available or ctx is done
+func (l *Limiter) Wait(ctx context.Context, key string) error {
+	for {
+		delay := l.take(key)
+		if delay == 0 {
+			return nil
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// take takes a token from the key bucket if it is available, otherwise returns the time until it is
+func (l *Limiter) take(key string) time.Duration {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := time.Now()
+
+	l.calls++
+	if l.calls%pruneEvery == 0 {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		// Not less than a nanosecond, zero means the token is taken
+		return time.Duration((1-b.tokens)/l.rate*float64(time.Second)) + 1
+	}
+
+	b.tokens--
+
+	return 0
+}
+
+// prune removes buckets which are full again, they are equal to new ones
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
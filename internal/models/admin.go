@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Роли администраторов
+const (
+	RoleGlobal       = "global"
+	RoleOrganization = "organization"
+)
+
+// Admin роль администратора. Для роли RoleOrganization задан OrganizationID
+type Admin struct {
+	ID             int64  `db:"id" json:"id"`
+	UserID         int64  `db:"user_id" json:"user_id"`
+	Role           string `db:"role" json:"role"`
+	OrganizationID *int64 `db:"organization_id" json:"organization_id,omitempty"`
+}
+
+// AuditRecord запись журнала действий администратора
+type AuditRecord struct {
+	ID        int64     `db:"id" json:"id"`
+	AdminID   int64     `db:"admin_id" json:"admin_id"`
+	Action    string    `db:"action" json:"action"`
+	Target    string    `db:"target" json:"target"`
+	Details   string    `db:"details" json:"details"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// CelebrationInfo поздравление вместе с именинником
+type CelebrationInfo struct {
+	Celebration
+	User User `json:"user"`
+}
+
+// Dashboard данные панели администратора в пределах его ролей
+type Dashboard struct {
+	Global        bool
+	Users         []UserInfo
+	NextAfterID   int64 // 0, если это последняя страница пользователей
+	Organizations []Organization
+	Birthdays     []Birthday
+	Celebrations  []CelebrationInfo
+	EmailFailures []Email // Только для глобальных администраторов
+}
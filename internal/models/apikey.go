@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Права ключей API. ScopeAdmin дает все права, с ним ключ проходит любую проверку прав
+const (
+	ScopeReadUsers          = "read:users"
+	ScopeWriteUsers         = "write:users"
+	ScopeReadSubscriptions  = "read:subscriptions"
+	ScopeWriteSubscriptions = "write:subscriptions"
+	ScopeAdmin              = "admin"
+)
+
+// Scopes все известные права ключей API
+var Scopes = []string{ScopeReadUsers, ScopeWriteUsers, ScopeReadSubscriptions, ScopeWriteSubscriptions, ScopeAdmin}
+
+// APIKey ключ доступа к API. Сам ключ не хранится, только его хеш
+type APIKey struct {
+	ID         int64      `db:"id" json:"id"`
+	Name       string     `db:"name" json:"name"`
+	Hash       string     `db:"key_hash" json:"-"`
+	Scopes     []string   `db:"scopes" json:"scopes"`
+	CreatedBy  int64      `db:"created_by" json:"created_by"`
+	SubjectID  *int64     `db:"subject_id" json:"subject_id,omitempty"` // от чьего имени действует личный ключ, у ключей сервисов nil
+	ExpiresAt  *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// HasScope сообщает, дает ли ключ право scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScopesString права ключа через пробел, как они хранятся в базе
+func (k APIKey) ScopesString() string {
+	return strings.Join(k.Scopes, " ")
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Статусы поздравлений
+const (
+	CelebrationActive    = "active"
+	CelebrationCancelled = "cancelled"
+)
+
+// Celebration поздравление пользователя UserID с ближайшим днем рождения
+type Celebration struct {
+	ID          int64     `db:"id" json:"id"`
+	UserID      int64     `db:"user_id" json:"user_id"`
+	Birthday    time.Time `db:"birthday" json:"birthday"`
+	Link        string    `db:"link" json:"link,omitempty"`
+	Status      string    `db:"status" json:"status"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	Subscribers int       `db:"-" json:"subscribers"`
+}
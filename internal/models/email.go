@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Статусы отправки писем
+const (
+	EmailSent   = "sent"
+	EmailFailed = "failed"
+)
+
+// Email запись журнала отправки письма
+type Email struct {
+	ID        int64     `db:"id" json:"id"`
+	Recipient string    `db:"recipient" json:"recipient"`
+	Subject   string    `db:"subject" json:"subject"`
+	Status    string    `db:"status" json:"status"`
+	Error     string    `db:"error" json:"error,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
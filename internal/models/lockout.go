@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Lockout временная блокировка за превышение лимита запросов
+type Lockout struct {
+	ID          int64     `db:"id" json:"id"`
+	Kind        string    `db:"kind" json:"kind"`
+	Subject     string    `db:"subject" json:"subject"`
+	Reason      string    `db:"reason" json:"reason,omitempty"`
+	LockedUntil time.Time `db:"locked_until" json:"locked_until"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
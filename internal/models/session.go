@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Session состояние диалога пользователя с ботом в мессенджере
+type Session struct {
+	MessengerType string `db:"messenger_type" json:"messenger_type"`
+	MessengerID   int64  `db:"messenger_id" json:"messenger_id"`
+	// State состояние ДКА, Data - состояние диалога целиком в JSON
+	State     int       `db:"state" json:"state"`
+	Data      string    `db:"data" json:"data"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
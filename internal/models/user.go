@@ -29,10 +29,76 @@ type UserOrganization struct {
 
 // UserMessenger представляет информацию о мессенджере пользователя
 type UserMessenger struct {
-	UserID        int64  `db:"user_id" json:"user_id"`
-	MessengerType string `db:"messenger_type" json:"messenger_type"`
-	MessengerID   int64  `db:"messenger_id" json:"messenger_id"`
-	ChatID        int64  `db:"chat_id" json:"chat_id"`
-	IsActivated   bool   `db:"is_activated" json:"is_activated"`
-	Token         string `db:"token" json:"token"`
+	UserID         int64      `db:"user_id" json:"user_id"`
+	MessengerType  string     `db:"messenger_type" json:"messenger_type"`
+	MessengerID    int64      `db:"messenger_id" json:"messenger_id"`
+	ChatID         int64      `db:"chat_id" json:"chat_id"`
+	IsActivated    bool       `db:"is_activated" json:"is_activated"`
+	Token          string     `db:"token" json:"token"`
+	TokenExpiresAt *time.Time `db:"token_expires_at" json:"token_expires_at,omitempty"`
+	// Language язык сообщений, выбранный пользователем, пустой - по настройкам мессенджера
+	Language string `db:"language" json:"language,omitempty"`
+	// ClientLanguage язык клиента мессенджера на момент регистрации
+	ClientLanguage string `db:"client_language" json:"client_language,omitempty"`
+	// UnreachableAt время, когда пользователь заблокировал бота, nil - сообщения доставляются
+	UnreachableAt *time.Time `db:"unreachable_at" json:"unreachable_at,omitempty"`
+}
+
+// Languages возвращает языки сообщений в порядке предпочтения
+func (m UserMessenger) Languages() []string {
+	return []string{m.Language, m.ClientLanguage}
+}
+
+// Reachable сообщает, доставляются ли пользователю сообщения в мессенджер
+func (m UserMessenger) Reachable() bool {
+	return m.UnreachableAt == nil
+}
+
+// Subscription подписка пользователя SubID на день рождения пользователя UserID
+type Subscription struct {
+	UserID int64      `db:"user_id" json:"user_id"`
+	SubID  int64      `db:"sub_id" json:"sub_id"`
+	Link   string     `db:"link" json:"link,omitempty"`
+	Expire *time.Time `db:"expire" json:"expire,omitempty"`
+}
+
+// UserData все данные, которые хранятся о пользователе (выгрузка по запросу пользователя)
+type UserData struct {
+	User          User            `json:"user"`
+	Organizations []Organization  `json:"organizations"`
+	Messengers    []UserMessenger `json:"messengers"`
+	Subscriptions []Subscription  `json:"subscriptions"`
+	Subscribers   []Subscription  `json:"subscribers"`
+	Celebrations  []Celebration   `json:"celebrations"`
+	// Состояния диалогов, журнал писем, блокировки и ключи API, выпущенные пользователем
+	Sessions   []Session `json:"sessions"`
+	Emails     []Email   `json:"emails"`
+	Lockouts   []Lockout `json:"lockouts"`
+	APIKeys    []APIKey  `json:"api_keys"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// UserInfo пользователь вместе с его организациями и мессенджерами
+type UserInfo struct {
+	User          User            `json:"user"`
+	Organizations []Organization  `json:"organizations"`
+	Messengers    []UserMessenger `json:"messengers"`
+}
+
+// UserFilter условия выборки пользователей. Пустые поля не учитываются
+type UserFilter struct {
+	OrganizationID int64
+	Organization   string
+	City           string
+	Office         string
+	Department     string
+	Activated      *bool
+}
+
+// Birthday ближайший день рождения пользователя
+type Birthday struct {
+	User       User      `json:"user"`
+	Date       time.Time `json:"date"`
+	DaysLeft   int       `json:"days_left"`
+	Subscribed bool      `json:"subscribed"`
 }
@@ -0,0 +1,418 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/email"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+)
+
+var (
+	ErrNotAdmin            = errors.New("user is not admin")
+	ErrForbidden           = errors.New("action is not allowed for the admin")
+	ErrNoPendingActivation = errors.New("user has no accounts waiting for activation")
+)
+
+// Audit trail actions
+const (
+	ActionFindUser          = "find_user"
+	ActionActivateUser      = "activate_user"
+	ActionDeactivateUser    = "deactivate_user"
+	ActionListCelebrations  = "list_celebrations"
+	ActionCancelCelebration = "cancel_celebration"
+	ActionRunScheduler      = "run_scheduler"
+	ActionResendLink        = "resend_link"
+	ActionViewDashboard     = "view_dashboard"
+	ActionLoginRequest      = "login_request"
+	ActionLogin             = "login"
+	ActionListKeys          = "list_api_keys"
+	ActionIssueKey          = "issue_api_key"
+	ActionRevokeKey         = "revoke_api_key"
+	ActionBootstrap         = "bootstrap_admin"
+)
+
+type Admin struct {
+	log                *slog.Logger
+	adminProvider      AdminProvider
+	userManager        UserManager
+	celebrationManager CelebrationManager
+	emailLog           EmailLog
+	activationSender   ActivationSender
+	keyManager         KeyManager
+	scheduler          SchedulerRunner
+	notifier           Notifier
+	catalog            *i18n.Catalog
+	web                *webAuth
+}
+
+type AdminProvider interface {
+	AdminRoles(uID int64) ([]models.Admin, error)
+	SaveAuditRecord(models.AuditRecord) error
+}
+
+type UserManager interface {
+	UserIDByMessengerID(messengerType string, id int64) (int64, error)
+	User(id int64) (models.User, error)
+	UserByEmail(email string) (models.User, error)
+	OrganizationsByUserID(uID int64) ([]models.Organization, error)
+	UserMessengers(uID int64) ([]models.UserMessenger, error)
+	SetUserActivation(uID int64, activated bool) error
+	Users(filter models.UserFilter, afterID int64, limit int) ([]models.User, error)
+	UsersByBirthdayDays(from, to int) ([]models.User, error)
+	Organizations() ([]models.Organization, error)
+}
+
+type CelebrationManager interface {
+	Celebration(id int64) (models.Celebration, error)
+	Celebrations(status string) ([]models.Celebration, error)
+	CancelCelebration(id int64) error
+	Subscribers(uID int64) ([]models.Subscription, error)
+}
+
+type EmailLog interface {
+	Emails(status string, limit int) ([]models.Email, error)
+}
+
+type ActivationSender interface {
+	ResendActivation(messengerType string, messengerID, chatID int64) error
+}
+
+type KeyManager interface {
+	Issue(name string, scopes []string, ttl time.Duration, createdBy int64) (string, models.APIKey, error)
+	Keys() ([]models.APIKey, error)
+	Revoke(id int64) error
+}
+
+type SchedulerRunner interface {
+	RunOnce() (int, error)
+}
+
+// Notifier sends messages to the chats of its messenger type
+type Notifier interface {
+	Type() string
+	Notify(chatID int64, text string) error
+}
+
+// New returns a new instance of the Admin service. Every admin action is written to the audit trail.
+// Web dashboard sessions expire after sessionTTL, login codes sent to the messenger after loginCodeTTL
+func New(
+	log *slog.Logger,
+	adminProvider AdminProvider,
+	userManager UserManager,
+	celebrationManager CelebrationManager,
+	emailLog EmailLog,
+	activationSender ActivationSender,
+	keyManager KeyManager,
+	scheduler SchedulerRunner,
+	notifier Notifier,
+	catalog *i18n.Catalog,
+	sessionTTL time.Duration,
+	loginCodeTTL time.Duration,
+) *Admin {
+	return &Admin{
+		log:                log,
+		adminProvider:      adminProvider,
+		userManager:        userManager,
+		celebrationManager: celebrationManager,
+		emailLog:           emailLog,
+		activationSender:   activationSender,
+		keyManager:         keyManager,
+		scheduler:          scheduler,
+		notifier:           notifier,
+		catalog:            catalog,
+		web:                newWebAuth(sessionTTL, loginCodeTTL),
+	}
+}
+
+// AdminByMessengerID return admin UserID by messenger account or ErrNotAdmin
+func (a *Admin) AdminByMessengerID(messengerType string, messengerID int64) (int64, error) {
+	uID, err := a.userManager.UserIDByMessengerID(messengerType, messengerID)
+	if err != nil {
+		return 0, ErrNotAdmin
+	}
+
+	roles, err := a.adminProvider.AdminRoles(uID)
+	if err != nil {
+		return 0, err
+	}
+	if len(roles) == 0 {
+		return 0, ErrNotAdmin
+	}
+
+	return uID, nil
+}
+
+// FindUser finds user by email or ID
+func (a *Admin) FindUser(adminID int64, query string) (models.UserInfo, error) {
+	const fn = "admin.FindUser"
+
+	var user models.User
+	var err error
+	if email.Valid(query) {
+		user, err = a.userManager.UserByEmail(query)
+	} else {
+		var id int64
+		id, err = strconv.ParseInt(query, 10, 64)
+		if err == nil {
+			user, err = a.userManager.User(id)
+		}
+	}
+	if err != nil {
+		return models.UserInfo{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	info, err := a.userInfo(user)
+	if err != nil {
+		return models.UserInfo{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	if err := a.authorize(adminID, ActionFindUser, query, info.Organizations); err != nil {
+		return models.UserInfo{}, err
+	}
+
+	a.audit(adminID, ActionFindUser, query, "done")
+
+	return info, nil
+}
+
+// SetActivation manually activates or deactivates user account
+func (a *Admin) SetActivation(adminID, uID int64, activated bool) error {
+	const fn = "admin.SetActivation"
+
+	action := ActionDeactivateUser
+	if activated {
+		action = ActionActivateUser
+	}
+
+	orgs, err := a.userManager.OrganizationsByUserID(uID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	target := strconv.FormatInt(uID, 10)
+	if err := a.authorize(adminID, action, target, orgs); err != nil {
+		return err
+	}
+
+	if err := a.userManager.SetUserActivation(uID, activated); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.audit(adminID, action, target, "done")
+
+	return nil
+}
+
+// Celebrations return active celebrations visible to the admin
+func (a *Admin) Celebrations(adminID int64) ([]models.Celebration, error) {
+	const fn = "admin.Celebrations"
+
+	roles, err := a.roles(adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	celebrations, err := a.visibleCelebrations(roles)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.audit(adminID, ActionListCelebrations, "", fmt.Sprintf("%d celebrations", len(celebrations)))
+
+	return celebrations, nil
+}
+
+// CancelCelebration cancels the celebration and notifies its subscribers
+func (a *Admin) CancelCelebration(adminID, celebrationID int64) error {
+	const fn = "admin.CancelCelebration"
+
+	log := a.log.With(slog.String("fn", fn))
+
+	c, err := a.celebrationManager.Celebration(celebrationID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	orgs, err := a.userManager.OrganizationsByUserID(c.UserID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	target := strconv.FormatInt(celebrationID, 10)
+	if err := a.authorize(adminID, ActionCancelCelebration, target, orgs); err != nil {
+		return err
+	}
+
+	if err := a.celebrationManager.CancelCelebration(celebrationID); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.audit(adminID, ActionCancelCelebration, target, "done")
+
+	user, err := a.userManager.User(c.UserID)
+	if err != nil {
+		log.Warn("failed to get celebration user", sl.Err(err))
+		return nil
+	}
+
+	subs, err := a.celebrationManager.Subscribers(c.UserID)
+	if err != nil {
+		log.Warn("failed to get celebration subscribers", sl.Err(err))
+		return nil
+	}
+
+	args := i18n.Args{"name": user.FirstName + " " + user.LastName, "date": c.Birthday.Format("02.01")}
+	for _, sub := range subs {
+		messengers, err := a.userManager.UserMessengers(sub.SubID)
+		if err != nil {
+			log.Warn("failed to get subscriber messengers", sl.Err(err))
+			continue
+		}
+
+		for _, m := range messengers {
+			if m.MessengerType != a.notifier.Type() || !m.Reachable() {
+				continue
+			}
+
+			text := a.catalog.Localizer(m.Languages()...).T("notify.celebration_cancelled", args)
+			if err := a.notifier.Notify(m.ChatID, text); err != nil {
+				log.Warn("failed to notify subscriber", sl.Err(err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// RunScheduler triggers the scheduler run. Only for global admins
+func (a *Admin) RunScheduler(adminID int64) (int, error) {
+	const fn = "admin.RunScheduler"
+
+	if err := a.authorizeGlobal(adminID, ActionRunScheduler, ""); err != nil {
+		return 0, err
+	}
+
+	created, err := a.scheduler.RunOnce()
+	if err != nil {
+		a.audit(adminID, ActionRunScheduler, "", "failed: "+err.Error())
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.audit(adminID, ActionRunScheduler, "", fmt.Sprintf("%d celebrations created", created))
+
+	return created, nil
+}
+
+// visibleCelebrations return active celebrations of users the roles give access to
+func (a *Admin) visibleCelebrations(roles []models.Admin) ([]models.Celebration, error) {
+	all, err := a.celebrationManager.Celebrations(models.CelebrationActive)
+	if err != nil {
+		return nil, err
+	}
+
+	celebrations := make([]models.Celebration, 0, len(all))
+	for _, c := range all {
+		orgs, err := a.userManager.OrganizationsByUserID(c.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if allowed(roles, orgs) {
+			celebrations = append(celebrations, c)
+		}
+	}
+
+	return celebrations, nil
+}
+
+func (a *Admin) userInfo(user models.User) (models.UserInfo, error) {
+	orgs, err := a.userManager.OrganizationsByUserID(user.ID)
+	if err != nil {
+		return models.UserInfo{}, err
+	}
+
+	messengers, err := a.userManager.UserMessengers(user.ID)
+	if err != nil {
+		return models.UserInfo{}, err
+	}
+
+	return models.UserInfo{User: user, Organizations: orgs, Messengers: messengers}, nil
+}
+
+func (a *Admin) roles(adminID int64) ([]models.Admin, error) {
+	roles, err := a.adminProvider.AdminRoles(adminID)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, ErrNotAdmin
+	}
+
+	return roles, nil
+}
+
+// authorize checks that the admin may act on the target from the organizations, denied attempts are recorded
+func (a *Admin) authorize(adminID int64, action, target string, orgs []models.Organization) error {
+	roles, err := a.roles(adminID)
+	if err != nil {
+		return err
+	}
+
+	if !allowed(roles, orgs) {
+		a.audit(adminID, action, target, "denied")
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+func (a *Admin) audit(adminID int64, action, target, details string) {
+	const fn = "admin.audit"
+
+	err := a.adminProvider.SaveAuditRecord(models.AuditRecord{
+		AdminID: adminID,
+		Action:  action,
+		Target:  target,
+		Details: details,
+	})
+	if err != nil {
+		a.log.Error("failed to save audit record", slog.String("fn", fn), slog.String("action", action), sl.Err(err))
+	}
+}
+
+func isGlobal(roles []models.Admin) bool {
+	for _, r := range roles {
+		if r.Role == models.RoleGlobal {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed reports whether roles give access to a user from the organizations
+func allowed(roles []models.Admin, orgs []models.Organization) bool {
+	if isGlobal(roles) {
+		return true
+	}
+
+	for _, r := range roles {
+		if r.Role != models.RoleOrganization || r.OrganizationID == nil {
+			continue
+		}
+
+		for _, org := range orgs {
+			if org.ID == *r.OrganizationID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/birthday"
+	"github.com/arxonic/gmh/internal/models"
+)
+
+const (
+	dashboardUsers         = 50
+	dashboardBirthdayDays  = 30
+	dashboardEmailFailures = 20
+)
+
+// Dashboard return data of the web dashboard visible to the admin.
+// Users are listed by pages starting after afterID
+func (a *Admin) Dashboard(adminID, afterID int64) (models.Dashboard, error) {
+	const fn = "admin.Dashboard"
+
+	roles, err := a.roles(adminID)
+	if err != nil {
+		return models.Dashboard{}, err
+	}
+
+	d := models.Dashboard{Global: isGlobal(roles)}
+
+	d.Users, d.NextAfterID, err = a.visibleUsers(roles, afterID, dashboardUsers)
+	if err != nil {
+		return models.Dashboard{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	d.Organizations, err = a.visibleOrganizations(roles)
+	if err != nil {
+		return models.Dashboard{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	d.Birthdays, err = a.upcomingBirthdays(roles, dashboardBirthdayDays)
+	if err != nil {
+		return models.Dashboard{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	celebrations, err := a.visibleCelebrations(roles)
+	if err != nil {
+		return models.Dashboard{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	d.Celebrations = make([]models.CelebrationInfo, 0, len(celebrations))
+	for _, c := range celebrations {
+		user, err := a.userManager.User(c.UserID)
+		if err != nil {
+			return models.Dashboard{}, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		d.Celebrations = append(d.Celebrations, models.CelebrationInfo{Celebration: c, User: user})
+	}
+
+	// Email log is not bound to organizations
+	if d.Global {
+		d.EmailFailures, err = a.emailLog.Emails(models.EmailFailed, dashboardEmailFailures)
+		if err != nil {
+			return models.Dashboard{}, fmt.Errorf("%s:%w", fn, err)
+		}
+	}
+
+	a.audit(adminID, ActionViewDashboard, "", "users after "+strconv.FormatInt(afterID, 10))
+
+	return d, nil
+}
+
+// ResendActivation sends new activation links for all not activated messenger accounts of the user.
+// Returns the number of sent links
+func (a *Admin) ResendActivation(adminID, uID int64) (int, error) {
+	const fn = "admin.ResendActivation"
+
+	orgs, err := a.userManager.OrganizationsByUserID(uID)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	target := strconv.FormatInt(uID, 10)
+	if err := a.authorize(adminID, ActionResendLink, target, orgs); err != nil {
+		return 0, err
+	}
+
+	messengers, err := a.userManager.UserMessengers(uID)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	sent := 0
+	for _, m := range messengers {
+		if m.IsActivated {
+			continue
+		}
+
+		if err := a.activationSender.ResendActivation(m.MessengerType, m.MessengerID, m.ChatID); err != nil {
+			a.audit(adminID, ActionResendLink, target, "failed: "+err.Error())
+			return sent, fmt.Errorf("%s:%w", fn, err)
+		}
+		sent++
+	}
+
+	if sent == 0 {
+		return 0, ErrNoPendingActivation
+	}
+
+	a.audit(adminID, ActionResendLink, target, fmt.Sprintf("%d links sent", sent))
+
+	return sent, nil
+}
+
+// visibleUsers return up to limit users after afterID the roles give access to
+// and the ID to continue from, 0 if there are no more users
+func (a *Admin) visibleUsers(roles []models.Admin, afterID int64, limit int) ([]models.UserInfo, int64, error) {
+	users := make([]models.UserInfo, 0, limit)
+	cursor := afterID
+
+	// Organization admins see only a part of users, so pages are fetched until the limit is filled.
+	// One extra user tells whether there is a next page
+	for len(users) <= limit {
+		page, err := a.userManager.Users(models.UserFilter{}, cursor, limit)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, user := range page {
+			cursor = user.ID
+
+			info, err := a.userInfo(user)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			if allowed(roles, info.Organizations) {
+				users = append(users, info)
+			}
+			if len(users) > limit {
+				break
+			}
+		}
+
+		if len(page) < limit {
+			break
+		}
+	}
+
+	if len(users) <= limit {
+		return users, 0, nil
+	}
+
+	users = users[:limit]
+
+	return users, users[limit-1].User.ID, nil
+}
+
+func (a *Admin) visibleOrganizations(roles []models.Admin) ([]models.Organization, error) {
+	all, err := a.userManager.Organizations()
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make([]models.Organization, 0, len(all))
+	for _, org := range all {
+		if allowed(roles, []models.Organization{org}) {
+			orgs = append(orgs, org)
+		}
+	}
+
+	return orgs, nil
+}
+
+func (a *Admin) upcomingBirthdays(roles []models.Admin, days int) ([]models.Birthday, error) {
+	users, err := a.userManager.UsersByBirthdayDays(0, days)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	birthdays := make([]models.Birthday, 0)
+	for _, user := range users {
+		orgs, err := a.userManager.OrganizationsByUserID(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed(roles, orgs) {
+			continue
+		}
+
+		birthdays = append(birthdays, models.Birthday{
+			User:     user,
+			Date:     birthday.Next(user.BirthDate, now),
+			DaysLeft: birthday.DaysLeft(user.BirthDate, now),
+		})
+	}
+
+	sort.Slice(birthdays, func(i, j int) bool {
+		return birthdays[i].DaysLeft < birthdays[j].DaysLeft
+	})
+
+	return birthdays, nil
+}
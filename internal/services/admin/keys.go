@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+)
+
+// APIKeys return active API keys. Only for global admins
+func (a *Admin) APIKeys(adminID int64) ([]models.APIKey, error) {
+	const fn = "admin.APIKeys"
+
+	if err := a.authorizeGlobal(adminID, ActionListKeys, ""); err != nil {
+		return nil, err
+	}
+
+	keys, err := a.keyManager.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.audit(adminID, ActionListKeys, "", fmt.Sprintf("%d keys", len(keys)))
+
+	return keys, nil
+}
+
+// IssueAPIKey creates an API key for a machine client and return its value, which is not stored.
+// Only for global admins
+func (a *Admin) IssueAPIKey(adminID int64, name string, scopes []string, ttl time.Duration) (string, models.APIKey, error) {
+	const fn = "admin.IssueAPIKey"
+
+	if err := a.authorizeGlobal(adminID, ActionIssueKey, name); err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	key, apiKey, err := a.keyManager.Issue(name, scopes, ttl, adminID)
+	if err != nil {
+		a.audit(adminID, ActionIssueKey, name, "failed: "+err.Error())
+		return "", models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.audit(adminID, ActionIssueKey, name, fmt.Sprintf("id %d, scopes %s", apiKey.ID, strings.Join(scopes, " ")))
+
+	return key, apiKey, nil
+}
+
+// RevokeAPIKey revokes the API key. Only for global admins
+func (a *Admin) RevokeAPIKey(adminID, keyID int64) error {
+	const fn = "admin.RevokeAPIKey"
+
+	target := strconv.FormatInt(keyID, 10)
+	if err := a.authorizeGlobal(adminID, ActionRevokeKey, target); err != nil {
+		return err
+	}
+
+	if err := a.keyManager.Revoke(keyID); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.audit(adminID, ActionRevokeKey, target, "done")
+
+	return nil
+}
+
+// authorizeGlobal checks that the admin is global, denied attempts are recorded
+func (a *Admin) authorizeGlobal(adminID int64, action, target string) error {
+	roles, err := a.roles(adminID)
+	if err != nil {
+		return err
+	}
+
+	if !isGlobal(roles) {
+		a.audit(adminID, action, target, "denied")
+		return ErrForbidden
+	}
+
+	return nil
+}
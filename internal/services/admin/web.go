@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/lib/token"
+)
+
+var (
+	ErrInvalidCode     = errors.New("invalid or expired login code")
+	ErrSessionNotFound = errors.New("session not found or expired")
+)
+
+const (
+	loginCodeDigits = 6
+	// maxCodeAttempts is the number of wrong codes after which the code is revoked
+	maxCodeAttempts = 5
+)
+
+// Session is the web dashboard session of the admin
+type Session struct {
+	Token     string
+	AdminID   int64
+	CSRFToken string
+	ExpiresAt time.Time
+}
+
+type loginCode struct {
+	code      string
+	adminID   int64
+	expiresAt time.Time
+	attempts  int
+}
+
+// webAuth keeps login codes and sessions of the web dashboard in memory
+type webAuth struct {
+	sessionTTL   time.Duration
+	loginCodeTTL time.Duration
+
+	mx       sync.Mutex
+	codes    map[string]*loginCode // by lower-cased email
+	sessions map[string]Session    // by session token
+}
+
+func newWebAuth(sessionTTL, loginCodeTTL time.Duration) *webAuth {
+	return &webAuth{
+		sessionTTL:   sessionTTL,
+		loginCodeTTL: loginCodeTTL,
+		codes:        make(map[string]*loginCode),
+		sessions:     make(map[string]Session),
+	}
+}
+
+// StartLogin sends a one-time login code to activated messenger accounts of the admin.
+// Unknown emails and non-admins are not reported to the caller, so emails of admins can't be guessed
+func (a *Admin) StartLogin(email string) error {
+	const fn = "admin.StartLogin"
+
+	log := a.log.With(slog.String("fn", fn))
+
+	user, err := a.userManager.UserByEmail(email)
+	if err != nil {
+		log.Debug("login requested for unknown user", sl.Err(err))
+		return nil
+	}
+
+	if _, err := a.roles(user.ID); err != nil {
+		log.Info("login requested by non-admin", slog.Int64("uid", user.ID))
+		return nil
+	}
+
+	messengers, err := a.userManager.UserMessengers(user.ID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	code, err := newLoginCode()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	a.web.mx.Lock()
+	a.web.prune()
+	a.web.codes[strings.ToLower(email)] = &loginCode{
+		code:      code,
+		adminID:   user.ID,
+		expiresAt: time.Now().Add(a.web.loginCodeTTL),
+	}
+	a.web.mx.Unlock()
+
+	args := i18n.Args{"code": code, i18n.CountArg: int(a.web.loginCodeTTL.Minutes())}
+
+	sent := 0
+	for _, m := range messengers {
+		if !m.IsActivated || m.MessengerType != a.notifier.Type() {
+			continue
+		}
+
+		text := a.catalog.Localizer(m.Languages()...).T("notify.login_code", args)
+		if err := a.notifier.Notify(m.ChatID, text); err != nil {
+			log.Warn("failed to send login code", sl.Err(err))
+			continue
+		}
+		sent++
+	}
+
+	a.audit(user.ID, ActionLoginRequest, "", fmt.Sprintf("code sent to %d chats", sent))
+
+	return nil
+}
+
+// CompleteLogin checks the login code and starts a new session
+func (a *Admin) CompleteLogin(email, code string) (Session, error) {
+	const fn = "admin.CompleteLogin"
+
+	key := strings.ToLower(email)
+
+	a.web.mx.Lock()
+	defer a.web.mx.Unlock()
+
+	a.web.prune()
+
+	lc, ok := a.web.codes[key]
+	if !ok {
+		return Session{}, ErrInvalidCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(lc.code), []byte(strings.TrimSpace(code))) != 1 {
+		lc.attempts++
+		if lc.attempts >= maxCodeAttempts {
+			delete(a.web.codes, key)
+			a.audit(lc.adminID, ActionLogin, "", "denied: too many wrong codes")
+		}
+		return Session{}, ErrInvalidCode
+	}
+
+	delete(a.web.codes, key)
+
+	sessionToken, err := token.NewToken()
+	if err != nil {
+		return Session{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	csrfToken, err := token.NewToken()
+	if err != nil {
+		return Session{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	session := Session{
+		Token:     sessionToken,
+		AdminID:   lc.adminID,
+		CSRFToken: csrfToken,
+		ExpiresAt: time.Now().Add(a.web.sessionTTL),
+	}
+	a.web.sessions[sessionToken] = session
+
+	a.audit(lc.adminID, ActionLogin, "", "done")
+
+	return session, nil
+}
+
+// Session return the active session by token
+func (a *Admin) Session(sessionToken string) (Session, error) {
+	a.web.mx.Lock()
+	defer a.web.mx.Unlock()
+
+	session, ok := a.web.sessions[sessionToken]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		delete(a.web.sessions, sessionToken)
+		return Session{}, ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// Logout ends the session
+func (a *Admin) Logout(sessionToken string) {
+	a.web.mx.Lock()
+	defer a.web.mx.Unlock()
+
+	delete(a.web.sessions, sessionToken)
+}
+
+// prune removes expired codes and sessions, mx must be held
+func (w *webAuth) prune() {
+	now := time.Now()
+
+	for key, lc := range w.codes {
+		if now.After(lc.expiresAt) {
+			delete(w.codes, key)
+		}
+	}
+
+	for key, s := range w.sessions {
+		if now.After(s.ExpiresAt) {
+			delete(w.sessions, key)
+		}
+	}
+}
+
+func newLoginCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < loginCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", loginCodeDigits, n), nil
+}
@@ -0,0 +1,224 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+var (
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrKeyExpired   = errors.New("api key expired")
+	ErrKeyExists    = errors.New("active api key with the name alredy exists")
+	ErrKeyNotFound  = errors.New("api key not found")
+	ErrUnknownScope = errors.New("unknown scope")
+	ErrNoScopes     = errors.New("at least one scope is required")
+)
+
+const (
+	// KeyPrefix marks API keys issued by the service, so they can be found by secret scanners
+	KeyPrefix = "gmh_"
+	keyBytes  = 32
+	// touchInterval limits writes of the last usage time for busy keys
+	touchInterval = time.Minute
+)
+
+// PersonalScopes are the scopes of personal keys of employees, the API acts on behalf of the key subject
+var PersonalScopes = []string{models.ScopeReadSubscriptions, models.ScopeWriteSubscriptions}
+
+type Keys struct {
+	log         *slog.Logger
+	storage     KeyStorage
+	personalTTL time.Duration
+}
+
+type KeyStorage interface {
+	SaveAPIKey(k models.APIKey) (int64, error)
+	APIKeyByHash(hash string) (models.APIKey, error)
+	APIKeys() ([]models.APIKey, error)
+	APIKeysByCreator(uID int64) ([]models.APIKey, error)
+	TouchAPIKey(id int64, usedAt time.Time) error
+	RevokeAPIKey(id int64) error
+}
+
+// New returns a new instance of the API keys service. Personal keys expire after personalTTL
+func New(log *slog.Logger, storage KeyStorage, personalTTL time.Duration) *Keys {
+	return &Keys{
+		log:         log,
+		storage:     storage,
+		personalTTL: personalTTL,
+	}
+}
+
+// Issue creates a new API key of a service with the scopes. The key never expires if ttl is 0.
+// The returned key value is shown only once, only its hash is stored
+func (k *Keys) Issue(name string, scopes []string, ttl time.Duration, createdBy int64) (string, models.APIKey, error) {
+	return k.issue(name, scopes, ttl, createdBy, nil)
+}
+
+// issue creates a new API key, a personal key acts on behalf of the subject
+func (k *Keys) issue(name string, scopes []string, ttl time.Duration, createdBy int64, subjectID *int64) (string, models.APIKey, error) {
+	const fn = "apikeys.Issue"
+
+	if len(scopes) == 0 {
+		return "", models.APIKey{}, ErrNoScopes
+	}
+	for _, s := range scopes {
+		if !slices.Contains(models.Scopes, s) {
+			return "", models.APIKey{}, fmt.Errorf("%w: %s", ErrUnknownScope, s)
+		}
+	}
+
+	raw := make([]byte, keyBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+	}
+	key := KeyPrefix + hex.EncodeToString(raw)
+
+	apiKey := models.APIKey{
+		Name:      name,
+		Hash:      hash(key),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		SubjectID: subjectID,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	id, err := k.storage.SaveAPIKey(apiKey)
+	if err != nil {
+		if errors.Is(err, repo.ErrAPIKeyExists) {
+			return "", models.APIKey{}, ErrKeyExists
+		}
+		return "", models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+	}
+	apiKey.ID = id
+
+	k.log.Info("api key issued", slog.Int64("id", id), slog.String("name", name), slog.String("scopes", apiKey.ScopesString()))
+
+	return key, apiKey, nil
+}
+
+// IssuePersonal creates a personal key of the user with PersonalScopes, the previous personal key is revoked.
+// The returned key value is shown only once
+func (k *Keys) IssuePersonal(uID int64) (string, models.APIKey, error) {
+	const fn = "apikeys.IssuePersonal"
+
+	name := personalKeyName(uID)
+
+	keys, err := k.storage.APIKeysByCreator(uID)
+	if err != nil {
+		return "", models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+	}
+	for _, key := range keys {
+		if key.Name != name || key.RevokedAt != nil {
+			continue
+		}
+
+		if err := k.Revoke(key.ID); err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return "", models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+		}
+	}
+
+	key, apiKey, err := k.issue(name, PersonalScopes, k.personalTTL, uID, &uID)
+	if err != nil {
+		return "", models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return key, apiKey, nil
+}
+
+// personalKeyName is the name of the personal key of the user, a user has one active personal key
+func personalKeyName(uID int64) string {
+	return "personal:" + strconv.FormatInt(uID, 10)
+}
+
+// Authenticate return the active API key by its value and records its usage
+func (k *Keys) Authenticate(key string) (models.APIKey, error) {
+	const fn = "apikeys.Authenticate"
+
+	log := k.log.With(slog.String("fn", fn))
+
+	if !strings.HasPrefix(key, KeyPrefix) {
+		authTotal.Inc(authInvalid)
+		return models.APIKey{}, ErrInvalidKey
+	}
+
+	apiKey, err := k.storage.APIKeyByHash(hash(key))
+	if err != nil {
+		if errors.Is(err, repo.ErrAPIKeyNotFound) {
+			authTotal.Inc(authInvalid)
+			return models.APIKey{}, ErrInvalidKey
+		}
+		authTotal.Inc(authError)
+		return models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	if apiKey.RevokedAt != nil {
+		authTotal.Inc(authInvalid)
+		return models.APIKey{}, ErrInvalidKey
+	}
+
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		authTotal.Inc(authExpired)
+		return models.APIKey{}, ErrKeyExpired
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= touchInterval {
+		if err := k.storage.TouchAPIKey(apiKey.ID, now); err != nil {
+			log.Warn("failed to save api key usage", slog.Int64("id", apiKey.ID), sl.Err(err))
+		}
+	}
+
+	authTotal.Inc(authSuccess)
+
+	return apiKey, nil
+}
+
+// Keys return all not revoked API keys
+func (k *Keys) Keys() ([]models.APIKey, error) {
+	const fn = "apikeys.Keys"
+
+	keys, err := k.storage.APIKeys()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return keys, nil
+}
+
+// Revoke revokes the API key, requests with it are rejected right away
+func (k *Keys) Revoke(id int64) error {
+	const fn = "apikeys.Revoke"
+
+	if err := k.storage.RevokeAPIKey(id); err != nil {
+		if errors.Is(err, repo.ErrAPIKeyNotFound) {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	k.log.Info("api key revoked", slog.Int64("id", id))
+
+	return nil
+}
+
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,100 @@
+package apikeys
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+// memStorage keeps keys in memory
+type memStorage struct {
+	keys []models.APIKey
+}
+
+func (m *memStorage) SaveAPIKey(k models.APIKey) (int64, error) {
+	for _, key := range m.keys {
+		if key.Name == k.Name && key.RevokedAt == nil {
+			return 0, repo.ErrAPIKeyExists
+		}
+	}
+
+	k.ID = int64(len(m.keys) + 1)
+	m.keys = append(m.keys, k)
+
+	return k.ID, nil
+}
+
+func (m *memStorage) APIKeyByHash(hash string) (models.APIKey, error) {
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, repo.ErrAPIKeyNotFound
+}
+
+func (m *memStorage) APIKeys() ([]models.APIKey, error) { return m.keys, nil }
+
+func (m *memStorage) APIKeysByCreator(uID int64) ([]models.APIKey, error) {
+	keys := make([]models.APIKey, 0)
+	for _, key := range m.keys {
+		if key.CreatedBy == uID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *memStorage) TouchAPIKey(int64, time.Time) error { return nil }
+
+func (m *memStorage) RevokeAPIKey(id int64) error {
+	for i := range m.keys {
+		if m.keys[i].ID == id && m.keys[i].RevokedAt == nil {
+			now := time.Now()
+			m.keys[i].RevokedAt = &now
+			return nil
+		}
+	}
+	return repo.ErrAPIKeyNotFound
+}
+
+func TestIssuePersonalRevokesPrevious(t *testing.T) {
+	k := New(slog.New(slog.NewTextHandler(io.Discard, nil)), &memStorage{}, time.Hour)
+
+	first, _, err := k.IssuePersonal(1)
+	if err != nil {
+		t.Fatalf("issue first key: %v", err)
+	}
+
+	second, apiKey, err := k.IssuePersonal(1)
+	if err != nil {
+		t.Fatalf("issue second key: %v", err)
+	}
+	if apiKey.CreatedBy != 1 || apiKey.SubjectID == nil || *apiKey.SubjectID != 1 || apiKey.ExpiresAt == nil || !apiKey.HasScope(models.ScopeWriteSubscriptions) || apiKey.HasScope(models.ScopeReadUsers) {
+		t.Errorf("personal key = %+v", apiKey)
+	}
+
+	if _, err := k.Authenticate(first); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("authenticate previous key = %v, want ErrInvalidKey", err)
+	}
+	if _, err := k.Authenticate(second); err != nil {
+		t.Errorf("authenticate new key: %v", err)
+	}
+}
+
+func TestIssueServiceKeyHasNoSubject(t *testing.T) {
+	k := New(slog.New(slog.NewTextHandler(io.Discard, nil)), &memStorage{}, time.Hour)
+
+	_, apiKey, err := k.Issue("portal", []string{models.ScopeAdmin}, 0, 1)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if apiKey.SubjectID != nil {
+		t.Errorf("subject of the service key = %d, want none", *apiKey.SubjectID)
+	}
+}
@@ -0,0 +1,14 @@
+package apikeys
+
+import "github.com/arxonic/gmh/internal/lib/metrics"
+
+// Results of API key authentication
+const (
+	authSuccess = "success"
+	authInvalid = "invalid"
+	authExpired = "expired"
+	authError   = "error"
+)
+
+var authTotal = metrics.NewCounterVec("api_key_auth_total",
+	"API key authentication attempts by result.", "result")
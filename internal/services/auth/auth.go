@@ -4,15 +4,25 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/arxonic/gmh/internal/lib/i18n"
 	"github.com/arxonic/gmh/internal/lib/logger/sl"
 	"github.com/arxonic/gmh/internal/lib/token"
 	"github.com/arxonic/gmh/internal/models"
+	"github.com/arxonic/gmh/internal/services/guard"
+	repo "github.com/arxonic/gmh/internal/storage"
 )
 
 var (
-	ErrUserExists   = errors.New("user alredy exists")
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists       = errors.New("user alredy exists")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidToken     = errors.New("invalid activation token")
+	ErrTokenExpired     = errors.New("activation token expired")
+	ErrAlreadyActivated = errors.New("account alredy activated")
+	ErrMessengerExists  = errors.New("messenger account alredy registered")
 )
 
 type Auth struct {
@@ -21,34 +31,77 @@ type Auth struct {
 	userProvider UserProvider
 	userAuther   UserAuther
 	emailSender  EmailSender
+	notifier     Notifier
+	limiter      Limiter
+	catalog      *i18n.Catalog
+	tokenTTL     time.Duration
+	// activationURL is the activation page, botURL is where the page returns user after activation
+	activationURL string
+	botURL        string
 }
 
+// EmailSender sends the email before it returns, so the registration is not saved when the activation email failed
+// and the user may try again
 type EmailSender interface {
 	SendEmail(to, subject, message string) error
 }
 
+// Notifier sends messages to the chats of its messenger type
+type Notifier interface {
+	Type() string
+	Notify(chatID int64, text string) error
+}
+
+type Limiter interface {
+	Allow(kind, subject string) error
+}
+
 type UserSaver interface {
 	SaveAllUserInfo(models.User, models.UserMessenger, models.Organization) (int64, error)
 }
 
 type UserProvider interface {
 	IsActivated(messengerType string, messengerID, chatID int64) (bool, error)
+	UserIDByMessengerID(messengerType string, id int64) (int64, error)
+	User(id int64) (models.User, error)
 	// UserByEmail(email string) (models.User, error)
-	// UserMessenger(messenger string, messengerID int64) (models.UserMessenger, error)
+	UserMessenger(messenger string, messengerID int64) (models.UserMessenger, error)
 }
 
 type UserAuther interface {
 	UpdateUserActivationStatus(messengerType string, messengerID, chatID int64, token string) error
+	UpdateActivationToken(messengerType string, messengerID, chatID int64, token string, expiresAt time.Time) error
 }
 
 // New returns a new instance of the Auth service.
-func New(log *slog.Logger, userSaver UserSaver, userProvider UserProvider, userAuther UserAuther, noty EmailSender) *Auth {
+// Activation links sent by email lead to activationURL and expire after tokenTTL, emails to each address are rate limited.
+// Emails and notifications are in the language of the messenger account
+func New(
+	log *slog.Logger,
+	userSaver UserSaver,
+	userProvider UserProvider,
+	userAuther UserAuther,
+	noty EmailSender,
+	notifier Notifier,
+	limiter Limiter,
+	catalog *i18n.Catalog,
+	tokenTTL time.Duration,
+	activationURL string,
+	botURL string,
+) *Auth {
 	return &Auth{
 		log:          log,
 		userSaver:    userSaver,
 		userProvider: userProvider,
 		userAuther:   userAuther,
 		emailSender:  noty,
+		notifier:     notifier,
+		limiter:      limiter,
+		catalog:      catalog,
+		tokenTTL:     tokenTTL,
+
+		activationURL: activationURL,
+		botURL:        botURL,
 	}
 }
 
@@ -57,6 +110,16 @@ func (a *Auth) RegisterNewUser(user models.User, userMessenger models.UserMessen
 
 	log := a.log.With(slog.String("fn", fn))
 
+	// The messenger account belongs to one user, the registered one gets a new link by ResendActivation
+	_, err := a.userProvider.UserIDByMessengerID(userMessenger.MessengerType, userMessenger.MessengerID)
+	switch {
+	case err == nil:
+		return 0, fmt.Errorf("%s:%w", fn, ErrMessengerExists)
+	case !errors.Is(err, repo.ErrUserNotFound):
+		log.Error("failed to check messenger account", sl.Err(err))
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
 	// Generate auth token
 	token, err := token.NewToken()
 	if err != nil {
@@ -65,33 +128,104 @@ func (a *Auth) RegisterNewUser(user models.User, userMessenger models.UserMessen
 	}
 
 	userMessenger.Token = token
+	expiresAt := time.Now().Add(a.tokenTTL)
+	userMessenger.TokenExpiresAt = &expiresAt
 
 	// Send Email
-	authLink := fmt.Sprintf(
-		"http://localhost:2001/v1/auth?token=%s&mtype=%s&mid=%d&chatid=%d&redirect=%s",
-		token,
-		userMessenger.MessengerType,
-		userMessenger.MessengerID,
-		userMessenger.ChatID,
-		"https://t.me/GPMHappyBBot",
-	)
-
-	err = a.emailSender.SendEmail(user.Email, "Регистрация", authLink)
-	if err != nil {
+	if err := a.sendAuthLink(user.Email, userMessenger); err != nil {
 		log.Error("failed to send email", sl.Err(err))
 		return 0, fmt.Errorf("%s:%w", fn, err)
 	}
 
 	// Save User
 	uID, err := a.userSaver.SaveAllUserInfo(user, userMessenger, userOrganization)
+	if errors.Is(err, repo.ErrMessengerExists) {
+		return 0, fmt.Errorf("%s:%w", fn, ErrMessengerExists)
+	}
 	if err != nil {
 		log.Error("failed to save user", sl.Err(err))
 		return 0, fmt.Errorf("%s:%w", fn, err)
 	}
 
+	registrationsStarted.Inc()
+
 	return uID, nil
 }
 
+// ResendActivation generates a new auth token and sends a new auth link to the user email
+func (a *Auth) ResendActivation(messengerType string, messengerID, chatID int64) error {
+	const fn = "auth.ResendActivation"
+
+	log := a.log.With(slog.String("fn", fn))
+
+	uID, err := a.userProvider.UserIDByMessengerID(messengerType, messengerID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, ErrUserNotFound)
+	}
+
+	user, err := a.userProvider.User(uID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	token, err := token.NewToken()
+	if err != nil {
+		log.Error("failed to generate auth token", sl.Err(err))
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	expiresAt := time.Now().Add(a.tokenTTL)
+	err = a.userAuther.UpdateActivationToken(messengerType, messengerID, chatID, token, expiresAt)
+	if errors.Is(err, repo.ErrAlreadyActivated) {
+		return ErrAlreadyActivated
+	}
+	if err != nil {
+		log.Error("failed to update auth token", sl.Err(err))
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	userMessenger := models.UserMessenger{
+		UserID:         uID,
+		MessengerType:  messengerType,
+		MessengerID:    messengerID,
+		ChatID:         chatID,
+		Token:          token,
+		TokenExpiresAt: &expiresAt,
+	}
+	if stored, err := a.userProvider.UserMessenger(messengerType, messengerID); err == nil {
+		userMessenger.Language = stored.Language
+		userMessenger.ClientLanguage = stored.ClientLanguage
+	}
+	if err := a.sendAuthLink(user.Email, userMessenger); err != nil {
+		log.Error("failed to send email", sl.Err(err))
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
+
+func (a *Auth) sendAuthLink(email string, userMessenger models.UserMessenger) error {
+	if err := a.limiter.Allow(guard.KindEmail, strings.ToLower(email)); err != nil {
+		return err
+	}
+
+	authLink := fmt.Sprintf(
+		"%s?token=%s&mtype=%s&mid=%d&chatid=%d&redirect=%s",
+		a.activationURL,
+		userMessenger.Token,
+		userMessenger.MessengerType,
+		userMessenger.MessengerID,
+		userMessenger.ChatID,
+		url.QueryEscape(a.botURL),
+	)
+
+	l := a.catalog.Localizer(userMessenger.Languages()...)
+	body := l.T("email.activation.body", i18n.Args{"link": authLink, i18n.CountArg: int(a.tokenTTL.Hours())})
+
+	return a.emailSender.SendEmail(email, l.T("email.activation.subject"), body)
+}
+
 // IsActivated return Activation Account Status if UserMessenger exists
 func (a *Auth) IsActivated(messengerType string, messengerID, chatID int64) (bool, error) {
 	const fn = "auth.IsActivated"
@@ -99,8 +233,11 @@ func (a *Auth) IsActivated(messengerType string, messengerID, chatID int64) (boo
 	log := a.log.With(slog.String("fn", fn))
 
 	isActivated, err := a.userProvider.IsActivated(messengerType, messengerID, chatID)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		return false, ErrUserNotFound
+	}
 	if err != nil {
-		log.Debug("rows not found", sl.Err(err))
+		log.Error("failed to get activation status", sl.Err(err))
 		return false, err
 	}
 
@@ -113,10 +250,40 @@ func (a *Auth) AccountActivation(messengerType string, messengerID, chatID int64
 
 	log := a.log.With(slog.String("fn", fn))
 
-	if err := a.userAuther.UpdateUserActivationStatus(messengerType, messengerID, chatID, token); err != nil {
+	err := a.userAuther.UpdateUserActivationStatus(messengerType, messengerID, chatID, token)
+	switch {
+	case errors.Is(err, repo.ErrUserNotFound), errors.Is(err, repo.ErrInvalidToken):
+		log.Debug("invalid activation attempt", sl.Err(err))
+		activationsTotal.Inc(activationInvalid)
+		return ErrInvalidToken
+	case errors.Is(err, repo.ErrTokenExpired):
+		activationsTotal.Inc(activationExpired)
+		return ErrTokenExpired
+	case errors.Is(err, repo.ErrAlreadyActivated):
+		activationsTotal.Inc(activationAlreadyActivated)
+		return ErrAlreadyActivated
+	case err != nil:
 		log.Error("failed to save user activation status", sl.Err(err))
+		activationsTotal.Inc(activationError)
 		return err
 	}
 
+	activationsTotal.Inc(activationSuccess)
+	registrationsCompleted.Inc()
+
+	// The account of another messenger is activated without a notification
+	if messengerType != a.notifier.Type() {
+		return nil
+	}
+
+	var langs []string
+	if m, err := a.userProvider.UserMessenger(messengerType, messengerID); err == nil {
+		langs = m.Languages()
+	}
+
+	if err := a.notifier.Notify(chatID, a.catalog.Localizer(langs...).T("auth.activated")); err != nil {
+		log.Warn("failed to notify user about activation", sl.Err(err))
+	}
+
 	return nil
 }
@@ -0,0 +1,21 @@
+package auth
+
+import "github.com/arxonic/gmh/internal/lib/metrics"
+
+// Results of activation attempts
+const (
+	activationSuccess          = "success"
+	activationInvalid          = "invalid"
+	activationExpired          = "expired"
+	activationAlreadyActivated = "already_activated"
+	activationError            = "error"
+)
+
+var (
+	registrationsStarted = metrics.NewCounterVec("registrations_started_total",
+		"Registrations with the activation email sent.")
+	registrationsCompleted = metrics.NewCounterVec("registrations_completed_total",
+		"Registrations completed by account activation.")
+	activationsTotal = metrics.NewCounterVec("activations_total",
+		"Account activation attempts by result.", "result")
+)
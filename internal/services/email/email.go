@@ -1,21 +1,193 @@
 package email
 
 import (
+	"context"
+	"errors"
 	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/lib/metrics"
+	"github.com/arxonic/gmh/internal/models"
+)
+
+var emailsTotal = metrics.NewCounterVec("emails_total", "Emails by sending result.", "result")
+
+var (
+	ErrOutboxFull   = errors.New("email outbox is full")
+	ErrOutboxClosed = errors.New("email outbox is closed")
+	ErrNotSent      = errors.New("email not sent before shutdown")
 )
 
+const (
+	outboxSize = 100
+	// sendAttempts is the number of tries to send an email before it is logged as failed
+	sendAttempts = 3
+)
+
+// retryDelay is the pause between attempts, tests shorten it
+var retryDelay = 5 * time.Second
+
+type message struct {
+	to, subject, text string
+	// result gets the final sending result, it is buffered so Run never waits for the sender
+	result chan error
+}
+
+// Sender sends emails from the outbox one by one in the background, see Run
 type Sender struct {
 	log *slog.Logger
 	EmailSender
+	emailLog EmailLog
+
+	mx     sync.RWMutex
+	closed bool
+	outbox chan message
 }
 
 type EmailSender interface {
 	SendEmail(to, subject, message string) error
 }
 
-func New(log *slog.Logger, sender EmailSender) *Sender {
+type EmailLog interface {
+	SaveEmail(models.Email) (int64, error)
+}
+
+func New(log *slog.Logger, sender EmailSender, emailLog EmailLog) *Sender {
 	return &Sender{
 		log:         log,
 		EmailSender: sender,
+		emailLog:    emailLog,
+		outbox:      make(chan message, outboxSize),
+	}
+}
+
+// SendEmail puts the email into the outbox and waits until Run sends it or gives up after sendAttempts.
+// It returns the error of the last attempt, ErrNotSent if the service stopped before sending,
+// ErrOutboxFull or ErrOutboxClosed if the email is not accepted. The result is counted and logged by Run
+func (s *Sender) SendEmail(to, subject, text string) error {
+	m := message{to: to, subject: subject, text: text, result: make(chan error, 1)}
+
+	if err := s.enqueue(m); err != nil {
+		return err
+	}
+
+	return <-m.result
+}
+
+func (s *Sender) enqueue(m message) error {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	if s.closed {
+		return ErrOutboxClosed
+	}
+
+	select {
+	case s.outbox <- m:
+		return nil
+	default:
+		return ErrOutboxFull
+	}
+}
+
+// Run sends emails from the outbox until Close is called and the outbox is empty.
+// Emails left when ctx is done are logged as failed
+func (s *Sender) Run(ctx context.Context) error {
+	const fn = "email.Run"
+
+	log := s.log.With(slog.String("fn", fn))
+
+	for {
+		select {
+		case m, ok := <-s.outbox:
+			if !ok {
+				return nil
+			}
+			m.result <- s.send(ctx, m)
+		case <-ctx.Done():
+			// Nobody sends emails put into the outbox after this, so it is closed first
+			s.Close(ctx)
+			if dropped := s.drop(); dropped > 0 {
+				log.Error("emails not sent before shutdown", slog.Int("count", dropped))
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// drop logs emails left in the outbox as failed and returns their number
+func (s *Sender) drop() int {
+	dropped := 0
+	for {
+		select {
+		case m, ok := <-s.outbox:
+			if !ok {
+				return dropped
+			}
+			s.save(m, models.EmailFailed, ErrNotSent.Error())
+			m.result <- ErrNotSent
+			dropped++
+		default:
+			return dropped
+		}
+	}
+}
+
+// Close stops accepting emails, Run returns after the rest of the outbox is sent
+func (s *Sender) Close(context.Context) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.outbox)
+	}
+
+	return nil
+}
+
+// send tries to send the email sendAttempts times, counts and logs the result and returns the last error
+func (s *Sender) send(ctx context.Context, m message) error {
+	const fn = "email.send"
+
+	log := s.log.With(slog.String("fn", fn))
+
+	var err error
+	for attempt := 1; attempt <= sendAttempts; attempt++ {
+		if err = s.EmailSender.SendEmail(m.to, m.subject, m.text); err == nil {
+			s.save(m, models.EmailSent, "")
+			return nil
+		}
+
+		log.Warn("failed to send email", slog.Int("attempt", attempt), sl.Err(err))
+
+		if attempt == sendAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			s.save(m, models.EmailFailed, err.Error())
+			return err
+		}
+	}
+
+	log.Error("failed to send email", sl.Err(err))
+	s.save(m, models.EmailFailed, err.Error())
+
+	return err
+}
+
+func (s *Sender) save(m message, status, errText string) {
+	const fn = "email.save"
+
+	emailsTotal.Inc(status)
+
+	record := models.Email{Recipient: m.to, Subject: m.subject, Status: status, Error: errText}
+	if _, err := s.emailLog.SaveEmail(record); err != nil {
+		s.log.Error("failed to save email log", slog.String("fn", fn), sl.Err(err))
 	}
 }
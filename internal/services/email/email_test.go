@@ -0,0 +1,93 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+)
+
+// smtp fails the first failures attempts
+type smtp struct {
+	failures int
+	attempts int
+}
+
+func (s *smtp) SendEmail(string, string, string) error {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errors.New("smtp: 451 try again later")
+	}
+	return nil
+}
+
+type emailLog struct {
+	statuses chan string
+}
+
+func (l emailLog) SaveEmail(e models.Email) (int64, error) {
+	l.statuses <- e.Status
+	return 1, nil
+}
+
+// runSender starts Run of the sender and stops it when the test ends
+func runSender(t *testing.T, s EmailSender) (*Sender, emailLog) {
+	t.Helper()
+
+	retryDelay = time.Millisecond
+
+	log := emailLog{statuses: make(chan string, 10)}
+	sender := New(slog.New(slog.NewTextHandler(io.Discard, nil)), s, log)
+
+	done := make(chan struct{})
+	go func() {
+		sender.Run(context.Background())
+		close(done)
+	}()
+	t.Cleanup(func() {
+		sender.Close(context.Background())
+		<-done
+	})
+
+	return sender, log
+}
+
+func TestSendEmailRetries(t *testing.T) {
+	srv := &smtp{failures: sendAttempts - 1}
+	sender, log := runSender(t, srv)
+
+	if err := sender.SendEmail("ivan@example.com", "subject", "text"); err != nil {
+		t.Fatalf("SendEmail: %v", err)
+	}
+	if srv.attempts != sendAttempts {
+		t.Errorf("attempts = %d, want %d", srv.attempts, sendAttempts)
+	}
+	if status := <-log.statuses; status != models.EmailSent {
+		t.Errorf("logged status = %q, want %q", status, models.EmailSent)
+	}
+}
+
+func TestSendEmailReturnsFinalFailure(t *testing.T) {
+	srv := &smtp{failures: sendAttempts}
+	sender, log := runSender(t, srv)
+
+	if err := sender.SendEmail("ivan@example.com", "subject", "text"); err == nil {
+		t.Fatal("SendEmail succeeded, want the error of the last attempt")
+	}
+	if status := <-log.statuses; status != models.EmailFailed {
+		t.Errorf("logged status = %q, want %q", status, models.EmailFailed)
+	}
+}
+
+func TestSendEmailClosed(t *testing.T) {
+	sender, _ := runSender(t, &smtp{})
+	sender.Close(context.Background())
+
+	if err := sender.SendEmail("ivan@example.com", "subject", "text"); !errors.Is(err, ErrOutboxClosed) {
+		t.Fatalf("SendEmail = %v, want ErrOutboxClosed", err)
+	}
+}
@@ -1,39 +1,88 @@
 package employers
 
 import (
+	"errors"
 	"log/slog"
+	"strings"
 
+	"github.com/arxonic/gmh/internal/lib/email"
 	"github.com/arxonic/gmh/internal/lib/logger/sl"
 	"github.com/arxonic/gmh/internal/models"
 )
 
+var (
+	ErrDomainNotAllowed = errors.New("email domain is not allowed")
+	ErrDomainMismatch   = errors.New("email domain does not match organization")
+)
+
 type Employee struct {
 	log                *slog.Logger
 	employeeInfoGetter EmployeeInfoGetter
+	domains            map[string][]string
 }
 
 type EmployeeInfoGetter interface {
 	Employee(email string) (models.Emp, error)
 }
 
-// New returns a new instance of the Employee service to fetch employee from organization
-func New(log *slog.Logger, employeeInfoGetter EmployeeInfoGetter) *Employee {
+// New returns a new instance of the Employee service to fetch employee from organization.
+// domains maps organization name to its corporate email domains, empty map disables the checks
+func New(log *slog.Logger, employeeInfoGetter EmployeeInfoGetter, domains map[string][]string) *Employee {
 	return &Employee{
 		log:                log,
 		employeeInfoGetter: employeeInfoGetter,
+		domains:            domains,
+	}
+}
+
+// AllowedDomain reports whether email belongs to a domain of any configured organization
+func (e *Employee) AllowedDomain(addr string) bool {
+	if len(e.domains) == 0 {
+		return true
+	}
+
+	domain := email.Domain(addr)
+	for org := range e.domains {
+		if e.orgHasDomain(org, domain) {
+			return true
+		}
 	}
+
+	return false
 }
 
-func (e *Employee) Employee(email string) (models.Emp, error) {
+func (e *Employee) Employee(addr string) (models.Emp, error) {
 	const fn = "employers.Employee"
 
 	log := e.log.With(slog.String("fn", fn))
 
-	emp, err := e.employeeInfoGetter.Employee(email)
+	if !e.AllowedDomain(addr) {
+		return models.Emp{}, ErrDomainNotAllowed
+	}
+
+	emp, err := e.employeeInfoGetter.Employee(addr)
 	if err != nil {
 		log.Error("failed to fetch employee from organization", sl.Err(err))
 		return models.Emp{}, err
 	}
 
+	if len(e.domains) > 0 && !e.orgHasDomain(emp.Employer.Name, email.Domain(addr)) {
+		log.Warn("suspicious registration: email domain does not match organization",
+			slog.String("email", addr),
+			slog.String("organization", emp.Employer.Name),
+		)
+		return models.Emp{}, ErrDomainMismatch
+	}
+
 	return emp, nil
 }
+
+func (e *Employee) orgHasDomain(org, domain string) bool {
+	for _, d := range e.domains[org] {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/lib/ratelimit"
+)
+
+// Kinds of rate limited subjects
+const (
+	KindMessengerUser = "messenger_user" // any update from the messenger user
+	KindRegistration  = "registration"   // email attempts from the messenger user
+	KindEmail         = "email"          // emails sent to the address
+	KindIP            = "ip"             // HTTP requests from the address
+)
+
+var (
+	// ErrLimited is returned when the subject exceeded its limit and has just been locked out
+	ErrLimited = errors.New("too many requests")
+	// ErrLocked is returned while the subject is locked out
+	ErrLocked = errors.New("temporarily locked")
+)
+
+// Limit is the token bucket configuration: PerMinute tokens are refilled up to Burst
+type Limit struct {
+	PerMinute float64
+	Burst     int
+}
+
+type Guard struct {
+	log        *slog.Logger
+	lockouts   LockoutStorage
+	lockoutTTL time.Duration
+	limiters   map[string]*ratelimit.Limiter
+}
+
+type LockoutStorage interface {
+	SaveLockout(kind, subject, reason string, until time.Time) error
+	LockedUntil(kind, subject string) (time.Time, error)
+	DeleteLockouts(endedBefore time.Time) (int64, error)
+}
+
+// New returns a new instance of the Guard service which protects from abuse by limits of each kind.
+// Limits with zero burst are disabled
+func New(log *slog.Logger, lockouts LockoutStorage, lockoutTTL time.Duration, limits map[string]Limit) *Guard {
+	limiters := make(map[string]*ratelimit.Limiter, len(limits))
+	for kind, l := range limits {
+		if l.Burst <= 0 {
+			continue
+		}
+		limiters[kind] = ratelimit.New(l.PerMinute/60, l.Burst)
+	}
+
+	return &Guard{
+		log:        log,
+		lockouts:   lockouts,
+		lockoutTTL: lockoutTTL,
+		limiters:   limiters,
+	}
+}
+
+// Allow takes a token from the subject bucket. Exceeding the limit locks the subject out for the lockout TTL.
+// Kinds without configured limit are always allowed
+func (g *Guard) Allow(kind, subject string) error {
+	const fn = "guard.Allow"
+
+	log := g.log.With(slog.String("fn", fn), slog.String("kind", kind))
+
+	limiter, ok := g.limiters[kind]
+	if !ok {
+		return nil
+	}
+
+	until, err := g.lockouts.LockedUntil(kind, subject)
+	if err != nil {
+		// Storage failure must not lock out everybody, buckets still work
+		log.Error("failed to check lockout", sl.Err(err))
+	}
+	if time.Now().Before(until) {
+		return ErrLocked
+	}
+
+	if limiter.Allow(subject) {
+		return nil
+	}
+
+	until = time.Now().Add(g.lockoutTTL)
+	if err := g.lockouts.SaveLockout(kind, subject, "rate limit exceeded", until); err != nil {
+		log.Error("failed to save lockout", sl.Err(err))
+	}
+
+	log.Warn("subject locked out", slog.String("subject", subject), slog.Time("until", until))
+
+	return ErrLimited
+}
+
+// LockedUntil return the end of the subject lockout or zero time
+func (g *Guard) LockedUntil(kind, subject string) time.Time {
+	until, err := g.lockouts.LockedUntil(kind, subject)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return until
+}
+
+// RunJanitor deletes ended lockouts every interval until ctx is done
+func (g *Guard) RunJanitor(ctx context.Context, interval time.Duration) {
+	const fn = "guard.RunJanitor"
+
+	log := g.log.With(slog.String("fn", fn))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := g.lockouts.DeleteLockouts(time.Now())
+			if err != nil {
+				log.Error("failed to delete ended lockouts", sl.Err(err))
+				continue
+			}
+			if n > 0 {
+				log.Info("ended lockouts deleted", slog.Int64("count", n))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package guard
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeLockouts keeps lockouts in memory, err fails the lockout check
+type fakeLockouts struct {
+	until map[string]time.Time
+	err   error
+}
+
+func newFakeLockouts() *fakeLockouts {
+	return &fakeLockouts{until: make(map[string]time.Time)}
+}
+
+func (f *fakeLockouts) SaveLockout(kind, subject, _ string, until time.Time) error {
+	f.until[kind+":"+subject] = until
+	return nil
+}
+
+func (f *fakeLockouts) LockedUntil(kind, subject string) (time.Time, error) {
+	if f.err != nil {
+		return time.Time{}, f.err
+	}
+	return f.until[kind+":"+subject], nil
+}
+
+func (f *fakeLockouts) DeleteLockouts(time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newTestGuard(lockouts LockoutStorage, ttl time.Duration, limits map[string]Limit) *Guard {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), lockouts, ttl, limits)
+}
+
+func TestAllowBurst(t *testing.T) {
+	g := newTestGuard(newFakeLockouts(), time.Hour, map[string]Limit{KindEmail: {PerMinute: 1, Burst: 3}})
+
+	for i := 0; i < 3; i++ {
+		if err := g.Allow(KindEmail, "ivan@example.com"); err != nil {
+			t.Fatalf("attempt %d: %v, want allowed within the burst", i+1, err)
+		}
+	}
+
+	if err := g.Allow(KindEmail, "ivan@example.com"); !errors.Is(err, ErrLimited) {
+		t.Fatalf("attempt over the burst: %v, want %v", err, ErrLimited)
+	}
+
+	// Buckets are per subject
+	if err := g.Allow(KindEmail, "petr@example.com"); err != nil {
+		t.Errorf("other subject: %v, want allowed", err)
+	}
+}
+
+func TestAllowLockout(t *testing.T) {
+	lockouts := newFakeLockouts()
+	// The bucket refills in 10ms, the lockout holds the subject back longer
+	g := newTestGuard(lockouts, 50*time.Millisecond, map[string]Limit{KindRegistration: {PerMinute: 6000, Burst: 1}})
+
+	// Two attempts in a row exceed the burst before a token is refilled
+	_ = g.Allow(KindRegistration, "123")
+	if err := g.Allow(KindRegistration, "123"); !errors.Is(err, ErrLimited) {
+		t.Fatalf("second attempt: %v, want %v", err, ErrLimited)
+	}
+
+	if until := g.LockedUntil(KindRegistration, "123"); !until.After(time.Now()) {
+		t.Fatalf("LockedUntil = %v, want the lockout saved", until)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if err := g.Allow(KindRegistration, "123"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("attempt while locked out: %v, want %v", err, ErrLocked)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if err := g.Allow(KindRegistration, "123"); err != nil {
+		t.Errorf("attempt after the lockout: %v, want allowed", err)
+	}
+}
+
+func TestAllowUnlimitedKind(t *testing.T) {
+	g := newTestGuard(newFakeLockouts(), time.Hour, map[string]Limit{
+		KindEmail: {PerMinute: 1, Burst: 1},
+		KindIP:    {PerMinute: 1, Burst: 0},
+	})
+
+	for i := 0; i < 10; i++ {
+		if err := g.Allow(KindIP, "127.0.0.1"); err != nil {
+			t.Fatalf("disabled limit: %v, want allowed", err)
+		}
+		if err := g.Allow(KindMessengerUser, "123"); err != nil {
+			t.Fatalf("not configured limit: %v, want allowed", err)
+		}
+	}
+}
+
+func TestAllowStorageFailure(t *testing.T) {
+	lockouts := newFakeLockouts()
+	lockouts.err = errors.New("database is locked")
+	g := newTestGuard(lockouts, time.Hour, map[string]Limit{KindEmail: {PerMinute: 1, Burst: 1}})
+
+	if err := g.Allow(KindEmail, "ivan@example.com"); err != nil {
+		t.Fatalf("first attempt: %v, want allowed when lockouts can't be checked", err)
+	}
+	if err := g.Allow(KindEmail, "ivan@example.com"); !errors.Is(err, ErrLimited) {
+		t.Errorf("second attempt: %v, want the bucket still limiting", err)
+	}
+}
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Statuses of the report and of single checks
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+var ErrTimeout = errors.New("check timed out")
+
+// Checker checks a dependency, it should respect ctx deadline
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to use ordinary functions as Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the result of a single check
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the readiness of the application with a result of each check
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type Health struct {
+	log     *slog.Logger
+	timeout time.Duration
+
+	mx       sync.RWMutex
+	checkers map[string]Checker
+}
+
+// New returns a new instance of the Health service. Each check is limited by timeout
+func New(log *slog.Logger, timeout time.Duration) *Health {
+	return &Health{
+		log:      log,
+		timeout:  timeout,
+		checkers: make(map[string]Checker),
+	}
+}
+
+// Register adds the checker under the name, checker with the same name is replaced
+func (h *Health) Register(name string, c Checker) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	h.checkers[name] = c
+}
+
+// Ready runs all checks in parallel. The report is ok only if all checks passed
+func (h *Health) Ready(ctx context.Context) Report {
+	const fn = "health.Ready"
+
+	log := h.log.With(slog.String("fn", fn))
+
+	h.mx.RLock()
+	checkers := make(map[string]Checker, len(h.checkers))
+	for name, c := range h.checkers {
+		checkers[name] = c
+	}
+	h.mx.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checkers))}
+
+	var (
+		wg sync.WaitGroup
+		mx sync.Mutex
+	)
+	for name, c := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res := h.check(ctx, c)
+			if res.Status != StatusOK {
+				log.Warn("readiness check failed", slog.String("check", name), slog.String("error", res.Error))
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+
+			report.Checks[name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (h *Health) check(ctx context.Context, c Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// Checker may ignore ctx, so the result is awaited no longer than timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+
+	res := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = ErrTimeout
+		}
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	return res
+}
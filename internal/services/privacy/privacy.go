@@ -0,0 +1,157 @@
+package privacy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+)
+
+type Privacy struct {
+	log          *slog.Logger
+	userProvider UserProvider
+	userDeleter  UserDeleter
+	notifier     Notifier
+	catalog      *i18n.Catalog
+}
+
+type UserProvider interface {
+	UserIDByMessengerID(messengerType string, id int64) (int64, error)
+	User(id int64) (models.User, error)
+	OrganizationsByUserID(uID int64) ([]models.Organization, error)
+	UserMessengers(uID int64) ([]models.UserMessenger, error)
+	Subscriptions(subID int64) ([]models.Subscription, error)
+	Subscribers(uID int64) ([]models.Subscription, error)
+	CelebrationsByUserID(uID int64) ([]models.Celebration, error)
+	SessionsByUserID(uID int64) ([]models.Session, error)
+	EmailsByUserID(uID int64) ([]models.Email, error)
+	LockoutsByUserID(uID int64) ([]models.Lockout, error)
+	APIKeysByCreator(uID int64) ([]models.APIKey, error)
+}
+
+type UserDeleter interface {
+	DeleteUser(uID int64) ([]models.UserMessenger, error)
+}
+
+// Notifier sends messages to the chats of its messenger type
+type Notifier interface {
+	Type() string
+	Notify(chatID int64, text string) error
+}
+
+// New returns a new instance of the Privacy service to export and delete users data on their request
+func New(log *slog.Logger, userProvider UserProvider, userDeleter UserDeleter, notifier Notifier, catalog *i18n.Catalog) *Privacy {
+	return &Privacy{
+		log:          log,
+		userProvider: userProvider,
+		userDeleter:  userDeleter,
+		notifier:     notifier,
+		catalog:      catalog,
+	}
+}
+
+// Export return JSON document with everything stored about the user
+func (p *Privacy) Export(messengerType string, messengerID int64) ([]byte, error) {
+	const fn = "privacy.Export"
+
+	log := p.log.With(slog.String("fn", fn))
+
+	uID, err := p.userProvider.UserIDByMessengerID(messengerType, messengerID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	data := models.UserData{ExportedAt: time.Now().UTC()}
+
+	if data.User, err = p.userProvider.User(uID); err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Organizations, err = p.userProvider.OrganizationsByUserID(uID); err != nil {
+		log.Error("failed to get user organizations", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Messengers, err = p.userProvider.UserMessengers(uID); err != nil {
+		log.Error("failed to get user messengers", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Subscriptions, err = p.userProvider.Subscriptions(uID); err != nil {
+		log.Error("failed to get user subscriptions", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Subscribers, err = p.userProvider.Subscribers(uID); err != nil {
+		log.Error("failed to get user subscribers", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Celebrations, err = p.userProvider.CelebrationsByUserID(uID); err != nil {
+		log.Error("failed to get user celebrations", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Sessions, err = p.userProvider.SessionsByUserID(uID); err != nil {
+		log.Error("failed to get user sessions", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Emails, err = p.userProvider.EmailsByUserID(uID); err != nil {
+		log.Error("failed to get user emails", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.Lockouts, err = p.userProvider.LockoutsByUserID(uID); err != nil {
+		log.Error("failed to get user lockouts", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if data.APIKeys, err = p.userProvider.APIKeysByCreator(uID); err != nil {
+		log.Error("failed to get user API keys", sl.Err(err))
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	buf, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return buf, nil
+}
+
+// Delete removes the user from all tables and notifies subscribers of his birthday
+func (p *Privacy) Delete(messengerType string, messengerID int64) error {
+	const fn = "privacy.Delete"
+
+	log := p.log.With(slog.String("fn", fn))
+
+	uID, err := p.userProvider.UserIDByMessengerID(messengerType, messengerID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	user, err := p.userProvider.User(uID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	affected, err := p.userDeleter.DeleteUser(uID)
+	if err != nil {
+		log.Error("failed to delete user", sl.Err(err))
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	log.Info("user deleted", slog.Int64("uid", uID), slog.Int("affected", len(affected)))
+
+	args := i18n.Args{"name": user.FirstName + " " + user.LastName}
+	for _, m := range affected {
+		if m.MessengerType != p.notifier.Type() || !m.Reachable() {
+			continue
+		}
+
+		text := p.catalog.Localizer(m.Languages()...).T("notify.account_deleted", args)
+		if err := p.notifier.Notify(m.ChatID, text); err != nil {
+			log.Warn("failed to notify subscriber", slog.Int64("uid", m.UserID), sl.Err(err))
+		}
+	}
+
+	return nil
+}
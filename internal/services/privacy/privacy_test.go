@@ -0,0 +1,110 @@
+package privacy
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/models"
+)
+
+// provider returns one record of every kind of user data
+type provider struct{}
+
+func (provider) UserIDByMessengerID(string, int64) (int64, error) { return 1, nil }
+func (provider) User(int64) (models.User, error) {
+	return models.User{ID: 1, Email: "ivan@example.com"}, nil
+}
+func (provider) OrganizationsByUserID(int64) ([]models.Organization, error) {
+	return []models.Organization{{Name: "org"}}, nil
+}
+func (provider) UserMessengers(int64) ([]models.UserMessenger, error) {
+	return []models.UserMessenger{{UserID: 1, MessengerType: "telegram"}}, nil
+}
+func (provider) Subscriptions(int64) ([]models.Subscription, error) {
+	return []models.Subscription{{SubID: 1}}, nil
+}
+func (provider) Subscribers(int64) ([]models.Subscription, error) {
+	return []models.Subscription{{UserID: 1}}, nil
+}
+func (provider) CelebrationsByUserID(int64) ([]models.Celebration, error) {
+	return []models.Celebration{{UserID: 1, Birthday: time.Now()}}, nil
+}
+
+func (provider) SessionsByUserID(int64) ([]models.Session, error) {
+	return []models.Session{{MessengerType: "telegram"}}, nil
+}
+func (provider) EmailsByUserID(int64) ([]models.Email, error) {
+	return []models.Email{{Recipient: "ivan@example.com"}}, nil
+}
+func (provider) LockoutsByUserID(int64) ([]models.Lockout, error) {
+	return []models.Lockout{{Subject: "ivan@example.com"}}, nil
+}
+func (provider) APIKeysByCreator(int64) ([]models.APIKey, error) {
+	return []models.APIKey{{CreatedBy: 1}}, nil
+}
+
+func TestExport(t *testing.T) {
+	p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), provider{}, nil, nil, nil)
+
+	data, err := p.Export("telegram", 42)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+
+	// Every kind of stored user data must be exported
+	for _, key := range []string{"user", "organizations", "messengers", "subscriptions", "subscribers", "celebrations",
+		"sessions", "emails", "lockouts", "api_keys"} {
+		v, ok := sections[key]
+		if !ok || string(v) == "null" || string(v) == "[]" {
+			t.Errorf("section %q is missing or empty: %s", key, v)
+		}
+	}
+}
+
+// deleter deletes the user with subscribers in chats of two messengers
+type deleter struct{}
+
+func (deleter) DeleteUser(int64) ([]models.UserMessenger, error) {
+	return []models.UserMessenger{
+		{UserID: 2, MessengerType: "telegram", ChatID: 10},
+		{UserID: 3, MessengerType: "other", ChatID: 20},
+	}, nil
+}
+
+// notifier records the chats of telegram notifications
+type notifier struct{ chats []int64 }
+
+func (n *notifier) Type() string { return "telegram" }
+
+func (n *notifier) Notify(chatID int64, _ string) error {
+	n.chats = append(n.chats, chatID)
+	return nil
+}
+
+func TestDeleteNotifiesChatsOfNotifierType(t *testing.T) {
+	catalog, err := i18n.Load("en", i18n.Args{"company": "ACME"}, "")
+	if err != nil {
+		t.Fatalf("i18n.Load: %v", err)
+	}
+
+	n := &notifier{}
+	p := New(slog.New(slog.NewTextHandler(io.Discard, nil)), provider{}, deleter{}, n, catalog)
+
+	if err := p.Delete("telegram", 42); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if !slices.Equal(n.chats, []int64{10}) {
+		t.Errorf("notified chats = %v, want only the telegram chat [10]", n.chats)
+	}
+}
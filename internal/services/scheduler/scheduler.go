@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/birthday"
+	"github.com/arxonic/gmh/internal/lib/i18n"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+type Scheduler struct {
+	log                *slog.Logger
+	birthdayProvider   BirthdayProvider
+	celebrationCreator CelebrationCreator
+	notifier           Notifier
+	catalog            *i18n.Catalog
+	interval           time.Duration
+	daysBefore         int
+
+	// mx prevents parallel runs by the timer and by an admin
+	mx sync.Mutex
+}
+
+type BirthdayProvider interface {
+	UsersWhoseBirthdayIsInXDays(x int) ([]models.User, error)
+	Subscribers(uID int64) ([]models.Subscription, error)
+	UserMessengers(uID int64) ([]models.UserMessenger, error)
+}
+
+type CelebrationCreator interface {
+	CreateCelebration(uID int64, birthday time.Time) (int64, error)
+}
+
+// Notifier sends messages to the chats of its messenger type
+type Notifier interface {
+	Type() string
+	Notify(chatID int64, text string) error
+}
+
+// New returns a new instance of the Scheduler which creates celebrations daysBefore days before birthdays
+func New(
+	log *slog.Logger,
+	birthdayProvider BirthdayProvider,
+	celebrationCreator CelebrationCreator,
+	notifier Notifier,
+	catalog *i18n.Catalog,
+	interval time.Duration,
+	daysBefore int,
+) *Scheduler {
+	return &Scheduler{
+		log:                log,
+		birthdayProvider:   birthdayProvider,
+		celebrationCreator: celebrationCreator,
+		notifier:           notifier,
+		catalog:            catalog,
+		interval:           interval,
+		daysBefore:         daysBefore,
+	}
+}
+
+// Run calls RunOnce every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	const fn = "scheduler.Run"
+
+	log := s.log.With(slog.String("fn", fn))
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunOnce(); err != nil {
+			log.Error("scheduler run failed", sl.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce creates celebrations for upcoming birthdays and notifies subscribers.
+// Returns the number of created celebrations
+func (s *Scheduler) RunOnce() (int, error) {
+	const fn = "scheduler.RunOnce"
+
+	log := s.log.With(slog.String("fn", fn))
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	users, err := s.birthdayProvider.UsersWhoseBirthdayIsInXDays(s.daysBefore)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	created := 0
+	for _, user := range users {
+		subs, err := s.birthdayProvider.Subscribers(user.ID)
+		if err != nil {
+			return created, fmt.Errorf("%s:%w", fn, err)
+		}
+		if len(subs) == 0 {
+			continue
+		}
+
+		date := birthday.Next(user.BirthDate, time.Now())
+		_, err = s.celebrationCreator.CreateCelebration(user.ID, date)
+		if errors.Is(err, repo.ErrCelebrationExists) {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		created++
+		log.Info("celebration created", slog.Int64("uid", user.ID), slog.Int("subscribers", len(subs)))
+
+		s.notify(log, subs, "notify.birthday", i18n.Args{
+			i18n.CountArg: birthday.DaysLeft(user.BirthDate, time.Now()),
+			"date":        date.Format("02.01"),
+			"name":        user.FirstName + " " + user.LastName,
+		})
+	}
+
+	return created, nil
+}
+
+// notify sends the message to subscribers, each in the language of the messenger account.
+// The chat link of the subscription is the link variable of the message
+func (s *Scheduler) notify(log *slog.Logger, subs []models.Subscription, key string, args i18n.Args) {
+	for _, sub := range subs {
+		messengers, err := s.birthdayProvider.UserMessengers(sub.SubID)
+		if err != nil {
+			log.Warn("failed to get subscriber messengers", slog.Int64("uid", sub.SubID), sl.Err(err))
+			continue
+		}
+
+		for _, m := range messengers {
+			// The user blocked the bot, messages are not delivered. Chats of other messengers are not notified
+			if m.MessengerType != s.notifier.Type() || !m.Reachable() {
+				continue
+			}
+
+			text := s.catalog.Localizer(m.Languages()...).T(key, args, i18n.Args{"link": sub.Link})
+			if err := s.notifier.Notify(m.ChatID, text); err != nil {
+				log.Warn("failed to notify subscriber", slog.Int64("uid", sub.SubID), sl.Err(err))
+			}
+		}
+	}
+}
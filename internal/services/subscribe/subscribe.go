@@ -1,9 +1,24 @@
 package subscribe
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"sort"
+	"time"
 
+	"github.com/arxonic/gmh/internal/lib/birthday"
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
 	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrNotActivated         = errors.New("account is not activated")
+	ErrSelfSubscription     = errors.New("can't subscribe to yourself")
+	ErrAlreadySubscribed    = errors.New("alredy subscribed")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
 )
 
 type Sub struct {
@@ -15,12 +30,17 @@ type Sub struct {
 type UserProvider interface {
 	User(id int64) (models.User, error)
 	UserIDsByOrgID(id int64) ([]int64, error)
-	UserIDByMessengerID(id int64) (int64, error)
+	UserIDByMessengerID(messengerType string, id int64) (int64, error)
+	IsUserActivated(uID int64) (bool, error)
+	OrganizationsByUserID(uID int64) ([]models.Organization, error)
+	UsersByBirthdayDays(from, to int) ([]models.User, error)
 }
 
 type Subscriber interface {
 	FindOrgByFields(...string) ([]string, error)
 	Subscribe(subID, uID int64) (int64, error)
+	Unsubscribe(subID, uID int64) error
+	Subscriptions(subID int64) ([]models.Subscription, error)
 }
 
 func New(log *slog.Logger, s Subscriber, up UserProvider) *Sub {
@@ -31,20 +51,172 @@ func New(log *slog.Logger, s Subscriber, up UserProvider) *Sub {
 	}
 }
 
-func (s *Sub) Subscribe(messengerSubID, uID int64) error {
-	subID, err := s.UserProvider.UserIDByMessengerID(messengerSubID)
+// CheckCaller checks that the user calling the API exists and is activated
+func (s *Sub) CheckCaller(uID int64) error {
+	if _, err := s.UserProvider.User(uID); err != nil {
+		if errors.Is(err, repo.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	return s.checkActivated(uID)
+}
+
+// Subscribe subscribes the messenger account owner on the birthday of the user uID
+func (s *Sub) Subscribe(messengerType string, messengerSubID, uID int64) error {
+	subID, err := s.UserProvider.UserIDByMessengerID(messengerType, messengerSubID)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.Subscriber.Subscribe(subID, uID)
+	return s.SubscribeUser(subID, uID)
+}
+
+// SubscribeUser subscribes subID on the birthday of uID.
+// Only activated users can subscribe and nobody can subscribe to himself
+func (s *Sub) SubscribeUser(subID, uID int64) error {
+	const fn = "subscribe.SubscribeUser"
+
+	if subID == uID {
+		return ErrSelfSubscription
+	}
+
+	if err := s.checkActivated(subID); err != nil {
+		return err
+	}
+
+	if _, err := s.UserProvider.User(uID); err != nil {
+		if errors.Is(err, repo.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	_, err := s.Subscriber.Subscribe(subID, uID)
+	if errors.Is(err, repo.ErrSubscriptionExists) {
+		return ErrAlreadySubscribed
+	}
 	if err != nil {
+		s.log.Error("failed to subscribe", slog.String("fn", fn), sl.Err(err))
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
+
+// Unsubscribe removes subscription of subID on the birthday of uID
+func (s *Sub) Unsubscribe(subID, uID int64) error {
+	const fn = "subscribe.Unsubscribe"
+
+	if err := s.checkActivated(subID); err != nil {
 		return err
 	}
 
+	err := s.Subscriber.Unsubscribe(subID, uID)
+	if errors.Is(err, repo.ErrSubscriptionNotFound) {
+		return ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
 	return nil
 }
 
+// Subscriptions return nearest birthdays of the users subID is subscribed to
+func (s *Sub) Subscriptions(subID int64) ([]models.Birthday, error) {
+	const fn = "subscribe.Subscriptions"
+
+	subs, err := s.Subscriber.Subscriptions(subID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	now := time.Now()
+	birthdays := make([]models.Birthday, 0, len(subs))
+	for _, sub := range subs {
+		user, err := s.UserProvider.User(sub.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		birthdays = append(birthdays, models.Birthday{
+			User:       user,
+			Date:       birthday.Next(user.BirthDate, now),
+			DaysLeft:   birthday.DaysLeft(user.BirthDate, now),
+			Subscribed: true,
+		})
+	}
+
+	return birthdays, nil
+}
+
+// UpcomingBirthdays return birthdays of uID colleagues in the next days including today.
+// Colleagues are users from organizations with the same name
+func (s *Sub) UpcomingBirthdays(uID int64, days int) ([]models.Birthday, error) {
+	const fn = "subscribe.UpcomingBirthdays"
+
+	orgs, err := s.UserProvider.OrganizationsByUserID(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	orgNames := make(map[string]bool, len(orgs))
+	for _, org := range orgs {
+		orgNames[org.Name] = true
+	}
+
+	subs, err := s.Subscriber.Subscriptions(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	subscribed := make(map[int64]bool, len(subs))
+	for _, sub := range subs {
+		subscribed[sub.UserID] = true
+	}
+
+	users, err := s.UserProvider.UsersByBirthdayDays(0, days)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	now := time.Now()
+	birthdays := make([]models.Birthday, 0)
+	for _, user := range users {
+		if user.ID == uID {
+			continue
+		}
+
+		userOrgs, err := s.UserProvider.OrganizationsByUserID(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		colleague := false
+		for _, org := range userOrgs {
+			colleague = colleague || orgNames[org.Name]
+		}
+		if !colleague {
+			continue
+		}
+
+		birthdays = append(birthdays, models.Birthday{
+			User:       user,
+			Date:       birthday.Next(user.BirthDate, now),
+			DaysLeft:   birthday.DaysLeft(user.BirthDate, now),
+			Subscribed: subscribed[user.ID],
+		})
+	}
+
+	sort.Slice(birthdays, func(i, j int) bool {
+		return birthdays[i].DaysLeft < birthdays[j].DaysLeft
+	})
+
+	return birthdays, nil
+}
+
 func (s *Sub) FindUser(fields ...string) ([]string, error) {
 	orgs, err := s.FindOrgByFields(fields...)
 	if err != nil {
@@ -80,3 +252,15 @@ func (s *Sub) UsersByOrgID(id int64) ([]models.User, error) {
 
 	return users, nil
 }
+
+func (s *Sub) checkActivated(uID int64) error {
+	activated, err := s.UserProvider.IsUserActivated(uID)
+	if err != nil {
+		return err
+	}
+	if !activated {
+		return ErrNotActivated
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package users
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/logger/sl"
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidInput  = errors.New("invalid input")
+)
+
+type Users struct {
+	log          *slog.Logger
+	userProvider UserProvider
+	userUpdater  UserUpdater
+}
+
+type UserProvider interface {
+	User(id int64) (models.User, error)
+	UserByEmail(email string) (models.User, error)
+	Users(filter models.UserFilter, afterID int64, limit int) ([]models.User, error)
+	OrganizationsByUserID(uID int64) ([]models.Organization, error)
+	UserMessengers(uID int64) ([]models.UserMessenger, error)
+	UserMessenger(messenger string, messengerID int64) (models.UserMessenger, error)
+}
+
+type UserUpdater interface {
+	UpdateUser(models.User) error
+	SetUserActivation(uID int64, activated bool) error
+	SetMessengerLanguage(messenger string, messengerID int64, lang string) error
+	SetChatReachable(messenger string, chatID int64, reachable bool) error
+}
+
+// ProfileUpdate contains profile fields to change, nil fields are kept
+type ProfileUpdate struct {
+	FirstName  *string
+	LastName   *string
+	Patronymic *string
+	BirthDate  *time.Time
+}
+
+// Page is a part of the list and the cursor of the next part, empty if it is the last one
+type Page struct {
+	Users      []models.UserInfo
+	NextCursor string
+}
+
+// New returns a new instance of the Users service to manage users and their organization membership
+func New(log *slog.Logger, userProvider UserProvider, userUpdater UserUpdater) *Users {
+	return &Users{
+		log:          log,
+		userProvider: userProvider,
+		userUpdater:  userUpdater,
+	}
+}
+
+// List return users matching the filter starting after the cursor
+func (u *Users) List(filter models.UserFilter, cursor string, limit int) (Page, error) {
+	const fn = "users.List"
+
+	log := u.log.With(slog.String("fn", fn))
+
+	afterID, err := decodeCursor(cursor)
+	if err != nil {
+		return Page{}, ErrInvalidCursor
+	}
+
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	// One extra row tells whether there is a next page
+	users, err := u.userProvider.Users(filter, afterID, limit+1)
+	if err != nil {
+		log.Error("failed to list users", sl.Err(err))
+		return Page{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	var page Page
+	if len(users) > limit {
+		users = users[:limit]
+		page.NextCursor = encodeCursor(users[limit-1].ID)
+	}
+
+	page.Users = make([]models.UserInfo, 0, len(users))
+	for _, user := range users {
+		info, err := u.info(user)
+		if err != nil {
+			log.Error("failed to get user info", sl.Err(err))
+			return Page{}, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		page.Users = append(page.Users, info)
+	}
+
+	return page, nil
+}
+
+// User return user with organizations by ID
+func (u *Users) User(id int64) (models.UserInfo, error) {
+	const fn = "users.User"
+
+	user, err := u.userProvider.User(id)
+	if err != nil {
+		return models.UserInfo{}, u.wrap(fn, err)
+	}
+
+	info, err := u.info(user)
+	if err != nil {
+		return models.UserInfo{}, u.wrap(fn, err)
+	}
+
+	return info, nil
+}
+
+// UserByEmail return user with organizations by email
+func (u *Users) UserByEmail(email string) (models.UserInfo, error) {
+	const fn = "users.UserByEmail"
+
+	user, err := u.userProvider.UserByEmail(email)
+	if err != nil {
+		return models.UserInfo{}, u.wrap(fn, err)
+	}
+
+	info, err := u.info(user)
+	if err != nil {
+		return models.UserInfo{}, u.wrap(fn, err)
+	}
+
+	return info, nil
+}
+
+// UpdateProfile changes profile fields of the user
+func (u *Users) UpdateProfile(id int64, upd ProfileUpdate) (models.UserInfo, error) {
+	const fn = "users.UpdateProfile"
+
+	user, err := u.userProvider.User(id)
+	if err != nil {
+		return models.UserInfo{}, u.wrap(fn, err)
+	}
+
+	for _, f := range []struct {
+		name  string
+		value *string
+		dst   *string
+	}{
+		{"first_name", upd.FirstName, &user.FirstName},
+		{"last_name", upd.LastName, &user.LastName},
+		{"patronymic", upd.Patronymic, &user.Patronymic},
+	} {
+		if f.value == nil {
+			continue
+		}
+		if strings.TrimSpace(*f.value) == "" {
+			return models.UserInfo{}, fmt.Errorf("%w: %s is empty", ErrInvalidInput, f.name)
+		}
+		*f.dst = strings.TrimSpace(*f.value)
+	}
+
+	if upd.BirthDate != nil {
+		if upd.BirthDate.After(time.Now()) {
+			return models.UserInfo{}, fmt.Errorf("%w: birth_date is in the future", ErrInvalidInput)
+		}
+		user.BirthDate = *upd.BirthDate
+	}
+
+	if err := u.userUpdater.UpdateUser(user); err != nil {
+		return models.UserInfo{}, u.wrap(fn, err)
+	}
+
+	return u.User(id)
+}
+
+// Deactivate deactivates all messenger accounts of the user
+func (u *Users) Deactivate(id int64) (models.UserInfo, error) {
+	const fn = "users.Deactivate"
+
+	if err := u.userUpdater.SetUserActivation(id, false); err != nil {
+		return models.UserInfo{}, u.wrap(fn, err)
+	}
+
+	return u.User(id)
+}
+
+// Language returns the language chosen by the owner of the messenger account, empty if it follows the messenger settings
+func (u *Users) Language(messengerType string, messengerID int64) (string, error) {
+	const fn = "users.Language"
+
+	m, err := u.userProvider.UserMessenger(messengerType, messengerID)
+	if err != nil {
+		return "", u.wrap(fn, err)
+	}
+
+	return m.Language, nil
+}
+
+// SetLanguage saves the language of messages to the messenger account, empty lang follows the messenger settings
+func (u *Users) SetLanguage(messengerType string, messengerID int64, lang string) error {
+	const fn = "users.SetLanguage"
+
+	if err := u.userUpdater.SetMessengerLanguage(messengerType, messengerID, lang); err != nil {
+		return u.wrap(fn, err)
+	}
+
+	return nil
+}
+
+// SetReachable marks the chat unreachable when the user blocked the bot, or reachable after unblocking.
+// Unreachable chats are skipped by notifications
+func (u *Users) SetReachable(messengerType string, chatID int64, reachable bool) error {
+	const fn = "users.SetReachable"
+
+	if err := u.userUpdater.SetChatReachable(messengerType, chatID, reachable); err != nil {
+		return u.wrap(fn, err)
+	}
+
+	return nil
+}
+
+func (u *Users) info(user models.User) (models.UserInfo, error) {
+	orgs, err := u.userProvider.OrganizationsByUserID(user.ID)
+	if err != nil {
+		return models.UserInfo{}, err
+	}
+
+	messengers, err := u.userProvider.UserMessengers(user.ID)
+	if err != nil {
+		return models.UserInfo{}, err
+	}
+
+	return models.UserInfo{User: user, Organizations: orgs, Messengers: messengers}, nil
+}
+
+func (u *Users) wrap(fn string, err error) error {
+	if errors.Is(err, repo.ErrUserNotFound) {
+		return ErrUserNotFound
+	}
+
+	u.log.Error("users storage failure", slog.String("fn", fn), sl.Err(err))
+
+	return fmt.Errorf("%s:%w", fn, err)
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("u:" + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, ok := strings.CutPrefix(string(raw), "u:")
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+
+	return strconv.ParseInt(id, 10, 64)
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/arxonic/gmh/internal/models"
+)
+
+// AdminRoles return all admin roles of the user
+func (s *Storage) AdminRoles(uID int64) ([]models.Admin, error) {
+	const fn = "storage.sqlite.AdminRoles"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT id, user_id, role, organization_id FROM admins WHERE user_id = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	admins := make([]models.Admin, 0)
+	for rows.Next() {
+		var a models.Admin
+		var orgID sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Role, &orgID); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+		if orgID.Valid {
+			a.OrganizationID = &orgID.Int64
+		}
+
+		admins = append(admins, a)
+	}
+
+	return admins, nil
+}
+
+// SaveAuditRecord save admin action into audit trail
+func (s *Storage) SaveAuditRecord(r models.AuditRecord) error {
+	const fn = "storage.sqlite.SaveAuditRecord"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("INSERT INTO admin_audit (admin_id, action, target, details) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(r.AdminID, r.Action, r.Target, r.Details); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
+
+// SaveAdmin save the admin role of the user and return its ID
+func (s *Storage) SaveAdmin(a models.Admin) (int64, error) {
+	const fn = "storage.sqlite.SaveAdmin"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("INSERT INTO admins (user_id, role, organization_id) VALUES (?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var orgID sql.NullInt64
+	if a.OrganizationID != nil {
+		orgID = sql.NullInt64{Int64: *a.OrganizationID, Valid: true}
+	}
+
+	res, err := stmt.Exec(a.UserID, a.Role, orgID)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,200 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+const apiKeyColumns = "id, name, key_hash, scopes, created_by, subject_id, expires_at, last_used_at, revoked_at, created_at"
+
+// SaveAPIKey save the API key and return its ID
+func (s *Storage) SaveAPIKey(k models.APIKey) (int64, error) {
+	const fn = "storage.sqlite.SaveAPIKey"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("INSERT INTO api_keys (name, key_hash, scopes, created_by, subject_id, expires_at) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var expiresAt sql.NullTime
+	if k.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: k.ExpiresAt.UTC(), Valid: true}
+	}
+
+	var subjectID sql.NullInt64
+	if k.SubjectID != nil {
+		subjectID = sql.NullInt64{Int64: *k.SubjectID, Valid: true}
+	}
+
+	res, err := stmt.Exec(k.Name, k.Hash, k.ScopesString(), k.CreatedBy, subjectID, expiresAt)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s:%w", fn, repo.ErrAPIKeyExists)
+		}
+
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return id, nil
+}
+
+// APIKeyByHash return the API key by the hash of its value, including revoked and expired keys
+func (s *Storage) APIKeyByHash(hash string) (models.APIKey, error) {
+	const fn = "storage.sqlite.APIKeyByHash"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT " + apiKeyColumns + " FROM api_keys WHERE key_hash = ?")
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	defer stmt.Close()
+
+	k, err := scanAPIKey(stmt.QueryRow(hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, repo.ErrAPIKeyNotFound
+		}
+		return models.APIKey{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return k, nil
+}
+
+// APIKeys return all not revoked API keys
+func (s *Storage) APIKeys() ([]models.APIKey, error) {
+	const fn = "storage.sqlite.APIKeys"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT " + apiKeyColumns + " FROM api_keys WHERE revoked_at IS NULL ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// APIKeysByCreator return API keys issued by the user, revoked ones too
+func (s *Storage) APIKeysByCreator(uID int64) ([]models.APIKey, error) {
+	const fn = "storage.sqlite.APIKeysByCreator"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT " + apiKeyColumns + " FROM api_keys WHERE created_by = ? ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// TouchAPIKey set the last usage time of the API key
+func (s *Storage) TouchAPIKey(id int64, usedAt time.Time) error {
+	const fn = "storage.sqlite.TouchAPIKey"
+	defer observeQuery(fn)()
+
+	if _, err := s.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", usedAt.UTC(), id); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey marks the API key revoked, it can't be used after that
+func (s *Storage) RevokeAPIKey(id int64) error {
+	const fn = "storage.sqlite.RevokeAPIKey"
+	defer observeQuery(fn)()
+
+	res, err := s.db.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	var subjectID sql.NullInt64
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&k.ID, &k.Name, &k.Hash, &scopes, &k.CreatedBy, &subjectID, &expiresAt, &lastUsedAt, &revokedAt, &k.CreatedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	k.Scopes = strings.Fields(scopes)
+	if subjectID.Valid {
+		k.SubjectID = &subjectID.Int64
+	}
+	k.ExpiresAt = nullTime(expiresAt)
+	k.LastUsedAt = nullTime(lastUsedAt)
+	k.RevokedAt = nullTime(revokedAt)
+
+	return k, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
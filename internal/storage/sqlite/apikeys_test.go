@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+func TestSaveAPIKeyNameOfRevokedKey(t *testing.T) {
+	s := newTestStorage(t)
+
+	key := models.APIKey{Name: "portal", Hash: "hash1", Scopes: []string{models.ScopeReadUsers}, CreatedBy: 1}
+	id, err := s.SaveAPIKey(key)
+	if err != nil {
+		t.Fatalf("save key: %v", err)
+	}
+
+	key.Hash = "hash2"
+	if _, err := s.SaveAPIKey(key); !errors.Is(err, repo.ErrAPIKeyExists) {
+		t.Fatalf("save key with the name of an active key = %v, want ErrAPIKeyExists", err)
+	}
+
+	if err := s.RevokeAPIKey(id); err != nil {
+		t.Fatalf("revoke key: %v", err)
+	}
+	if _, err := s.SaveAPIKey(key); err != nil {
+		t.Fatalf("save key with the name of a revoked key: %v", err)
+	}
+}
+
+func TestRevokeAPIKeyNotFound(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.RevokeAPIKey(100); !errors.Is(err, repo.ErrAPIKeyNotFound) {
+		t.Fatalf("RevokeAPIKey = %v, want ErrAPIKeyNotFound", err)
+	}
+}
+
+func TestAPIKeySubject(t *testing.T) {
+	s := newTestStorage(t)
+
+	uID := int64(1)
+	for _, k := range []models.APIKey{
+		{Name: "portal", Hash: "hash1", Scopes: []string{models.ScopeAdmin}, CreatedBy: 1},
+		{Name: "personal:1", Hash: "hash2", Scopes: []string{models.ScopeReadSubscriptions}, CreatedBy: 1, SubjectID: &uID},
+	} {
+		if _, err := s.SaveAPIKey(k); err != nil {
+			t.Fatalf("SaveAPIKey(%s): %v", k.Name, err)
+		}
+	}
+
+	portal, err := s.APIKeyByHash("hash1")
+	if err != nil || portal.SubjectID != nil {
+		t.Errorf("service key = %+v, %v, want no subject", portal, err)
+	}
+
+	personal, err := s.APIKeyByHash("hash2")
+	if err != nil || personal.SubjectID == nil || *personal.SubjectID != uID {
+		t.Errorf("personal key = %+v, %v, want subject %d", personal, err, uID)
+	}
+}
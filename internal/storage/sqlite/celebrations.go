@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+const celebrationColumns = `c.id, c.user_id, c.birthday, c.link, c.status, c.created_at,
+	(SELECT COUNT(*) FROM subscribes s WHERE s.user_id = c.user_id)`
+
+// CreateCelebration save active Celebration of the user birthday and return its ID
+func (s *Storage) CreateCelebration(uID int64, birthday time.Time) (int64, error) {
+	const fn = "storage.sqlite.CreateCelebration"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("INSERT INTO celebrations (user_id, birthday, status) VALUES (?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(uID, birthday.Format(time.DateOnly), models.CelebrationActive)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s:%w", fn, repo.ErrCelebrationExists)
+		}
+
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return id, nil
+}
+
+// Celebration return Celebration model by its ID
+func (s *Storage) Celebration(id int64) (models.Celebration, error) {
+	const fn = "storage.sqlite.Celebration"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT " + celebrationColumns + " FROM celebrations c WHERE c.id = ?")
+	if err != nil {
+		return models.Celebration{}, err
+	}
+	defer stmt.Close()
+
+	c, err := scanCelebration(stmt.QueryRow(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Celebration{}, repo.ErrCelebrationNotFound
+		}
+		return models.Celebration{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return c, nil
+}
+
+// Celebrations return Celebrations with the status and the birthday not in the past
+func (s *Storage) Celebrations(status string) ([]models.Celebration, error) {
+	const fn = "storage.sqlite.Celebrations"
+	defer observeQuery(fn)()
+
+	return s.celebrations(fn,
+		"SELECT "+celebrationColumns+" FROM celebrations c WHERE c.status = ? AND c.birthday >= ? ORDER BY c.birthday",
+		status, time.Now().Format(time.DateOnly),
+	)
+}
+
+// CelebrationsByUserID return all Celebrations of the user birthdays
+func (s *Storage) CelebrationsByUserID(uID int64) ([]models.Celebration, error) {
+	const fn = "storage.sqlite.CelebrationsByUserID"
+	defer observeQuery(fn)()
+
+	return s.celebrations(fn, "SELECT "+celebrationColumns+" FROM celebrations c WHERE c.user_id = ? ORDER BY c.birthday", uID)
+}
+
+// CancelCelebration set cancelled status to the Celebration
+func (s *Storage) CancelCelebration(id int64) error {
+	const fn = "storage.sqlite.CancelCelebration"
+	defer observeQuery(fn)()
+
+	res, err := s.db.Exec("UPDATE celebrations SET status = ? WHERE id = ?", models.CelebrationCancelled, id)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrCelebrationNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) celebrations(fn, q string, args ...any) ([]models.Celebration, error) {
+	stmt, err := s.db.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	celebrations := make([]models.Celebration, 0)
+	for rows.Next() {
+		c, err := scanCelebration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		celebrations = append(celebrations, c)
+	}
+
+	return celebrations, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCelebration(row scanner) (models.Celebration, error) {
+	var c models.Celebration
+	var link sql.NullString
+	err := row.Scan(&c.ID, &c.UserID, &c.Birthday, &link, &c.Status, &c.CreatedAt, &c.Subscribers)
+	c.Link = link.String
+
+	return c, err
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/arxonic/gmh/internal/models"
+)
+
+// SaveEmail save the email sending result into the email log
+func (s *Storage) SaveEmail(e models.Email) (int64, error) {
+	const fn = "storage.sqlite.SaveEmail"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("INSERT INTO emails (recipient, subject, status, error) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(e.Recipient, e.Subject, e.Status, sql.NullString{String: e.Error, Valid: e.Error != ""})
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return id, nil
+}
+
+// Emails return the latest limit emails with the status
+func (s *Storage) Emails(status string, limit int) ([]models.Email, error) {
+	const fn = "storage.sqlite.Emails"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare(`SELECT id, recipient, subject, status, error, created_at FROM emails
+	WHERE status = ? ORDER BY created_at DESC, id DESC LIMIT ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	emails := make([]models.Email, 0)
+	for rows.Next() {
+		var e models.Email
+		var errText sql.NullString
+		if err := rows.Scan(&e.ID, &e.Recipient, &e.Subject, &e.Status, &errText, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+		e.Error = errText.String
+
+		emails = append(emails, e)
+	}
+
+	return emails, nil
+}
+
+// EmailsByUserID return the log of emails sent to the user email
+func (s *Storage) EmailsByUserID(uID int64) ([]models.Email, error) {
+	const fn = "storage.sqlite.EmailsByUserID"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare(`SELECT id, recipient, subject, status, error, created_at FROM emails
+	WHERE lower(recipient) IN (SELECT lower(email) FROM users WHERE id = ?) ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	emails := make([]models.Email, 0)
+	for rows.Next() {
+		var e models.Email
+		var errText sql.NullString
+		if err := rows.Scan(&e.ID, &e.Recipient, &e.Subject, &e.Status, &errText, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+		e.Error = errText.String
+
+		emails = append(emails, e)
+	}
+
+	return emails, nil
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the latest migration the storage code relies on
+const SchemaVersion = 15
+
+// Check pings the database and checks that migrations are applied up to SchemaVersion
+func (s *Storage) Check(ctx context.Context) error {
+	const fn = "storage.sqlite.Check"
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	var (
+		version int64
+		dirty   bool
+	)
+	err := s.db.QueryRowContext(ctx, "SELECT version, dirty FROM migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: no migrations applied", fn)
+	}
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	if dirty {
+		return fmt.Errorf("%s: migration %d is dirty", fn, version)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("%s: schema version %d, want %d", fn, version, SchemaVersion)
+	}
+
+	return nil
+}
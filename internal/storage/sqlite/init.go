@@ -19,3 +19,14 @@ func New(storagePath string) (*Storage, error) {
 
 	return &Storage{db: db}, nil
 }
+
+// Close closes the database, it must not be used after that
+func (s *Storage) Close() error {
+	const fn = "storage.sqlite.Close"
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
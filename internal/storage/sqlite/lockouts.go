@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+)
+
+// lockoutSubjects selects subjects of the user with ID ?1: the email and the messenger accounts
+// as "<messenger_type>:<messenger_id>"
+const lockoutSubjects = `SELECT lower(email) FROM users WHERE id = ?1
+	UNION SELECT messenger_type || ':' || messenger_id FROM user_messengers WHERE user_id = ?1`
+
+// SaveLockout save temporary lockout of the subject
+func (s *Storage) SaveLockout(kind, subject, reason string, until time.Time) error {
+	const fn = "storage.sqlite.SaveLockout"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("INSERT INTO lockouts (kind, subject, reason, locked_until) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(kind, subject, reason, until.UTC()); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
+
+// LockedUntil return the end of the latest lockout of the subject or zero time if there is none
+func (s *Storage) LockedUntil(kind, subject string) (time.Time, error) {
+	const fn = "storage.sqlite.LockedUntil"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT locked_until FROM lockouts WHERE kind = ? AND subject = ? ORDER BY locked_until DESC LIMIT 1")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer stmt.Close()
+
+	var until time.Time
+	err = stmt.QueryRow(kind, subject).Scan(&until)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return until, nil
+}
+
+// DeleteLockouts removes lockouts ended before the time and returns their number
+func (s *Storage) DeleteLockouts(endedBefore time.Time) (int64, error) {
+	const fn = "storage.sqlite.DeleteLockouts"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("DELETE FROM lockouts WHERE locked_until < ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(endedBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return n, nil
+}
+
+// LockoutsByUserID return lockouts of the user email and messenger accounts
+func (s *Storage) LockoutsByUserID(uID int64) ([]models.Lockout, error) {
+	const fn = "storage.sqlite.LockoutsByUserID"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT id, kind, subject, reason, locked_until, created_at FROM lockouts WHERE subject IN (" + lockoutSubjects + ") ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	lockouts := make([]models.Lockout, 0)
+	for rows.Next() {
+		var l models.Lockout
+		var reason sql.NullString
+		if err := rows.Scan(&l.ID, &l.Kind, &l.Subject, &reason, &l.LockedUntil, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+		l.Reason = reason.String
+
+		lockouts = append(lockouts, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return lockouts, nil
+}
@@ -0,0 +1,35 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeleteLockouts(t *testing.T) {
+	s := newTestStorage(t)
+
+	now := time.Now()
+	for subject, until := range map[string]time.Time{
+		"ended":  now.Add(-time.Minute),
+		"active": now.Add(time.Minute),
+	} {
+		if err := s.SaveLockout("ip", subject, "test", until); err != nil {
+			t.Fatalf("save lockout: %v", err)
+		}
+	}
+
+	n, err := s.DeleteLockouts(now)
+	if err != nil {
+		t.Fatalf("delete lockouts: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("deleted %d lockouts, want 1", n)
+	}
+
+	if until, _ := s.LockedUntil("ip", "ended"); !until.IsZero() {
+		t.Errorf("ended lockout is kept until %v", until)
+	}
+	if until, _ := s.LockedUntil("ip", "active"); until.IsZero() {
+		t.Error("active lockout is deleted")
+	}
+}
@@ -4,21 +4,56 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/arxonic/gmh/internal/models"
 	repo "github.com/arxonic/gmh/internal/storage"
+	"github.com/mattn/go-sqlite3"
 )
 
-// UpdateUserActivationStatus is activating user account
+// UpdateUserActivationStatus is activating user account if the token matches and is not expired.
+// The token is checked first, so a wrong link tells nothing about the account. The token is kept
+// after activation, the same link then reports that the account is already activated
 func (s *Storage) UpdateUserActivationStatus(messengerType string, messengerID, chatID int64, token string) error {
 	const fn = "storage.sqlite.SaveUserActivationStatus"
+	defer observeQuery(fn)()
 
-	q := `UPDATE user_messengers SET is_activated = ?, token = ? 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	defer tx.Rollback()
+
+	var isActivated bool
+	var storedToken sql.NullString
+	var expiresAt sql.NullTime
+	err = tx.QueryRow(
+		"SELECT is_activated, token, token_expires_at FROM user_messengers WHERE messenger_type = ? AND messenger_id = ? AND chat_id = ?",
+		messengerType,
+		messengerID,
+		chatID,
+	).Scan(&isActivated, &storedToken, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repo.ErrUserNotFound
+		}
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	switch {
+	case token == "" || storedToken.String != token:
+		return repo.ErrInvalidToken
+	case isActivated:
+		return repo.ErrAlreadyActivated
+	case expiresAt.Valid && time.Now().After(expiresAt.Time):
+		return repo.ErrTokenExpired
+	}
+
+	q := `UPDATE user_messengers SET is_activated = ?, token_expires_at = NULL
 	WHERE messenger_type = ? AND messenger_id = ? AND chat_id = ? AND token = ?`
-	_, err := s.db.Exec(
+	_, err = tx.Exec(
 		q,
 		1,
-		"",
 		messengerType,
 		messengerID,
 		chatID,
@@ -28,12 +63,76 @@ func (s *Storage) UpdateUserActivationStatus(messengerType string, messengerID,
 		return fmt.Errorf("%s:%w", fn, err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
 	return nil
 }
 
+// UpdateActivationToken replace auth token of not activated user account
+func (s *Storage) UpdateActivationToken(messengerType string, messengerID, chatID int64, token string, expiresAt time.Time) error {
+	const fn = "storage.sqlite.UpdateActivationToken"
+	defer observeQuery(fn)()
+
+	q := `UPDATE user_messengers SET token = ?, token_expires_at = ?
+	WHERE messenger_type = ? AND messenger_id = ? AND chat_id = ? AND is_activated = 0`
+	res, err := s.db.Exec(q, token, expiresAt, messengerType, messengerID, chatID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrAlreadyActivated
+	}
+
+	return nil
+}
+
+// SetUserActivation activates or deactivates all messenger accounts of the user.
+// Activation links sent before stop working, so a deactivated account is not activated again by an old link
+func (s *Storage) SetUserActivation(uID int64, activated bool) error {
+	const fn = "storage.sqlite.SetUserActivation"
+	defer observeQuery(fn)()
+
+	res, err := s.db.Exec("UPDATE user_messengers SET is_activated = ?, token = '', token_expires_at = NULL WHERE user_id = ?", activated, uID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// IsUserActivated reports whether any messenger account of the user is activated
+func (s *Storage) IsUserActivated(uID int64) (bool, error) {
+	const fn = "storage.sqlite.IsUserActivated"
+	defer observeQuery(fn)()
+
+	var isActivated bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM user_messengers WHERE user_id = ? AND is_activated = 1)", uID).Scan(&isActivated)
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return isActivated, nil
+}
+
 // IsActivated return Activation Account status by Messenger Info
 func (s *Storage) IsActivated(messengerType string, messengerID, chatID int64) (bool, error) {
 	const fn = "storage.sqlite.IsActivated"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("SELECT is_activated FROM user_messengers WHERE messenger_type = ? AND messenger_id = ? AND chat_id = ?")
 	if err != nil {
@@ -56,30 +155,96 @@ func (s *Storage) IsActivated(messengerType string, messengerID, chatID int64) (
 // UserMessenger return UserMessenger model by Messenger Type and users MessengerID into this messenger
 func (s *Storage) UserMessenger(messenger string, messengerID int64) (models.UserMessenger, error) {
 	const fn = "storage.sqlite.UserMessenger"
+	defer observeQuery(fn)()
 
-	stmt, err := s.db.Prepare("SELECT * FROM user_messengers WHERE messenger_type = ? AND messenger_id = ?")
+	stmt, err := s.db.Prepare("SELECT user_id, messenger_type, messenger_id, chat_id, is_activated, token, token_expires_at, language, client_language, unreachable_at FROM user_messengers WHERE messenger_type = ? AND messenger_id = ?")
 	if err != nil {
 		return models.UserMessenger{}, err
 	}
 	defer stmt.Close()
 
-	var userMessenger models.UserMessenger
-	err = stmt.QueryRow(messenger, messengerID).Scan(&userMessenger)
+	rows, err := stmt.Query(messenger, messengerID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return models.UserMessenger{}, repo.ErrUserNotFound
-		}
 		return models.UserMessenger{}, fmt.Errorf("%s:%w", fn, err)
 	}
+	defer rows.Close()
+
+	messengers, err := scanUserMessengers(rows, fn)
+	if err != nil {
+		return models.UserMessenger{}, err
+	}
+	if len(messengers) == 0 {
+		return models.UserMessenger{}, repo.ErrUserNotFound
+	}
 
-	return userMessenger, nil
+	return messengers[0], nil
+}
+
+// SetMessengerLanguage saves the language chosen by the user, empty lang resets the choice
+func (s *Storage) SetMessengerLanguage(messenger string, messengerID int64, lang string) error {
+	const fn = "storage.sqlite.SetMessengerLanguage"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("UPDATE user_messengers SET language = ? WHERE messenger_type = ? AND messenger_id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(nullString(lang), messenger, messengerID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetChatReachable marks the chat unreachable when the user blocked the bot, or reachable again
+func (s *Storage) SetChatReachable(messenger string, chatID int64, reachable bool) error {
+	const fn = "storage.sqlite.SetChatReachable"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("UPDATE user_messengers SET unreachable_at = ? WHERE messenger_type = ? AND chat_id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var unreachableAt sql.NullTime
+	if !reachable {
+		unreachableAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	res, err := stmt.Exec(unreachableAt, messenger, chatID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrUserNotFound
+	}
+
+	return nil
 }
 
 // SaveUserMessenger save user messenger info and return new row ID
 func (s *Storage) SaveUserMessenger(data models.UserMessenger) (int64, error) {
 	const fn = "storage.sqlite.SaveUserMessenger"
+	defer observeQuery(fn)()
 
-	stmt, err := s.db.Prepare("INSERT INTO user_messengers (user_id, messenger_type, messenger_id, chat_id, is_activated, token) VALUES (?, ?, ?, ?, ?, ?)")
+	stmt, err := s.db.Prepare("INSERT INTO user_messengers (user_id, messenger_type, messenger_id, chat_id, is_activated, token, token_expires_at, language, client_language) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return 0, err
 	}
@@ -92,8 +257,17 @@ func (s *Storage) SaveUserMessenger(data models.UserMessenger) (int64, error) {
 		data.ChatID,
 		data.IsActivated,
 		data.Token,
+		data.TokenExpiresAt,
+		nullString(data.Language),
+		nullString(data.ClientLanguage),
 	)
 	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s:%w", fn, repo.ErrMessengerExists)
+		}
+
 		return 0, fmt.Errorf("%s:%w", fn, err)
 	}
 
@@ -105,23 +279,80 @@ func (s *Storage) SaveUserMessenger(data models.UserMessenger) (int64, error) {
 	return id, nil
 }
 
-func (s *Storage) UserIDByMessengerID(id int64) (int64, error) {
+// UserIDByMessengerID returns the owner of the messenger account, IDs of different messengers may be the same
+func (s *Storage) UserIDByMessengerID(messengerType string, id int64) (int64, error) {
 	const fn = "storage.sqlite.UserIDByMessengerID"
+	defer observeQuery(fn)()
 
-	stmt, err := s.db.Prepare("SELECT user_id FROM user_messengers WHERE messenger_id = ?")
+	stmt, err := s.db.Prepare("SELECT user_id FROM user_messengers WHERE messenger_type = ? AND messenger_id = ?")
 	if err != nil {
 		return 0, err
 	}
 	defer stmt.Close()
 
 	var uID int64
-	err = stmt.QueryRow(id).Scan(&uID)
+	err = stmt.QueryRow(messengerType, id).Scan(&uID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, repo.ErrOrganizationNotFound
+			return 0, repo.ErrUserNotFound
 		}
 		return 0, fmt.Errorf("%s:%w", fn, err)
 	}
 
 	return uID, nil
 }
+
+// UserMessengers return all UserMessenger models of the user
+func (s *Storage) UserMessengers(uID int64) ([]models.UserMessenger, error) {
+	const fn = "storage.sqlite.UserMessengers"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT user_id, messenger_type, messenger_id, chat_id, is_activated, token, token_expires_at, language, client_language, unreachable_at FROM user_messengers WHERE user_id = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	return scanUserMessengers(rows, fn)
+}
+
+func scanUserMessengers(rows *sql.Rows, fn string) ([]models.UserMessenger, error) {
+	messengers := make([]models.UserMessenger, 0)
+	for rows.Next() {
+		var m models.UserMessenger
+		var token sql.NullString
+		var expiresAt sql.NullTime
+		var lang, clientLang sql.NullString
+		var unreachableAt sql.NullTime
+		if err := rows.Scan(&m.UserID, &m.MessengerType, &m.MessengerID, &m.ChatID, &m.IsActivated, &token, &expiresAt, &lang, &clientLang, &unreachableAt); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+		m.Token = token.String
+		m.Language = lang.String
+		m.ClientLanguage = clientLang.String
+		if expiresAt.Valid {
+			m.TokenExpiresAt = &expiresAt.Time
+		}
+		if unreachableAt.Valid {
+			m.UnreachableAt = &unreachableAt.Time
+		}
+
+		messengers = append(messengers, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return messengers, nil
+}
+
+// nullString stores empty s as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+func TestUpdateUserActivationStatus(t *testing.T) {
+	s := newTestStorage(t)
+
+	// Ivan of 2_fill_test is not activated and has token123
+	const mType, mID, chatID = "telegram", 123456789, 987654321
+
+	if err := s.UpdateUserActivationStatus(mType, mID, chatID, "guess"); !errors.Is(err, repo.ErrInvalidToken) {
+		t.Fatalf("wrong token before activation = %v, want ErrInvalidToken", err)
+	}
+
+	if err := s.UpdateUserActivationStatus(mType, mID, chatID, "token123"); err != nil {
+		t.Fatalf("activation: %v", err)
+	}
+
+	// A stale or guessed link must not reveal that the account is activated
+	if err := s.UpdateUserActivationStatus(mType, mID, chatID, "guess"); !errors.Is(err, repo.ErrInvalidToken) {
+		t.Errorf("wrong token after activation = %v, want ErrInvalidToken", err)
+	}
+	if err := s.UpdateUserActivationStatus(mType, mID, chatID, ""); !errors.Is(err, repo.ErrInvalidToken) {
+		t.Errorf("empty token after activation = %v, want ErrInvalidToken", err)
+	}
+
+	if err := s.UpdateUserActivationStatus(mType, mID, chatID, "token123"); !errors.Is(err, repo.ErrAlreadyActivated) {
+		t.Errorf("same link again = %v, want ErrAlreadyActivated", err)
+	}
+}
+
+func TestSetUserActivationRevokesLinks(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.SetUserActivation(1, false); err != nil {
+		t.Fatalf("SetUserActivation: %v", err)
+	}
+
+	if err := s.UpdateUserActivationStatus("telegram", 123456789, 987654321, "token123"); !errors.Is(err, repo.ErrInvalidToken) {
+		t.Errorf("old link after deactivation = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestUserIDByMessengerIDOfType(t *testing.T) {
+	s := newTestStorage(t)
+
+	// Petr has the account of another messenger with the ID of Ivan's telegram account
+	exec(t, s, `INSERT INTO user_messengers (user_id, messenger_type, messenger_id, chat_id) VALUES (2, 'other', 123456789, 1)`)
+
+	for _, tt := range []struct {
+		mType string
+		want  int64
+	}{
+		{"telegram", 1},
+		{"other", 2},
+	} {
+		uID, err := s.UserIDByMessengerID(tt.mType, 123456789)
+		if err != nil {
+			t.Fatalf("UserIDByMessengerID(%s): %v", tt.mType, err)
+		}
+		if uID != tt.want {
+			t.Errorf("UserIDByMessengerID(%s) = %d, want %d", tt.mType, uID, tt.want)
+		}
+	}
+
+	if _, err := s.UserIDByMessengerID("unknown", 123456789); err == nil {
+		t.Error("UserIDByMessengerID of unknown messenger found a user")
+	}
+}
+
+func TestSaveUserMessengerOnce(t *testing.T) {
+	s := newTestStorage(t)
+
+	// Ivan's telegram account of 2_fill_test registered again by Petr
+	_, err := s.SaveUserMessenger(models.UserMessenger{UserID: 2, MessengerType: "telegram", MessengerID: 123456789, ChatID: 1})
+	if !errors.Is(err, repo.ErrMessengerExists) {
+		t.Errorf("SaveUserMessenger = %v, want ErrMessengerExists", err)
+	}
+}
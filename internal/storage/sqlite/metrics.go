@@ -0,0 +1,20 @@
+package sqlite
+
+import (
+	"strings"
+	"time"
+
+	"github.com/arxonic/gmh/internal/lib/metrics"
+)
+
+var queryDuration = metrics.NewHistogramVec("sqlite_query_duration_seconds",
+	"Duration of storage methods in seconds.", metrics.DefBuckets, "method")
+
+// observeQuery starts timing of the storage method fn, the returned func is meant to be deferred
+func observeQuery(fn string) func() {
+	start := time.Now()
+
+	return func() {
+		queryDuration.Observe(metrics.Since(start), strings.TrimPrefix(fn, "storage.sqlite."))
+	}
+}
@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// TestMigrateLockoutSubjects checks that lockouts of messenger users are kept by the messenger subject
+func TestMigrateLockoutSubjects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	m, err := migrate.New("file://../../../migrations", fmt.Sprintf("sqlite3://%s?x-migrations-table=migrations", path))
+	if err != nil {
+		t.Fatalf("init migrations: %v", err)
+	}
+	defer m.Close()
+
+	if err := m.Migrate(12); err != nil {
+		t.Fatalf("migrate to 12: %v", err)
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	defer s.Close()
+
+	const insert = `INSERT INTO lockouts (kind, subject, locked_until) VALUES (?, ?, datetime('now', '+1 hour'))`
+	exec(t, s, insert, "tg_user", "123456789")
+	exec(t, s, insert, "registration", "123456789")
+	exec(t, s, insert, "email", "ivan.ivanov@example.com")
+	exec(t, s, insert, "ip", "127.0.0.1")
+
+	want := func(kind, subject string) {
+		t.Helper()
+
+		if n := count(t, s, `SELECT 1 FROM lockouts WHERE kind = ? AND subject = ?`, kind, subject); n != 1 {
+			t.Errorf("lockouts of %s %s = %d, want 1", kind, subject, n)
+		}
+	}
+
+	if err := m.Migrate(13); err != nil {
+		t.Fatalf("migrate to 13: %v", err)
+	}
+	want("messenger_user", "telegram:123456789")
+	want("registration", "telegram:123456789")
+	want("email", "ivan.ivanov@example.com")
+	want("ip", "127.0.0.1")
+
+	exec(t, s, insert, "messenger_user", "other:1")
+
+	if err := m.Migrate(12); err != nil {
+		t.Fatalf("migrate back to 12: %v", err)
+	}
+	want("tg_user", "123456789")
+	want("registration", "123456789")
+	want("email", "ivan.ivanov@example.com")
+	if n := count(t, s, `SELECT 1 FROM lockouts`); n != 4 {
+		t.Errorf("lockouts = %d, want 4 without the other messenger", n)
+	}
+}
+
+// TestMigrateUserMessengersUnique checks that repeated registrations of a messenger account are removed
+func TestMigrateUserMessengersUnique(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	m, err := migrate.New("file://../../../migrations", fmt.Sprintf("sqlite3://%s?x-migrations-table=migrations", path))
+	if err != nil {
+		t.Fatalf("init migrations: %v", err)
+	}
+	defer m.Close()
+
+	if err := m.Migrate(13); err != nil {
+		t.Fatalf("migrate to 13: %v", err)
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	defer s.Close()
+
+	// Ivan's telegram account registered again with another email and activated, Ivan's row is not activated
+	exec(t, s, `INSERT INTO users (id, first_name, last_name, patronymic, birth_date, email) VALUES (100, 'a', 'b', 'c', '2000-01-01', 'other@example.com')`)
+	exec(t, s, `INSERT INTO user_messengers (user_id, messenger_type, messenger_id, chat_id, is_activated) VALUES (100, 'telegram', 123456789, 1, 1)`)
+
+	if err := m.Migrate(14); err != nil {
+		t.Fatalf("migrate to 14: %v", err)
+	}
+
+	if n := count(t, s, `SELECT 1 FROM user_messengers WHERE messenger_type = 'telegram' AND messenger_id = 123456789`); n != 1 {
+		t.Fatalf("rows of the account = %d, want 1", n)
+	}
+	if uID, err := s.UserIDByMessengerID("telegram", 123456789); err != nil || uID != 100 {
+		t.Errorf("owner = %d, %v, want the activated registration of 100", uID, err)
+	}
+}
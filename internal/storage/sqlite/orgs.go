@@ -13,6 +13,7 @@ import (
 // Organization return Organization model by OrganizationID
 func (s *Storage) Organization(orgID int64) (models.Organization, error) {
 	const fn = "storage.sqlite.Organization"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("SELECT id, name, city, office, department FROM organizations WHERE id = ?")
 	if err != nil {
@@ -35,6 +36,7 @@ func (s *Storage) Organization(orgID int64) (models.Organization, error) {
 // SaveOrganization save Organization into storage and return his OrganizationID
 func (s *Storage) SaveOrganization(org models.Organization) (int64, error) {
 	const fn = "storage.sqlite.SaveOrganization"
+	defer observeQuery(fn)()
 
 	// Check if Exist
 	stmt, err := s.db.Prepare("SELECT id FROM organizations WHERE name = ? AND city = ? AND office = ? AND department = ?")
@@ -81,6 +83,7 @@ func (s *Storage) SaveOrganization(org models.Organization) (int64, error) {
 // SaveUserOrganization save assignments by UserID and OrganizationID and return new row ID
 func (s *Storage) SaveUserOrganization(uID, orgID int64) (int64, error) {
 	const fn = "storage.sqlite.SaveUserOrganization"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("INSERT INTO user_organizations (user_id, organization_id) VALUES (?, ?)")
 	if err != nil {
@@ -103,6 +106,7 @@ func (s *Storage) SaveUserOrganization(uID, orgID int64) (int64, error) {
 
 func (s *Storage) UserIDsByOrgID(id int64) ([]int64, error) {
 	const fn = "storage.sqlite.UserIDsByOrgID"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("SELECT user_id FROM user_organizations WHERE organization_id = ?")
 	if err != nil {
@@ -132,6 +136,7 @@ func (s *Storage) UserIDsByOrgID(id int64) ([]int64, error) {
 // !!! IF YOU PASSED ALL FIELDS IN THE FUNC - RETURNS []ID `organization` table
 func (s *Storage) FindOrgByFields(fields ...string) ([]string, error) {
 	const fn = "storage.sqlite.FindOrgByFields"
+	defer observeQuery(fn)()
 
 	q := ""
 	switch len(fields) {
@@ -182,3 +187,58 @@ func (s *Storage) FindOrgByFields(fields ...string) ([]string, error) {
 
 	return orgs, nil
 }
+
+// OrganizationsByUserID return all Organizations the user belongs to
+func (s *Storage) OrganizationsByUserID(uID int64) ([]models.Organization, error) {
+	const fn = "storage.sqlite.OrganizationsByUserID"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare(`SELECT o.id, o.name, o.city, o.office, o.department FROM organizations o
+	JOIN user_organizations uo ON uo.organization_id = o.id WHERE uo.user_id = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	orgs := make([]models.Organization, 0)
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.City, &org.Office, &org.Department); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		orgs = append(orgs, org)
+	}
+
+	return orgs, nil
+}
+
+// Organizations return all organizations ordered by name, city, office and department
+func (s *Storage) Organizations() ([]models.Organization, error) {
+	const fn = "storage.sqlite.Organizations"
+	defer observeQuery(fn)()
+
+	rows, err := s.db.Query("SELECT id, name, city, office, department FROM organizations ORDER BY name, city, office, department")
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	orgs := make([]models.Organization, 0)
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.City, &org.Office, &org.Department); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		orgs = append(orgs, org)
+	}
+
+	return orgs, nil
+}
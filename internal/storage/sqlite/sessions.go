@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+// Session return the conversation state of the messenger account
+func (s *Storage) Session(messengerType string, messengerID int64) (models.Session, error) {
+	const fn = "storage.sqlite.Session"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT messenger_type, messenger_id, state, data, updated_at FROM sessions WHERE messenger_type = ? AND messenger_id = ?")
+	if err != nil {
+		return models.Session{}, err
+	}
+	defer stmt.Close()
+
+	var session models.Session
+	err = stmt.QueryRow(messengerType, messengerID).Scan(&session.MessengerType, &session.MessengerID, &session.State, &session.Data, &session.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, repo.ErrSessionNotFound
+		}
+		return models.Session{}, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return session, nil
+}
+
+// SessionsByUserID return conversation states of the user messenger accounts
+func (s *Storage) SessionsByUserID(uID int64) ([]models.Session, error) {
+	const fn = "storage.sqlite.SessionsByUserID"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare(`SELECT s.messenger_type, s.messenger_id, s.state, s.data, s.updated_at FROM sessions s
+	JOIN user_messengers um ON um.messenger_type = s.messenger_type AND um.messenger_id = s.messenger_id
+	WHERE um.user_id = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	sessions := make([]models.Session, 0)
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.MessengerType, &session.MessengerID, &session.State, &session.Data, &session.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return sessions, nil
+}
+
+// SaveSession creates or replaces the conversation state of the messenger account
+func (s *Storage) SaveSession(session models.Session) error {
+	const fn = "storage.sqlite.SaveSession"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare(`INSERT INTO sessions (messenger_type, messenger_id, state, data, updated_at) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (messenger_type, messenger_id) DO UPDATE SET state = excluded.state, data = excluded.data, updated_at = excluded.updated_at`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(session.MessengerType, session.MessengerID, session.State, session.Data, time.Now().UTC()); err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return nil
+}
+
+// SessionCounts return the number of sessions of the messenger active since the time in each state
+func (s *Storage) SessionCounts(messengerType string, activeSince time.Time) (map[int]int, error) {
+	const fn = "storage.sqlite.SessionCounts"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("SELECT state, COUNT(*) FROM sessions WHERE messenger_type = ? AND updated_at >= ? GROUP BY state")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(messengerType, activeSince.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var state, n int
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+		counts[state] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return counts, nil
+}
+
+// DeleteSessions removes sessions of the messenger idle since the time and returns their number
+func (s *Storage) DeleteSessions(messengerType string, idleSince time.Time) (int64, error) {
+	const fn = "storage.sqlite.DeleteSessions"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("DELETE FROM sessions WHERE messenger_type = ? AND updated_at < ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(messengerType, idleSince.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return n, nil
+}
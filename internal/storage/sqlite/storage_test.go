@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// newTestStorage returns the storage of a new database with all migrations applied,
+// including the test data of 2_fill_test
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	m, err := migrate.New("file://../../../migrations", fmt.Sprintf("sqlite3://%s?x-migrations-table=migrations", path))
+	if err != nil {
+		t.Fatalf("init migrations: %v", err)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	m.Close()
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// exec runs the query of the test setup
+func exec(t *testing.T, s *Storage, query string, args ...any) {
+	t.Helper()
+
+	if _, err := s.db.Exec(query, args...); err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+// count returns the number of rows of the query
+func count(t *testing.T, s *Storage, query string, args ...any) int {
+	t.Helper()
+
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&n); err != nil {
+		t.Fatalf("count %q: %v", query, err)
+	}
+
+	return n
+}
@@ -1,9 +1,18 @@
 package sqlite
 
-import "fmt"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/arxonic/gmh/internal/models"
+	repo "github.com/arxonic/gmh/internal/storage"
+	"github.com/mattn/go-sqlite3"
+)
 
 func (s *Storage) Subscribe(subID, uID int64) (int64, error) {
 	const fn = "storage.sqlite.Subscribe"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("INSERT INTO subscribes (user_id, sub_id) VALUES (?, ?)")
 	if err != nil {
@@ -13,6 +22,13 @@ func (s *Storage) Subscribe(subID, uID int64) (int64, error) {
 
 	res, err := stmt.Exec(uID, subID)
 	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) &&
+			(sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique) {
+			return 0, fmt.Errorf("%s:%w", fn, repo.ErrSubscriptionExists)
+		}
+
 		return 0, fmt.Errorf("%s:%w", fn, err)
 	}
 
@@ -23,3 +39,73 @@ func (s *Storage) Subscribe(subID, uID int64) (int64, error) {
 
 	return id, nil
 }
+
+// Unsubscribe removes subscription of subID on the birthday of uID
+func (s *Storage) Unsubscribe(subID, uID int64) error {
+	const fn = "storage.sqlite.Unsubscribe"
+	defer observeQuery(fn)()
+
+	res, err := s.db.Exec("DELETE FROM subscribes WHERE user_id = ? AND sub_id = ?", uID, subID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// Subscriptions return subscriptions made by the subscriber subID
+func (s *Storage) Subscriptions(subID int64) ([]models.Subscription, error) {
+	const fn = "storage.sqlite.Subscriptions"
+	defer observeQuery(fn)()
+
+	return s.subscriptions(fn, "SELECT user_id, sub_id, link, expire FROM subscribes WHERE sub_id = ?", subID)
+}
+
+// Subscribers return subscriptions made on the birthday of the user uID
+func (s *Storage) Subscribers(uID int64) ([]models.Subscription, error) {
+	const fn = "storage.sqlite.Subscribers"
+	defer observeQuery(fn)()
+
+	return s.subscriptions(fn, "SELECT user_id, sub_id, link, expire FROM subscribes WHERE user_id = ?", uID)
+}
+
+func (s *Storage) subscriptions(fn, q string, args ...any) ([]models.Subscription, error) {
+	stmt, err := s.db.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	subs := make([]models.Subscription, 0)
+	for rows.Next() {
+		var sub models.Subscription
+		var link sql.NullString
+		var expire sql.NullTime
+		if err := rows.Scan(&sub.UserID, &sub.SubID, &link, &expire); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		sub.Link = link.String
+		if expire.Valid {
+			sub.Expire = &expire.Time
+		}
+
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
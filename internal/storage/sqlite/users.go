@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/arxonic/gmh/internal/models"
 	repo "github.com/arxonic/gmh/internal/storage"
@@ -13,6 +15,7 @@ import (
 // User return User model by UserID
 func (s *Storage) User(uID int64) (models.User, error) {
 	const fn = "storage.sqlite.User"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("SELECT id, first_name, last_name, patronymic, birth_date, email FROM users WHERE id = ?")
 	if err != nil {
@@ -34,6 +37,7 @@ func (s *Storage) User(uID int64) (models.User, error) {
 
 func (s *Storage) UserByEmail(email string) (models.User, error) {
 	const fn = "storage.sqlite.UserByEmail"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("SELECT id, first_name, last_name, patronymic, birth_date, email FROM users WHERE email = ?")
 	if err != nil {
@@ -53,23 +57,134 @@ func (s *Storage) UserByEmail(email string) (models.User, error) {
 	return user, nil
 }
 
-// UsersWhoseBirthdayIsInXDays return []User whose birthday is in X days
+// Users return up to limit users with ID greater than afterID matching the filter, ordered by ID
+func (s *Storage) Users(filter models.UserFilter, afterID int64, limit int) ([]models.User, error) {
+	const fn = "storage.sqlite.Users"
+	defer observeQuery(fn)()
+
+	conds := []string{"u.id > ?"}
+	args := []any{afterID}
+
+	orgFields := []struct {
+		column string
+		value  string
+	}{
+		{"o.name", filter.Organization},
+		{"o.city", filter.City},
+		{"o.office", filter.Office},
+		{"o.department", filter.Department},
+	}
+	for _, f := range orgFields {
+		if f.value != "" {
+			conds = append(conds, f.column+" = ?")
+			args = append(args, f.value)
+		}
+	}
+
+	if filter.OrganizationID != 0 {
+		conds = append(conds, "o.id = ?")
+		args = append(args, filter.OrganizationID)
+	}
+
+	if filter.Activated != nil {
+		activated := "EXISTS (SELECT 1 FROM user_messengers um WHERE um.user_id = u.id AND um.is_activated = 1)"
+		if !*filter.Activated {
+			activated = "NOT " + activated
+		}
+		conds = append(conds, activated)
+	}
+
+	q := `SELECT DISTINCT u.id, u.first_name, u.last_name, u.patronymic, u.birth_date, u.email FROM users u
+	LEFT JOIN user_organizations uo ON uo.user_id = u.id
+	LEFT JOIN organizations o ON o.id = uo.organization_id
+	WHERE ` + strings.Join(conds, " AND ") + " ORDER BY u.id LIMIT ?"
+	args = append(args, limit)
+
+	stmt, err := s.db.Prepare(q)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Patronymic, &user.BirthDate, &user.Email); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// UpdateUser update profile fields of the user
+func (s *Storage) UpdateUser(user models.User) error {
+	const fn = "storage.sqlite.UpdateUser"
+	defer observeQuery(fn)()
+
+	stmt, err := s.db.Prepare("UPDATE users SET first_name = ?, last_name = ?, patronymic = ?, birth_date = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(user.FirstName, user.LastName, user.Patronymic, user.BirthDate, user.ID)
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s:%w", fn, err)
+	}
+	if n == 0 {
+		return repo.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UsersWhoseBirthdayIsInXDays return []User whose birthday is in 1..X days from today
 func (s *Storage) UsersWhoseBirthdayIsInXDays(x int) ([]models.User, error) {
-	const fn = "storage.sqlite.UsersWhoseBirthdayIsInXDays"
+	return s.UsersByBirthdayDays(1, x)
+}
+
+// UsersByBirthdayDays return []User whose birthday is in from..to days from today
+func (s *Storage) UsersByBirthdayDays(from, to int) ([]models.User, error) {
+	const fn = "storage.sqlite.UsersByBirthdayDays"
+	defer observeQuery(fn)()
+
+	if to < from {
+		return []models.User{}, nil
+	}
+
+	// Month-day pairs are listed explicitly to handle the New Year
+	now := time.Now()
+	days := make([]any, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		days = append(days, now.AddDate(0, 0, i).Format("01-02"))
+	}
 
 	stmt, err := s.db.Prepare("SELECT id, first_name, last_name, patronymic, birth_date, email " +
-		"FROM users WHERE DATE_FORMAT(birthdate, '%m-%d') " +
-		"BETWEEN DATE_FORMAT(DATE_ADD(CURDATE(), INTERVAL 1 DAY), '%m-%d') " +
-		"AND DATE_FORMAT(DATE_ADD(CURDATE(), INTERVAL ? DAY), '%m-%d')")
+		"FROM users WHERE strftime('%m-%d', birth_date) IN (?" + strings.Repeat(", ?", len(days)-1) + ")")
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(x)
+	rows, err := stmt.Query(days...)
 	if err != nil {
 		return nil, fmt.Errorf("%s:%w", fn, err)
 	}
+	defer rows.Close()
 
 	users := make([]models.User, 0)
 	for rows.Next() {
@@ -87,6 +202,7 @@ func (s *Storage) UsersWhoseBirthdayIsInXDays(x int) ([]models.User, error) {
 // SaveUser save User into storage and return his UserID
 func (s *Storage) SaveUser(user models.User) (int64, error) {
 	const fn = "storage.sqlite.SaveUser"
+	defer observeQuery(fn)()
 
 	stmt, err := s.db.Prepare("INSERT INTO users (first_name, last_name, patronymic, birth_date, email) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
@@ -120,6 +236,7 @@ func (s *Storage) SaveAllUserInfo(
 	userOrganization models.Organization,
 ) (int64, error) {
 	const fn = "storage.sqlite.SaveAllUserInfo"
+	defer observeQuery(fn)()
 
 	uID, err := s.SaveUser(user)
 	if err != nil {
@@ -146,3 +263,71 @@ func (s *Storage) SaveAllUserInfo(
 
 	return uID, nil
 }
+
+// deletedUser replaces the user in admin audit records, admin_id of the deleted admin is 0
+const deletedUser = "deleted user"
+
+// auditTargets are admin audit records with the target user ?1: actions on the user by ID and searches by ID or email
+const auditTargets = `(action IN ('find_user', 'activate_user', 'deactivate_user', 'resend_link') AND target = CAST(?1 AS TEXT))
+	OR lower(target) IN (SELECT lower(email) FROM users WHERE id = ?1)`
+
+// DeleteUser removes the user and everything linked to him in one transaction.
+// Returns messengers of the users subscribed to his birthday, so they can be notified.
+// Admin audit records are kept for accountability, but the user ID and email in them are anonymized.
+func (s *Storage) DeleteUser(uID int64) ([]models.UserMessenger, error) {
+	const fn = "storage.sqlite.DeleteUser"
+	defer observeQuery(fn)()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", uID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	if !exists {
+		return nil, repo.ErrUserNotFound
+	}
+
+	rows, err := tx.Query(`SELECT um.user_id, um.messenger_type, um.messenger_id, um.chat_id, um.is_activated, um.token, um.token_expires_at, um.language, um.client_language, um.unreachable_at
+	FROM user_messengers um JOIN subscribes s ON s.sub_id = um.user_id WHERE s.user_id = ?`, uID)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	affected, err := scanUserMessengers(rows, fn)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows without the user ID are found by the email and messengers, so they go before users and user_messengers
+	queries := []string{
+		"DELETE FROM subscribes WHERE user_id = ?1 OR sub_id = ?1",
+		"DELETE FROM celebrations WHERE user_id = ?",
+		"DELETE FROM admins WHERE user_id = ?",
+		"DELETE FROM api_keys WHERE created_by = ?1 OR subject_id = ?1",
+		"DELETE FROM sessions WHERE (messenger_type, messenger_id) IN (SELECT messenger_type, messenger_id FROM user_messengers WHERE user_id = ?)",
+		"DELETE FROM emails WHERE lower(recipient) IN (SELECT lower(email) FROM users WHERE id = ?)",
+		"DELETE FROM lockouts WHERE subject IN (" + lockoutSubjects + ")",
+		"UPDATE admin_audit SET target = '" + deletedUser + "' WHERE " + auditTargets,
+		"UPDATE admin_audit SET admin_id = 0 WHERE admin_id = ?",
+		"DELETE FROM user_messengers WHERE user_id = ?",
+		"DELETE FROM user_organizations WHERE user_id = ?",
+		"DELETE FROM users WHERE id = ?",
+	}
+	for _, q := range queries {
+		if _, err := tx.Exec(q, uID); err != nil {
+			return nil, fmt.Errorf("%s:%w", fn, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+
+	return affected, nil
+}
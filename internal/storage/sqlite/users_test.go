@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	repo "github.com/arxonic/gmh/internal/storage"
+)
+
+// userTables are the queries of everything stored about the user: :uid is the user ID, :email the email,
+// :type and :mid the messenger account. Every table with user data must be listed here and cleared by DeleteUser
+var userTables = map[string]string{
+	"users":              "SELECT 1 FROM users WHERE id = :uid",
+	"user_organizations": "SELECT 1 FROM user_organizations WHERE user_id = :uid",
+	"user_messengers":    "SELECT 1 FROM user_messengers WHERE user_id = :uid",
+	"subscribes":         "SELECT 1 FROM subscribes WHERE user_id = :uid OR sub_id = :uid",
+	"celebrations":       "SELECT 1 FROM celebrations WHERE user_id = :uid",
+	"admins":             "SELECT 1 FROM admins WHERE user_id = :uid",
+	"api_keys":           "SELECT 1 FROM api_keys WHERE created_by = :uid",
+	"sessions":           "SELECT 1 FROM sessions WHERE messenger_type = :type AND messenger_id = :mid",
+	"emails":             "SELECT 1 FROM emails WHERE lower(recipient) = lower(:email)",
+	"lockouts":           "SELECT 1 FROM lockouts WHERE subject IN (lower(:email), :type || ':' || :mid)",
+	"admin_audit": `SELECT 1 FROM admin_audit WHERE admin_id = :uid OR lower(target) = lower(:email)
+		OR (action = 'deactivate_user' AND target = CAST(:uid AS TEXT))`,
+}
+
+// testUser is a user of 2_fill_test with the telegram account
+type testUser struct {
+	id          int64
+	email       string
+	messengerID int64
+}
+
+var (
+	ivan = testUser{id: 1, email: "ivan.ivanov@example.com", messengerID: 123456789}
+	petr = testUser{id: 2, email: "petr.petrov@example.com", messengerID: 987654321}
+)
+
+// args are the named arguments of userTables queries
+func (u testUser) args() []any {
+	return []any{
+		sql.Named("uid", u.id),
+		sql.Named("email", u.email),
+		sql.Named("type", "telegram"),
+		sql.Named("mid", u.messengerID),
+	}
+}
+
+// seedUser links the user to rows of every table with user data
+func seedUser(t *testing.T, s *Storage, u, other testUser) {
+	t.Helper()
+
+	exec(t, s, "INSERT OR IGNORE INTO subscribes (user_id, sub_id) VALUES (?, ?), (?, ?)", u.id, other.id, other.id, u.id)
+	exec(t, s, "INSERT INTO celebrations (user_id, birthday) VALUES (?, ?)", u.id, time.Now())
+	exec(t, s, "INSERT INTO admins (user_id, role) VALUES (?, 'global')", u.id)
+	exec(t, s, "INSERT INTO api_keys (name, key_hash, scopes, created_by) VALUES (?, ?, 'admin', ?)", u.email, u.email, u.id)
+	exec(t, s, "INSERT INTO sessions (messenger_type, messenger_id, state, data) VALUES ('telegram', ?, 0, '{}')", u.messengerID)
+	// The email is logged as it was typed by the user
+	exec(t, s, "INSERT INTO emails (recipient, subject, status) VALUES (upper(?), 'activation', 'sent')", u.email)
+	exec(t, s, "INSERT INTO lockouts (kind, subject, locked_until) VALUES ('email', ?, ?), ('registration', ?, ?)",
+		u.email, time.Now(), fmt.Sprintf("telegram:%d", u.messengerID), time.Now())
+	// The user is the admin who found and deactivated the other user, the celebration has the ID of the other user
+	exec(t, s, `INSERT INTO admin_audit (admin_id, action, target) VALUES (?1, 'find_user', upper(?2)),
+		(?1, 'deactivate_user', CAST(?3 AS TEXT)), (?1, 'cancel_celebration', CAST(?3 AS TEXT))`, u.id, other.email, other.id)
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := newTestStorage(t)
+
+	seedUser(t, s, ivan, petr)
+	seedUser(t, s, petr, ivan)
+
+	for table, q := range userTables {
+		if count(t, s, q, ivan.args()...) == 0 {
+			t.Fatalf("%s: no rows of the user before deletion", table)
+		}
+	}
+
+	affected, err := s.DeleteUser(ivan.id)
+	if err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	// The other user is subscribed to the birthday of the deleted one
+	if len(affected) != 1 || affected[0].UserID != petr.id {
+		t.Errorf("affected = %+v, want messengers of user %d", affected, petr.id)
+	}
+
+	for table, q := range userTables {
+		if n := count(t, s, q, ivan.args()...); n != 0 {
+			t.Errorf("%s: %d rows of the deleted user left", table, n)
+		}
+	}
+
+	// Audit records of other targets with the same ID are not anonymized
+	if n := count(t, s, "SELECT 1 FROM admin_audit WHERE action = 'cancel_celebration' AND target = ?", fmt.Sprint(ivan.id)); n != 1 {
+		t.Errorf("audit of the celebration %d = %d rows, want 1", ivan.id, n)
+	}
+
+	// Rows of the other user are kept but subscriptions to the deleted one
+	for table, q := range userTables {
+		if table == "subscribes" {
+			continue
+		}
+		if count(t, s, q, petr.args()...) == 0 {
+			t.Errorf("%s: rows of the other user are deleted", table)
+		}
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.DeleteUser(100); !errors.Is(err, repo.ErrUserNotFound) {
+		t.Fatalf("DeleteUser = %v, want ErrUserNotFound", err)
+	}
+}
+
+// TestUserDataByUserID checks that rows without the user ID are exported by the email and messengers
+func TestUserDataByUserID(t *testing.T) {
+	s := newTestStorage(t)
+
+	seedUser(t, s, ivan, petr)
+	seedUser(t, s, petr, ivan)
+
+	sessions, err := s.SessionsByUserID(ivan.id)
+	if err != nil || len(sessions) != 1 || sessions[0].MessengerID != ivan.messengerID {
+		t.Errorf("SessionsByUserID = %+v, %v", sessions, err)
+	}
+
+	emails, err := s.EmailsByUserID(ivan.id)
+	if err != nil || len(emails) != 1 || !strings.EqualFold(emails[0].Recipient, ivan.email) {
+		t.Errorf("EmailsByUserID = %+v, %v", emails, err)
+	}
+
+	lockouts, err := s.LockoutsByUserID(ivan.id)
+	if err != nil || len(lockouts) != 2 {
+		t.Errorf("LockoutsByUserID = %+v, %v", lockouts, err)
+	}
+
+	keys, err := s.APIKeysByCreator(ivan.id)
+	if err != nil || len(keys) != 1 || keys[0].CreatedBy != ivan.id {
+		t.Errorf("APIKeysByCreator = %+v, %v", keys, err)
+	}
+}
@@ -7,6 +7,17 @@ import (
 var (
 	ErrUserExists           = errors.New("user alredy exists")
 	ErrUserNotFound         = errors.New("user not found")
+	ErrMessengerExists      = errors.New("messenger account alredy exists")
 	ErrOrganizationExists   = errors.New("organization alredy exists")
 	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrInvalidToken         = errors.New("invalid activation token")
+	ErrTokenExpired         = errors.New("activation token expired")
+	ErrAlreadyActivated     = errors.New("account alredy activated")
+	ErrSubscriptionExists   = errors.New("subscription alredy exists")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrCelebrationExists    = errors.New("celebration alredy exists")
+	ErrCelebrationNotFound  = errors.New("celebration not found")
+	ErrAPIKeyExists         = errors.New("api key alredy exists")
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+	ErrSessionNotFound      = errors.New("session not found")
 )
@@ -0,0 +1 @@
+ALTER TABLE user_messengers DROP COLUMN unreachable_at;
@@ -0,0 +1,2 @@
+-- Время, когда мессенджер сообщил, что пользователь заблокировал бота (NULL - сообщения доставляются)
+ALTER TABLE user_messengers ADD COLUMN unreachable_at DATETIME;
@@ -0,0 +1,9 @@
+-- Состояния диалогов пользователей с ботом, переживают перезапуск сервиса
+CREATE TABLE IF NOT EXISTS sessions (
+    messenger_type  TEXT NOT NULL,
+    messenger_id    INTEGER NOT NULL,
+    state           INTEGER NOT NULL,  -- Состояние ДКА
+    data            TEXT NOT NULL,     -- Состояние и прогресс поиска коллег в JSON
+    updated_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (messenger_type, messenger_id)
+);
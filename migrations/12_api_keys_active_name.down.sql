@@ -0,0 +1,21 @@
+-- Имена отозванных ключей, выданные повторно, не уникальны, такие отозванные ключи удаляются
+DELETE FROM api_keys WHERE revoked_at IS NOT NULL AND name IN (SELECT name FROM api_keys GROUP BY name HAVING COUNT(*) > 1);
+
+CREATE TABLE api_keys_old (
+    id              INTEGER PRIMARY KEY,
+    name            TEXT NOT NULL UNIQUE,
+    key_hash        TEXT NOT NULL UNIQUE,
+    scopes          TEXT NOT NULL,
+    created_by      INTEGER NOT NULL,
+    expires_at      DATETIME,
+    last_used_at    DATETIME,
+    revoked_at      DATETIME,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+INSERT INTO api_keys_old (id, name, key_hash, scopes, created_by, expires_at, last_used_at, revoked_at, created_at)
+SELECT id, name, key_hash, scopes, created_by, expires_at, last_used_at, revoked_at, created_at FROM api_keys;
+
+DROP TABLE api_keys;
+ALTER TABLE api_keys_old RENAME TO api_keys;
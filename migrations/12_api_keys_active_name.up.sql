@@ -0,0 +1,22 @@
+-- Имя ключа уникально только среди действующих ключей, имя отозванного ключа можно выдать снова.
+-- SQLite не удаляет ограничение UNIQUE, поэтому таблица пересоздается
+CREATE TABLE api_keys_new (
+    id              INTEGER PRIMARY KEY,
+    name            TEXT NOT NULL,
+    key_hash        TEXT NOT NULL UNIQUE,
+    scopes          TEXT NOT NULL,     -- Через пробел, например 'read:users write:subscriptions'
+    created_by      INTEGER NOT NULL,  -- users.id администратора
+    expires_at      DATETIME,          -- NULL - без срока действия
+    last_used_at    DATETIME,
+    revoked_at      DATETIME,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+INSERT INTO api_keys_new (id, name, key_hash, scopes, created_by, expires_at, last_used_at, revoked_at, created_at)
+SELECT id, name, key_hash, scopes, created_by, expires_at, last_used_at, revoked_at, created_at FROM api_keys;
+
+DROP TABLE api_keys;
+ALTER TABLE api_keys_new RENAME TO api_keys;
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_active_name ON api_keys (name) WHERE revoked_at IS NULL;
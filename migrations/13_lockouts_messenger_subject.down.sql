@@ -0,0 +1,8 @@
+-- Блокировки других мессенджеров не имеют tgID и удаляются
+DELETE FROM lockouts
+WHERE kind IN ('messenger_user', 'registration') AND instr(subject, ':') > 0 AND subject NOT LIKE 'telegram:%';
+
+UPDATE lockouts SET subject = substr(subject, length('telegram:') + 1)
+WHERE kind IN ('messenger_user', 'registration') AND subject LIKE 'telegram:%';
+
+UPDATE lockouts SET kind = 'tg_user' WHERE kind = 'messenger_user';
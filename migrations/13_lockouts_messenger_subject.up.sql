@@ -0,0 +1,5 @@
+-- Блокировки пользователей мессенджеров хранятся по субъекту '<тип мессенджера>:<ID>', раньше это был tgID
+UPDATE lockouts SET kind = 'messenger_user' WHERE kind = 'tg_user';
+
+UPDATE lockouts SET subject = 'telegram:' || subject
+WHERE kind IN ('messenger_user', 'registration') AND instr(subject, ':') = 0;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS user_messengers_account;
@@ -0,0 +1,12 @@
+-- Аккаунт мессенджера принадлежит одному пользователю. Из повторных регистраций остается
+-- активированная, а если таких нет, то первая
+DELETE FROM user_messengers WHERE rowid IN (
+    SELECT rowid FROM (
+        SELECT rowid, ROW_NUMBER() OVER (
+            PARTITION BY messenger_type, messenger_id ORDER BY is_activated DESC, rowid
+        ) AS n
+        FROM user_messengers
+    ) WHERE n > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_messengers_account ON user_messengers (messenger_type, messenger_id);
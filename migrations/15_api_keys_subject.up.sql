@@ -0,0 +1,4 @@
+-- Личный ключ действует от имени пользователя subject_id, у ключей сервисов subject_id нет
+ALTER TABLE api_keys ADD COLUMN subject_id INTEGER REFERENCES users(id);
+
+UPDATE api_keys SET subject_id = created_by WHERE name = 'personal:' || created_by;
@@ -0,0 +1,2 @@
+-- Срок действия токена активации аккаунта
+ALTER TABLE user_messengers ADD COLUMN token_expires_at DATETIME;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS celebrations;
+DROP TABLE IF EXISTS admin_audit;
+DROP TABLE IF EXISTS admins;
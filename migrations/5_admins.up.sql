@@ -0,0 +1,32 @@
+-- Администраторы: глобальные или в рамках организации
+CREATE TABLE IF NOT EXISTS admins (
+    id              INTEGER PRIMARY KEY,
+    user_id         INTEGER NOT NULL,
+    role            TEXT NOT NULL, -- 'global' или 'organization'
+    organization_id INTEGER,        -- Только для роли 'organization'
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    UNIQUE (user_id, role, organization_id)
+);
+
+-- Журнал действий администраторов
+CREATE TABLE IF NOT EXISTS admin_audit (
+    id          INTEGER PRIMARY KEY,
+    admin_id    INTEGER NOT NULL, -- users.id администратора
+    action      TEXT NOT NULL,
+    target      TEXT,
+    details     TEXT,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Поздравления, которые создает планировщик за неделю до ДР
+CREATE TABLE IF NOT EXISTS celebrations (
+    id          INTEGER PRIMARY KEY,
+    user_id     INTEGER NOT NULL, -- Именинник
+    birthday    DATE NOT NULL,    -- Дата ближайшего ДР
+    link        TEXT,
+    status      TEXT NOT NULL DEFAULT 'active', -- 'active' или 'cancelled'
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    UNIQUE (user_id, birthday)
+);
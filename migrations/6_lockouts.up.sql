@@ -0,0 +1,11 @@
+-- Временные блокировки за превышение лимита запросов
+CREATE TABLE IF NOT EXISTS lockouts (
+    id              INTEGER PRIMARY KEY,
+    kind            TEXT NOT NULL, -- Например, 'tg_user', 'ip', 'email'
+    subject         TEXT NOT NULL, -- tgID, IP адрес или email
+    reason          TEXT,
+    locked_until    DATETIME NOT NULL,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS lockouts_kind_subject ON lockouts (kind, subject);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS emails_status_idx;
+DROP TABLE IF EXISTS emails;
@@ -0,0 +1,11 @@
+-- Журнал отправленных писем. Текст письма не хранится, в нем ссылка активации
+CREATE TABLE IF NOT EXISTS emails (
+    id          INTEGER PRIMARY KEY,
+    recipient   TEXT NOT NULL,
+    subject     TEXT NOT NULL,
+    status      TEXT NOT NULL, -- 'sent' или 'failed'
+    error       TEXT,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS emails_status_idx ON emails (status, created_at);
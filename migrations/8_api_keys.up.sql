@@ -0,0 +1,13 @@
+-- Ключи доступа к API для внешних систем. Хранится только SHA-256 хеш ключа
+CREATE TABLE IF NOT EXISTS api_keys (
+    id              INTEGER PRIMARY KEY,
+    name            TEXT NOT NULL UNIQUE,
+    key_hash        TEXT NOT NULL UNIQUE,
+    scopes          TEXT NOT NULL,     -- Через пробел, например 'read:users write:subscriptions'
+    created_by      INTEGER NOT NULL,  -- users.id администратора
+    expires_at      DATETIME,          -- NULL - без срока действия
+    last_used_at    DATETIME,
+    revoked_at      DATETIME,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
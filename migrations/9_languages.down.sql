@@ -0,0 +1,2 @@
+ALTER TABLE user_messengers DROP COLUMN client_language;
+ALTER TABLE user_messengers DROP COLUMN language;
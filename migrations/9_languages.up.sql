@@ -0,0 +1,4 @@
+-- Язык сообщений, выбранный пользователем (NULL - по настройкам мессенджера)
+ALTER TABLE user_messengers ADD COLUMN language TEXT;
+-- Язык клиента мессенджера на момент регистрации
+ALTER TABLE user_messengers ADD COLUMN client_language TEXT;